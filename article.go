@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -11,24 +14,43 @@ import (
 type Article struct {
 	Title       string
 	Description string
-	Content     string
 	Url         string
+	// Parsed wikitext, nil until the article is loaded
+	Document *Document
+	// Code of the Wikipedia the article is from
+	Lang string
+	// The same article on other Wikipedias we can read
+	LangLinks []LangLink
+}
+
+// LangLink is an interlanguage link to another Wikipedia's article
+type LangLink struct {
+	Lang  string
+	Title string
 }
 
 var DefaultArticleMap = map[int]Article{
-	0: {Title: "...", Description: "type something!", Content: "", Url: ""},
+	0: {Title: "...", Description: "type something!", Url: ""},
 }
 
 func (m model) headerView() string {
-	title := titleStyle.Render(fmt.Sprintf("wki - %s", m.shownArticle))
-	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(title)))
+	crumb := m.history.breadcrumb(m.width - 16)
+	if crumb == "" {
+		crumb = m.shownArticle
+	}
+	title := titleStyle.Render(fmt.Sprintf("wki - %s", crumb))
+	line := strings.Repeat("─", max(0, m.width-lipgloss.Width(title)))
 	return lipgloss.JoinHorizontal(lipgloss.Center, title, line)
 }
 
 func (m model) footerView() string {
-	returnNote := "Return to search ← "
-	info := infoStyle.Render(fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100))
-	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(info)-len(returnNote)))
+	returnNote := "Back ← "
+	if m.info != "" {
+		returnNote = m.info + " "
+	}
+	section := lipgloss.NewStyle().MaxWidth(32).Render(m.currentSectionTitle())
+	info := infoStyle.Render(fmt.Sprintf("§ %s %3.f%%", section, m.viewport.ScrollPercent()*100))
+	line := strings.Repeat("─", max(0, m.width-lipgloss.Width(info)-lipgloss.Width(returnNote)))
 	return lipgloss.JoinHorizontal(lipgloss.Center, noteStyle(returnNote), line, info)
 }
 
@@ -36,7 +58,14 @@ func ArticleView(m model) string {
 	if !m.ready {
 		return "\n  Initializing..."
 	}
-	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.viewport.View(), m.footerView())
+	body := m.viewport.View()
+	if m.showContents && m.rendering != nil {
+		body = lipgloss.JoinHorizontal(lipgloss.Top, m.contentsView(), body)
+	}
+	if m.showFacts {
+		body = lipgloss.JoinHorizontal(lipgloss.Top, body, m.factsView())
+	}
+	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), body, m.footerView())
 }
 
 func ArticleUpdate(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -46,11 +75,71 @@ func ArticleUpdate(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 	)
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.info = ""
 		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEsc:
+			// Stop loading an article before quitting
+			if m.stopLoading() {
+				return m, nil
+			}
 			return m, tea.Quit
 		case tea.KeyLeft:
-			m.pageName = "search"
+			return m, m.navigate(-1)
+		case tea.KeyRight:
+			return m, m.navigate(1)
+		case tea.KeyRunes:
+			switch msg.String() {
+			case "h":
+				m.openHistory()
+				return m, nil
+			case "l":
+				m.openLanguages()
+				return m, nil
+			case "L":
+				m.openLangLinks()
+				return m, nil
+			case "t":
+				m.toggleContents()
+				return m, nil
+			case "i":
+				cmd := m.toggleFacts()
+				return m, cmd
+			case "I":
+				m.toggleInfobox()
+				return m, nil
+			case "]":
+				m.jumpSection(1)
+				return m, nil
+			case "[":
+				m.jumpSection(-1)
+				return m, nil
+			case "/":
+				m.openSections()
+				return m, nil
+			case "w":
+				m.openDefine()
+				return m, nil
+			}
+		case tea.KeyTab:
+			m.cycleLink(1)
+			return m, nil
+		case tea.KeyShiftTab:
+			m.cycleLink(-1)
+			return m, nil
+		case tea.KeyEnter:
+			if m.focusedLink == 0 {
+				break
+			}
+			link := m.rendering.Links[m.focusedLink-1]
+			if link.Citation > 0 {
+				m.openCitation(m.focusedLink)
+				return m, nil
+			}
+			target := link.Target
+			m.info = fmt.Sprintf("Loading %s...", target)
+			return m, m.loadArticleCmd(Article{Title: target})
 		}
 	}
 
@@ -60,3 +149,152 @@ func ArticleUpdate(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	return m, tea.Batch(cmds...)
 }
+
+// Switches to the article page showing article
+func (m *model) showArticle(article Article) {
+	m.pageName = "article"
+	m.stopLoading()
+	m.info = ""
+	if err := m.useLang(article.Lang); err != nil {
+		m.info = err.Error()
+	}
+	m.shownArticle = article.Title
+	m.langLinks = article.LangLinks
+	m.document = article.Document
+	if m.wiktionary && m.document != nil {
+		m.document = ParseDictionary(article.Title, article.Document).Document()
+	}
+	m.focusedLink = 0
+	m.renderArticle()
+	m.viewport.GotoTop()
+}
+
+// Lays out the shown article at the viewport's width, with
+// the lead's infobox beside it when there's room
+func (m *model) renderArticle() {
+	if m.document == nil {
+		return
+	}
+	width, options := m.viewport.Width, renderOptions{placeholders: m.showTemplates}
+	m.infobox = nil
+	if m.hideInfobox {
+		options.infobox = infoboxHidden
+	} else if lead := leadInfobox(m.document); lead != nil && width >= infoboxAsideMinWidth {
+		asideWidth := infoboxAsideWidth(width)
+		m.infobox = RenderInfobox(lead, asideWidth)
+		width -= asideWidth + 1
+		options.infobox = infoboxAside
+	}
+	m.rendering = render(m.document, width, options)
+	m.setContent()
+}
+
+// Shows the rendering with the focused link highlighted
+func (m *model) setContent() {
+	m.content = m.rendering.Highlighted(m.focusedLink)
+	if m.infobox != nil {
+		width := m.viewport.Width - lipgloss.Width(m.infobox.String()) - 1
+		article := lipgloss.NewStyle().Width(width).Render(m.content)
+		m.content = lipgloss.JoinHorizontal(lipgloss.Top, article, " ", m.infobox.String())
+	}
+	m.viewport.SetContent(m.content)
+}
+
+func (m *model) toggleInfobox() {
+	m.hideInfobox = !m.hideInfobox
+	m.fitArticle()
+}
+
+// Moves the link selection forward (step 1) or backward (step -1)
+// through the links currently in view, wrapping around
+func (m *model) cycleLink(step int) {
+	if m.rendering == nil {
+		return
+	}
+	var visible []int
+	top, bottom := m.viewport.YOffset, m.viewport.YOffset+m.viewport.Height
+	for i, link := range m.rendering.Links {
+		if link.Line >= top && link.Line < bottom {
+			visible = append(visible, i+1)
+		}
+	}
+	if len(visible) == 0 {
+		m.focusedLink = 0
+	} else {
+		next := slices.Index(visible, m.focusedLink)
+		switch {
+		case next < 0 && step > 0:
+			next = 0
+		case next < 0:
+			next = len(visible) - 1
+		default:
+			next = (next + step + len(visible)) % len(visible)
+		}
+		m.focusedLink = visible[next]
+	}
+	m.setContent()
+}
+
+// Loads an article from the Wikipedia for its Lang, or the current one
+func (m *model) loadArticleCmd(article Article) tea.Cmd {
+	ctx, cancel := m.startLoad()
+	src := m.source
+	return func() tea.Msg {
+		defer cancel()
+		if article.Lang != "" && article.Lang != src.Language() {
+			var err error
+			if src, err = src.InLang(article.Lang); err != nil {
+				return articleResponseMsg{article: article, err: err}
+			}
+		}
+		loaded, err := src.LoadArticle(ctx, article)
+		if errors.Is(err, ErrNotFound) {
+			return articleResponseMsg{article: loaded, err: err, suggestions: suggestTitles(ctx, src, article.Title)}
+		}
+		return articleResponseMsg{article: loaded, err: err}
+	}
+}
+
+// Loads an article picked at random
+func (m *model) randomArticleCmd() tea.Cmd {
+	ctx, cancel := m.startLoad()
+	src := m.source
+	return func() tea.Msg {
+		defer cancel()
+		titles, err := src.Random(ctx, 1)
+		if err == nil && len(titles) == 0 {
+			err = fmt.Errorf("%w: nothing to pick from", ErrNotFound)
+		}
+		if err != nil {
+			return articleResponseMsg{err: err}
+		}
+		article, err := src.LoadArticle(ctx, Article{Title: titles[0]})
+		return articleResponseMsg{article: article, err: err}
+	}
+}
+
+// Cancels the article load in flight for a new one
+func (m *model) startLoad() (context.Context, context.CancelFunc) {
+	m.stopLoading()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelLoad = cancel
+	return ctx, cancel
+}
+
+// Cancels the article load in flight, if there is one
+func (m *model) stopLoading() bool {
+	if m.cancelLoad == nil {
+		return false
+	}
+	m.cancelLoad()
+	m.cancelLoad = nil
+	m.info = ""
+	return true
+}
+
+type articleResponseMsg struct {
+	article Article
+	err     error
+	// Close matches for an article that wasn't found
+	suggestions []string
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for how long cached pages are trusted without asking
+// the API, and how much room the cache may take up
+const (
+	DefaultCacheTTL  = 24 * time.Hour
+	DefaultCacheSize = 100 << 20
+)
+
+// diskCache keeps API results as JSON files, one per key, in a
+// directory under the user's cache directory. A file's modification
+// time is when its content was last known to be current.
+//
+// Methods do nothing on a nil *diskCache, so a client without
+// one simply always asks the API.
+type diskCache struct {
+	dir string
+	// How long entries are used without checking they're current
+	ttl time.Duration
+	// Most bytes to keep, the least recently checked entries go first
+	maxBytes int64
+
+	mu sync.Mutex
+	// Bytes the entries take up, counted on the first put and
+	// kept up to date after, so only going over the limit
+	// needs a look at the whole directory. -1 until counted.
+	size int64
+}
+
+func newDiskCache(dir string, ttl time.Duration, maxBytes int64) *diskCache {
+	return &diskCache{dir: dir, ttl: ttl, maxBytes: maxBytes, size: -1}
+}
+
+// Where the cache lives by default, e.g. ~/.cache/wki on Linux
+func cacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wki"), nil
+}
+
+// Keys are made of parts like the wiki and title, hashed for a file name
+func cacheKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (dc *diskCache) path(key string) string {
+	return filepath.Join(dc.dir, key+".json")
+}
+
+// Reads the entry for key into v, reporting whether
+// there was one and whether it's within the TTL
+func (dc *diskCache) get(key string, v any) (found bool, fresh bool) {
+	if dc == nil {
+		return false, false
+	}
+	path := dc.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, v) != nil {
+		return false, false
+	}
+	return true, time.Since(info.ModTime()) < dc.ttl
+}
+
+// Stores v for key. Failing to write is no worse than not caching.
+func (dc *diskCache) put(key string, v any) {
+	if dc == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(dc.dir, 0o755); err != nil {
+		return
+	}
+	// Write then rename so readers never see half an entry
+	tmp, err := os.CreateTemp(dc.dir, "*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	tmp.Close()
+	path := dc.path(key)
+	var replaced int64
+	if info, err := os.Stat(path); err == nil {
+		replaced = info.Size()
+	}
+	if err != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	dc.grow(int64(len(data)) - replaced)
+}
+
+// Counts delta more bytes in the cache, pruning it if that's too many
+func (dc *diskCache) grow(delta int64) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if dc.size < 0 {
+		dc.size = dc.prune(-1)
+	} else {
+		dc.size += delta
+	}
+	if dc.size > dc.maxBytes {
+		dc.size = dc.prune(dc.maxBytes)
+	}
+}
+
+// Marks the entry for key as just checked to be current
+func (dc *diskCache) touch(key string) {
+	if dc == nil {
+		return
+	}
+	now := time.Now()
+	os.Chtimes(dc.path(key), now, now)
+}
+
+// Removes the least recently checked entries until the cache
+// fits in limit bytes, or none if limit is negative, and
+// returns how many bytes are left
+func (dc *diskCache) prune(limit int64) int64 {
+	entries, err := os.ReadDir(dc.dir)
+	if err != nil {
+		return 0
+	}
+	var infos []fs.FileInfo
+	var total int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		infos = append(infos, info)
+		total += info.Size()
+	}
+	if limit < 0 || total <= limit {
+		return total
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		if total <= limit {
+			break
+		}
+		if os.Remove(filepath.Join(dc.dir, info.Name())) == nil {
+			total -= info.Size()
+		}
+	}
+	return total
+}
+
+// Deletes everything in the cache
+func (dc *diskCache) clear() error {
+	if dc == nil {
+		return nil
+	}
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.size = -1
+	err := os.RemoveAll(dc.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDiskCache(t *testing.T) {
+	dc := newDiskCache(t.TempDir(), time.Hour, 1<<20)
+	var got string
+	if found, _ := dc.get("key", &got); found {
+		t.Fatalf("get() found an entry in an empty cache")
+	}
+
+	dc.put("key", "value")
+	if found, fresh := dc.get("key", &got); !found || !fresh || got != "value" {
+		t.Fatalf("get() = %q, found %v, fresh %v, expected fresh value", got, found, fresh)
+	}
+
+	// An hour later it's still there but needs checking
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(dc.path("key"), old, old)
+	if found, fresh := dc.get("key", &got); !found || fresh {
+		t.Fatalf("get() found %v, fresh %v, expected found stale", found, fresh)
+	}
+	dc.touch("key")
+	if _, fresh := dc.get("key", &got); !fresh {
+		t.Fatalf("get() after touch() isn't fresh")
+	}
+
+	if err := dc.clear(); err != nil {
+		t.Fatalf("clear() error = %v", err)
+	}
+	if found, _ := dc.get("key", &got); found {
+		t.Fatalf("get() found an entry after clear()")
+	}
+
+	var nilCache *diskCache
+	nilCache.put("key", "value")
+	if found, _ := nilCache.get("key", &got); found {
+		t.Fatalf("nil cache found an entry")
+	}
+}
+
+func TestDiskCachePrune(t *testing.T) {
+	// Room for two entries of 10 bytes
+	dc := newDiskCache(t.TempDir(), time.Hour, 25)
+	for i, key := range []string{"a", "b", "c"} {
+		dc.put(key, "12345678")
+		// Apart enough for the order to show in mtimes
+		at := time.Now().Add(time.Duration(i-3) * time.Minute)
+		os.Chtimes(dc.path(key), at, at)
+	}
+	dc.put("b", "12345678")
+
+	var got string
+	for key, expected := range map[string]bool{"a": false, "b": true, "c": true} {
+		if found, _ := dc.get(key, &got); found != expected {
+			t.Errorf("get(%q) found %v, expected %v", key, found, expected)
+		}
+	}
+	// Replacing b counts only the difference
+	if dc.size != 20 {
+		t.Errorf("size = %d, expected 20", dc.size)
+	}
+}
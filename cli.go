@@ -0,0 +1,513 @@
+package main
+
+// Non-interactive commands for scripts and pipes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
+)
+
+// Exit codes of the non-interactive commands
+const (
+	exitOK = 0
+	// Network and other failures
+	exitError    = 1
+	exitUsage    = 2
+	exitNotFound = 3
+)
+
+const getUsage = `Usage: wki get [--lang CODE] [--wiki URL|NAME] [--no-cache] [--width N] [--no-infobox] [--show-templates] [--render wikitext|html] <title>
+
+Prints an article as plain text, with its infobox as a card.
+Exits with 3 if there's no such article and 1 on network errors.
+`
+
+const searchUsage = `Usage: wki search [--lang CODE] [--wiki URL|NAME] [--no-cache] [--limit N] [--offset N] [--format text|json|tsv] <query>
+
+Prints the articles matching a search.
+Exits with 3 if nothing matches and 1 on network errors.
+`
+
+const defineUsage = `Usage: wki define [--lang CODE] [--in LANGUAGE] [--wiki URL|NAME] [--no-cache] [--width N] <word>
+
+Prints a word's entry in Wiktionary as a dictionary card: its
+pronunciation, definitions, etymology and translations.
+Exits with 3 if there's no such word and 1 on network errors.
+`
+
+const indexUsage = `Usage: wki index [--out DIR] [--full-text] <dump>
+       wki index <archive.zim>
+
+Indexes the articles of a MediaWiki XML dump, such as
+enwiki-latest-pages-articles.xml.bz2 from https://dumps.wikimedia.org,
+for reading offline with wki --dump DIR.
+
+Or indexes the words of the articles in a Kiwix ZIM archive, next to
+it in archive.zim.index, so wki --zim searches them after titles.
+`
+
+const cacheUsage = `Usage: wki cache clear
+
+Deletes the cached articles and searches.
+`
+
+// Non-interactive commands by name, as in wki <command>.
+// index reads files only, so it's given no client.
+var commands = map[string]func(context.Context, *Client, []string, io.Writer, io.Writer) int{
+	"get":    runGet,
+	"search": runSearch,
+	"define": runDefine,
+	"cache":  runCache,
+	"index":  runIndex,
+}
+
+// Adds -l and --lang, for reading another language's Wikipedia
+func langFlag(fs *flag.FlagSet, lang string) *string {
+	value := fs.String("lang", lang, "Language `code` of the Wikipedia to read, e.g. de")
+	fs.StringVar(value, "l", lang, "Shorthand for --lang")
+	return value
+}
+
+// Adds --no-cache, for always asking the API
+func cacheFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("no-cache", false, "Don't read or write the cache")
+}
+
+// Adds --show-templates, for seeing what's left out of articles
+func templatesFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("show-templates", false, "Show templates wki can't expand as dim {{placeholders}}")
+}
+
+// How articles are turned into text: from their wikitext,
+// or from the HTML the wiki renders them to
+type renderMode string
+
+func (r *renderMode) String() string {
+	return string(*r)
+}
+
+func (r *renderMode) Set(value string) error {
+	if value != "wikitext" && value != "html" {
+		return errors.New(`must be "wikitext" or "html"`)
+	}
+	*r = renderMode(value)
+	return nil
+}
+
+// Adds --render, for reading articles with templates wki can't
+// expand as the wiki renders them
+func renderFlag(fs *flag.FlagSet) *renderMode {
+	mode := renderMode("wikitext")
+	fs.Var(&mode, "render", "Render articles from their `wikitext` or from the\nwiki's HTML, which has every template expanded")
+	return &mode
+}
+
+// Adds -w and --wiki, for reading a MediaWiki other than Wikipedia
+func wikiFlag(fs *flag.FlagSet) *string {
+	value := fs.String("wiki", "", "`URL` of another MediaWiki's api.php, or the\nname of a wiki in the config, to read instead of Wikipedia")
+	fs.StringVar(value, "w", "", "Shorthand for --wiki")
+	return value
+}
+
+// client, or one for the wiki and language asked for. Another
+// wiki is read in its own language unless one is asked for.
+func clientFor(ctx context.Context, fs *flag.FlagSet, client *Client, lang string, wiki string) (*Client, error) {
+	if wiki != "" {
+		var err error
+		if client, err = client.WithWiki(ctx, wiki); err != nil {
+			return nil, err
+		}
+		if !isFlagSet(fs, "lang") && !isFlagSet(fs, "l") {
+			return client, nil
+		}
+	}
+	if lang == client.Lang {
+		return client, nil
+	}
+	return client.WithLang(lang)
+}
+
+// Exit code for failing to set up the client: bad
+// arguments are usage errors, failing to reach a wiki isn't
+func clientForExit(err error) int {
+	if errors.Is(err, ErrUnknownLang) || errors.Is(err, ErrUnknownWiki) || errors.Is(err, ErrOneLanguage) {
+		return exitUsage
+	}
+	return exitError
+}
+
+// Parses flags given before or after the positional arguments
+func parseCommand(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// Styling only makes it into terminals
+func setupOutput(stdout io.Writer) {
+	if f, ok := stdout.(*os.File); !ok || !term.IsTerminal(f.Fd()) {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+// Width to wrap at when none is given: the terminal's,
+// or no wrapping when the output isn't a terminal
+func outputWidth(stdout io.Writer) int {
+	f, ok := stdout.(*os.File)
+	if !ok || !term.IsTerminal(f.Fd()) {
+		return 0
+	}
+	width, _, err := term.GetSize(f.Fd())
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// wki get <title>
+func runGet(ctx context.Context, client *Client, args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, getUsage)
+		fs.PrintDefaults()
+	}
+	lang := langFlag(fs, client.Lang)
+	wiki := wikiFlag(fs)
+	noCache := cacheFlag(fs)
+	width := fs.Int("width", 0, "Wrap lines at this many columns, 0 for no wrapping.\nDefaults to the terminal's width when printing to one")
+	noInfobox := fs.Bool("no-infobox", false, "Leave out infoboxes")
+	showTemplates := templatesFlag(fs)
+	mode := renderFlag(fs)
+	positional, err := parseCommand(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) == 0 || *width < 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if !isFlagSet(fs, "width") {
+		*width = outputWidth(stdout)
+	}
+	if *noCache {
+		client = client.withoutCache()
+	}
+	if *mode == "html" {
+		client = client.withHTML()
+	}
+	client, err = clientFor(ctx, fs, client, *lang, *wiki)
+	if err != nil {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return clientForExit(err)
+	}
+	setupOutput(stdout)
+
+	title := strings.Join(positional, " ")
+	article, err := client.LoadArticle(ctx, Article{Title: title})
+	if errors.Is(err, ErrNotFound) {
+		fmt.Fprintf(stderr, "wki: no article titled %q\n", title)
+		// Offer the closest matches instead
+		if titles := suggestTitles(ctx, client, title); len(titles) > 0 {
+			fmt.Fprintf(stderr, "Did you mean: %s?\n", strings.Join(titles, ", "))
+		}
+		return exitNotFound
+	}
+	if errors.Is(err, ErrInvalidTitle) {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return exitNotFound
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return exitError
+	}
+	options := renderOptions{placeholders: *showTemplates}
+	if *noInfobox {
+		options.infobox = infoboxHidden
+	}
+	fmt.Fprintln(stdout, render(article.Document, *width, options).String())
+	return exitOK
+}
+
+// wki search <query>
+func runSearch(ctx context.Context, client *Client, args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, searchUsage)
+		fs.PrintDefaults()
+	}
+	lang := langFlag(fs, client.Lang)
+	wiki := wikiFlag(fs)
+	noCache := cacheFlag(fs)
+	limit := fs.Int("limit", 10, "Most results to print")
+	offset := fs.Int("offset", 0, "Results to skip, for paging")
+	format := fs.String("format", "text", "Output format: text, json or tsv")
+	positional, err := parseCommand(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	printResults, ok := resultPrinters[*format]
+	if len(positional) == 0 || *limit < 1 || *offset < 0 || !ok {
+		fs.Usage()
+		return exitUsage
+	}
+	if *noCache {
+		client = client.withoutCache()
+	}
+	client, err = clientFor(ctx, fs, client, *lang, *wiki)
+	if err != nil {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return clientForExit(err)
+	}
+	setupOutput(stdout)
+
+	query := strings.Join(positional, " ")
+	results, err := client.Search(ctx, query, *limit, *offset)
+	if err != nil {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return exitError
+	}
+	if err := printResults(stdout, results); err != nil {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return exitError
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(stderr, "wki: nothing matches %q\n", query)
+		return exitNotFound
+	}
+	return exitOK
+}
+
+// wki define <word>
+func runDefine(ctx context.Context, client *Client, args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("define", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, defineUsage)
+		fs.PrintDefaults()
+	}
+	lang := langFlag(fs, client.Lang)
+	in := fs.String("in", "", "Only show the entry for this `language`, e.g. French,\nby default the Wiktionary's own. all shows every language")
+	wiki := wikiFlag(fs)
+	noCache := cacheFlag(fs)
+	width := fs.Int("width", 0, "Wrap lines at this many columns, 0 for no wrapping.\nDefaults to the terminal's width when printing to one")
+	positional, err := parseCommand(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) == 0 || *width < 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if !isFlagSet(fs, "width") {
+		*width = outputWidth(stdout)
+	}
+	if *noCache {
+		client = client.withoutCache()
+	}
+	client, err = clientFor(ctx, fs, client, *lang, *wiki)
+	if err == nil {
+		client, err = client.Wiktionary()
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return clientForExit(err)
+	}
+	setupOutput(stdout)
+
+	switch *in {
+	case "":
+		*in = WikipediaLangs[client.Lang]
+	case "all":
+		*in = ""
+	}
+	word := strings.Join(positional, " ")
+	dictionary, err := lookUp(ctx, client, word, *in)
+	if errors.Is(err, ErrNotFound) {
+		fmt.Fprintf(stderr, "wki: no entry for %q\n", word)
+		return exitNotFound
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintln(stdout, Render(dictionary.Document(), *width).String())
+	return exitOK
+}
+
+// wki cache clear
+func runCache(ctx context.Context, client *Client, args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("cache", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, cacheUsage)
+	}
+	positional, err := parseCommand(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != 1 || positional[0] != "clear" {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := client.cache.clear(); err != nil {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// wki index <dump>
+func runIndex(ctx context.Context, client *Client, args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("index", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, indexUsage)
+		fs.PrintDefaults()
+	}
+	out := fs.String("out", "", "`Directory` to put the index in, by default\nnamed after the dump and next to it")
+	fullText := fs.Bool("full-text", false, "Also index the words of every article, for searching\nmore than titles. Takes a lot more room and time")
+	positional, err := parseCommand(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	path := positional[0]
+	if strings.HasSuffix(path, ".zim") {
+		return indexZIM(ctx, path, *out, stdout, stderr)
+	}
+	if *out == "" {
+		*out = defaultIndexDir(path)
+	}
+
+	dump, err := openDump(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return exitError
+	}
+	defer dump.Close()
+	articles, err := buildDumpIndex(ctx, dump, *out, *fullText)
+	if err != nil {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintf(stdout, "Indexed %d articles into %s, read them with wki --dump %s\n", articles, *out, *out)
+	return exitOK
+}
+
+// wki index <archive.zim>, which always goes next to the
+// archive, where wki --zim looks for it
+func indexZIM(ctx context.Context, path string, out string, stdout io.Writer, stderr io.Writer) int {
+	dir := defaultIndexDir(path)
+	if out != "" && out != dir {
+		fmt.Fprintf(stderr, "wki: an archive's index goes next to it, in %s\n", dir)
+		return exitUsage
+	}
+	archive, err := openZIMArchive(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return exitError
+	}
+	defer archive.Close()
+	articles, err := buildZIMIndex(ctx, archive, dir)
+	if err != nil {
+		fmt.Fprintf(stderr, "wki: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintf(stdout, "Indexed the words of %d articles into %s, wki --zim %s searches them\n", articles, dir, path)
+	return exitOK
+}
+
+// Writers for each --format of wki search
+var resultPrinters = map[string]func(io.Writer, []SearchResult) error{
+	"text": printResultsText,
+	"json": printResultsJSON,
+	"tsv":  printResultsTSV,
+}
+
+func printResultsText(w io.Writer, results []SearchResult) error {
+	for i, result := range results {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, listArticleStyle(result.Title))
+		fmt.Fprintln(w, "  "+result.Url)
+		if result.Snippet != "" {
+			fmt.Fprintln(w, "  "+result.Snippet)
+		}
+		_, err := fmt.Fprintln(w, noteStyle(fmt.Sprintf("  %d words, edited %s", result.WordCount, result.Timestamp.Format(time.DateOnly))))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printResultsJSON(w io.Writer, results []SearchResult) error {
+	if results == nil {
+		results = []SearchResult{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+// One result per line: title, snippet, URL, word count and timestamp
+func printResultsTSV(w io.Writer, results []SearchResult) error {
+	field := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	for _, result := range results {
+		_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+			field.Replace(result.Title), field.Replace(result.Snippet), result.Url,
+			result.WordCount, result.Timestamp.Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
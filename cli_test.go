@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunGet(t *testing.T) {
+	tests := map[string]struct {
+		args        []string
+		apiResponse string
+		statusCode  int
+		exitCode    int
+		output      string
+	}{
+		"article": {
+			args:        []string{"--width", "12", "Giraffe"},
+			apiResponse: `{"query": {"pages": [{"title": "Giraffe", "revisions": [{"slots": {"main": {"content": "The '''giraffe''' is a large mammal."}}}]}]}}`,
+			statusCode:  http.StatusOK,
+			exitCode:    exitOK,
+			output:      "The giraffe\nis a large\nmammal.\n",
+		},
+		"flags after the title": {
+			args:        []string{"Giraffe", "--width=0"},
+			apiResponse: `{"query": {"pages": [{"title": "Giraffe", "revisions": [{"slots": {"main": {"content": "The giraffe is a large mammal."}}}]}]}}`,
+			statusCode:  http.StatusOK,
+			exitCode:    exitOK,
+			output:      "The giraffe is a large mammal.\n",
+		},
+		"no infobox": {
+			args:        []string{"--width=0", "--no-infobox", "Giraffe"},
+			apiResponse: `{"query": {"pages": [{"title": "Giraffe", "revisions": [{"slots": {"main": {"content": "{{Infobox animal\n| genus = Giraffa\n}}\nThe giraffe is a large mammal."}}}]}]}}`,
+			statusCode:  http.StatusOK,
+			exitCode:    exitOK,
+			output:      "The giraffe is a large mammal.\n",
+		},
+		"template placeholders": {
+			args:        []string{"--width=0", "--show-templates", "Giraffe"},
+			apiResponse: `{"query": {"pages": [{"title": "Giraffe", "revisions": [{"slots": {"main": {"content": "The giraffe{{mystery}} is {{convert|5.5|m|ft}} tall."}}}]}]}}`,
+			statusCode:  http.StatusOK,
+			exitCode:    exitOK,
+			output:      "The giraffe{{mystery}} is 5.5 metres (18 ft) tall.\n",
+		},
+		"rendered HTML": {
+			args:        []string{"--width=0", "--render=html", "Giraffe"},
+			apiResponse: `{"parse": {"title": "Giraffe", "revid": 1, "text": "<p>The <b>giraffe</b> is 5.5 metres (18 ft) tall.</p>"}}`,
+			statusCode:  http.StatusOK,
+			exitCode:    exitOK,
+			output:      "The giraffe is 5.5 metres (18 ft) tall.\n",
+		},
+		"unknown render mode": {
+			args:     []string{"--render=pdf", "Giraffe"},
+			exitCode: exitUsage,
+		},
+		"missing article": {
+			args:        []string{"Girafe"},
+			apiResponse: `{"query": {"pages": [{"title": "Girafe", "missing": true}]}}`,
+			statusCode:  http.StatusOK,
+			exitCode:    exitNotFound,
+		},
+		"server error": {
+			args:       []string{"Giraffe"},
+			statusCode: http.StatusInternalServerError,
+			exitCode:   exitError,
+		},
+		"no title": {
+			exitCode: exitUsage,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.statusCode)
+				w.Write([]byte(test.apiResponse))
+			}))
+			defer ts.Close()
+
+			client := &Client{ApiUrl: ts.URL + "/?"}
+			var stdout, stderr bytes.Buffer
+			code := runGet(context.Background(), client, test.args, &stdout, &stderr)
+			if code != test.exitCode {
+				t.Fatalf("runGet() exit code = %d, expected %d\n%s", code, test.exitCode, stderr.String())
+			}
+			if got := stdout.String(); got != test.output {
+				t.Fatalf("runGet() output = %q, expected %q", got, test.output)
+			}
+		})
+	}
+}
+
+func TestRunSearch(t *testing.T) {
+	tests := map[string]struct {
+		args     []string
+		exitCode int
+		output   string
+	}{
+		"tsv": {
+			args:     []string{"--format", "tsv", "--limit", "2", "match"},
+			exitCode: exitOK,
+			output: "Result 0\tmatch & more\thttps://en.wikipedia.org/wiki/Result_0\t0\t2024-05-01T12:00:00Z\n" +
+				"Result 1\tmatch & more\thttps://en.wikipedia.org/wiki/Result_1\t100\t2024-05-01T12:00:00Z\n",
+		},
+		"json": {
+			args:     []string{"match", "--format=json", "--limit=1", "--offset=2"},
+			exitCode: exitOK,
+			output: `[
+  {
+    "title": "Result 2",
+    "snippet": "match \u0026 more",
+    "url": "https://en.wikipedia.org/wiki/Result_2",
+    "wordcount": 200,
+    "timestamp": "2024-05-01T12:00:00Z"
+  }
+]
+`,
+		},
+		"text": {
+			args:     []string{"--limit", "1", "match"},
+			exitCode: exitOK,
+			output:   "Result 0\n  https://en.wikipedia.org/wiki/Result_0\n  match & more\n  0 words, edited 2024-05-01\n",
+		},
+		"nothing matches": {
+			args:     []string{"--format", "json", "--offset", "10", "match"},
+			exitCode: exitNotFound,
+			output:   "[]\n",
+		},
+		"unknown format": {
+			args:     []string{"--format", "xml", "match"},
+			exitCode: exitUsage,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ts := searchServer(4)
+			defer ts.Close()
+
+			client := &Client{ApiUrl: ts.URL + "/?", WikiUrl: "https://en.wikipedia.org/wiki"}
+			var stdout, stderr bytes.Buffer
+			code := runSearch(context.Background(), client, test.args, &stdout, &stderr)
+			if code != test.exitCode {
+				t.Fatalf("runSearch() exit code = %d, expected %d\n%s", code, test.exitCode, stderr.String())
+			}
+			if got := stdout.String(); got != test.output {
+				t.Fatalf("runSearch() output = %q, expected %q", got, test.output)
+			}
+		})
+	}
+}
+
+func TestRunDefine(t *testing.T) {
+	page, _ := json.Marshal(testEntry)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("titles") != "giraffe" {
+			w.Write([]byte(`{"query": {"pages": [{"title": "Okapi", "missing": true}]}}`))
+			return
+		}
+		w.Write([]byte(`{"query": {"pages": [{"title": "giraffe", "revisions": [{"slots": {"main": {"content": ` + string(page) + `}}}]}]}}`))
+	}))
+	defer ts.Close()
+
+	tests := map[string]struct {
+		args     []string
+		exitCode int
+		output   string
+	}{
+		"the Wiktionary's language": {
+			args:     []string{"Giraffe"},
+			exitCode: exitOK,
+			output:   "1. (zoology) A tall African mammal",
+		},
+		"another language": {
+			args:     []string{"--in", "German", "giraffe"},
+			exitCode: exitOK,
+			output:   "German\n\nNoun\n\n1. Giraffe giraffe",
+		},
+		"missing word": {
+			args:     []string{"okapi"},
+			exitCode: exitNotFound,
+		},
+		"no word": {
+			exitCode: exitUsage,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &Client{Lang: "en", ApiUrl: ts.URL + "/?"}
+			var stdout, stderr bytes.Buffer
+			code := runDefine(context.Background(), client, test.args, &stdout, &stderr)
+			if code != test.exitCode {
+				t.Fatalf("runDefine() exit code = %d, expected %d\n%s", code, test.exitCode, stderr.String())
+			}
+			if got := stdout.String(); !strings.Contains(got, test.output) {
+				t.Fatalf("runDefine() output = %q, expected it to contain %q", got, test.output)
+			}
+		})
+	}
+}
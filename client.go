@@ -1,125 +1,644 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// How long a request to the API may take by default
+const DefaultTimeout = 15 * time.Second
+
+const (
+	// Seconds of database replication lag after which the API should
+	// turn requests away, as https://www.mediawiki.org/wiki/Manual:Maxlag_parameter asks
+	maxLag = 5
+	// Retries of requests turned away for being busy or lagging
+	maxRetries = 3
+	// Wait before the first retry, doubled for each one after
+	retryBackoff = 500 * time.Millisecond
+)
+
+// Identifies wki to the API, as https://meta.wikimedia.org/wiki/User-Agent_policy asks
+func DefaultUserAgent() string {
+	return fmt.Sprintf("wki/%s (https://github.com/seporterfield/wki)", version)
+}
+
+// Wikimedia projects, whose wikis come in many languages
+// at <lang>.<project>, e.g. de.wiktionary.org
+var wikimediaProjects = []string{
+	"wikipedia.org", "wiktionary.org", "wikibooks.org", "wikinews.org",
+	"wikiquote.org", "wikisource.org", "wikiversity.org", "wikivoyage.org",
+}
+
 type Client struct {
-	Lang    string
+	Lang string
+	// Where articles are, either followed by /<title>
+	// or with $1 standing in for the title
 	WikiUrl string
 	ApiUrl  string
+	// Name of the wiki, empty for Wikipedias
+	Site string
+	// API of the Wikidata the wiki's articles have items in,
+	// DefaultWikidataUrl when empty
+	WikidataUrl string
+	// Makes the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+	// Sent with requests, DefaultUserAgent() when empty
+	UserAgent string
+	// Spaces out requests, shared with clients made by WithLang
+	limiter *rateLimiter
+	// Keeps articles and searches between sessions, nil for none
+	cache *diskCache
+	// API URLs of other wikis by name, for WithWiki
+	wikis map[string]string
+	// Loads articles as the wiki renders them to HTML,
+	// templates expanded, instead of as wikitext
+	html bool
 }
 
 func NewClient(lang string, unformattedWikiUrl string, unformattedApiUrl string) (*Client, error) {
 	if _, ok := WikipediaLangs[lang]; !ok {
-		return nil, fmt.Errorf("wikipedia language %s does not exist", lang)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLang, lang)
 	}
 	client := &Client{
 		Lang:    lang,
 		WikiUrl: fmt.Sprintf("https://%s.%s", lang, unformattedWikiUrl),
 		ApiUrl:  fmt.Sprintf("https://%s.%s", lang, unformattedApiUrl),
+		HTTPClient: &http.Client{
+			Timeout: DefaultTimeout,
+		},
+		limiter: newRateLimiter(defaultRequestRate, defaultRequestBurst),
+	}
+	return client, nil
+}
+
+// A client for the same project's wiki in lang, e.g. the German
+// Wikipedia, making requests the same way. Wikis outside
+// Wikimedia only come in one language.
+func (c *Client) WithLang(lang string) (*Client, error) {
+	project := c.project()
+	if project == "" {
+		return nil, fmt.Errorf("%w: %s", ErrOneLanguage, c.SiteName())
 	}
+	client, err := NewClient(lang, project+"/wiki", project+"/w/api.php?")
+	if err != nil {
+		return nil, err
+	}
+	client.Site = c.Site
+	client.WikidataUrl = c.WikidataUrl
+	client.HTTPClient = c.HTTPClient
+	client.UserAgent = c.UserAgent
+	client.limiter = c.limiter
+	client.cache = c.cache
+	client.wikis = c.wikis
+	client.html = c.html
 	return client, nil
 }
 
-func (c *Client) fetch(result WikipediaJSON, apiUrl string) error {
-	resp, err := http.Get(apiUrl)
+// A client for the Wiktionary in the client's language, for looking
+// words up. Wikis outside Wikimedia, such as a Wiktionary mirror,
+// are used as they are.
+func (c *Client) Wiktionary() (*Client, error) {
+	project := c.project()
+	if project == "" || project == "wiktionary.org" {
+		return c, nil
+	}
+	wiktionary := *c
+	wiktionary.ApiUrl = "https://" + c.Lang + ".wiktionary.org/w/api.php?"
+	wiktionary.Site = "Wiktionary"
+	return wiktionary.WithLang(c.Lang)
+}
+
+// The Wikimedia project the client's wiki is part of,
+// e.g. wikipedia.org, or "" for other wikis
+func (c *Client) project() string {
+	if c.ApiUrl == "" {
+		return "wikipedia.org"
+	}
+	u, err := url.Parse(c.ApiUrl)
 	if err != nil {
-		return errors.New("couldn't fetch data from Wikipedia API")
+		return ""
+	}
+	for _, project := range wikimediaProjects {
+		if strings.HasSuffix(u.Hostname(), "."+project) {
+			return project
+		}
+	}
+	return ""
+}
+
+// A client for another MediaWiki, given by the URL of its api.php or
+// the name it has in the config. Its language, name and where its
+// articles are come from the wiki itself.
+func (c *Client) WithWiki(ctx context.Context, wiki string) (*Client, error) {
+	apiUrl, ok := c.wikis[wiki]
+	if !ok {
+		if !strings.Contains(wiki, "://") {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownWiki, wiki)
+		}
+		apiUrl = wiki
+	}
+	client := *c
+	client.ApiUrl = strings.TrimSuffix(apiUrl, "?") + "?"
+	client.WikiUrl = ""
+	client.Site = ""
+
+	params := url.Values{}
+	params.Add("action", "query")
+	params.Add("formatversion", "2")
+	params.Add("meta", "siteinfo")
+	params.Add("siprop", "general")
+	params.Add("format", "json")
+
+	var result WikiSiteInfoJSON
+	if err := client.fetch(ctx, &result, client.ApiUrl+params.Encode()); err != nil {
+		return nil, fmt.Errorf("%s: %w", wiki, err)
+	}
+	general := result.Query.General
+	if general.SiteName == "" || general.ArticlePath == "" {
+		return nil, fmt.Errorf("%s: %w", wiki, ErrNotMediaWiki)
+	}
+	server := general.Server
+	// Protocol relative, as in //en.wikipedia.org
+	if strings.HasPrefix(server, "//") {
+		if u, err := url.Parse(client.ApiUrl); err == nil {
+			server = u.Scheme + ":" + server
+		}
+	}
+	client.Lang = general.Lang
+	client.Site = general.SiteName
+	client.WikiUrl = server + strings.TrimSuffix(general.ArticlePath, "/$1")
+	return &client, nil
+}
+
+func (c *Client) Language() string {
+	return c.Lang
+}
+
+// Wikimedia wikis get their language in their name
+func (c *Client) SiteName() string {
+	switch {
+	case c.Site == "":
+		return WikipediaLangs[c.Lang] + " Wikipedia"
+	case c.project() != "" && WikipediaLangs[c.Lang] != "":
+		return WikipediaLangs[c.Lang] + " " + c.Site
+	}
+	return c.Site
+}
+
+func (c *Client) InLang(lang string) (Source, error) {
+	client, err := c.WithLang(lang)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// A copy of the client that always asks the API
+func (c *Client) withoutCache() *Client {
+	client := *c
+	client.cache = nil
+	return &client
+}
+
+// A copy of the client that loads articles as rendered HTML
+func (c *Client) withHTML() *Client {
+	client := *c
+	client.html = true
+	return &client
+}
+
+// Fetches apiUrl and decodes its JSON into result, waiting and
+// retrying while the API is busy or lagging
+func (c *Client) fetch(ctx context.Context, result WikipediaJSON, apiUrl string) error {
+	u, err := url.Parse(apiUrl)
+	if err != nil {
+		return fmt.Errorf("couldn't make request: %w", err)
+	}
+	query := u.Query()
+	query.Set("maxlag", strconv.Itoa(maxLag))
+	u.RawQuery = query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		retry, retryAfter, err := c.fetchOnce(ctx, result, u.String())
+		if !retry || attempt == maxRetries {
+			return err
+		}
+		timer := time.NewTimer(backoff(attempt, retryAfter))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return &NetworkError{Err: ctx.Err()}
+		}
+	}
+}
+
+// Makes one attempt at a request, reporting whether it's worth
+// trying again and how long the server asked to wait, -1 if it didn't
+func (c *Client) fetchOnce(ctx context.Context, result WikipediaJSON, apiUrl string) (bool, time.Duration, error) {
+	if c.limiter != nil {
+		if err := c.limiter.wait(ctx); err != nil {
+			return false, -1, &NetworkError{Err: err}
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiUrl, nil)
+	if err != nil {
+		return false, -1, fmt.Errorf("couldn't make request: %w", err)
+	}
+	req.Header.Set("User-Agent", c.userAgent())
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, -1, &NetworkError{Err: err}
 	}
 	defer resp.Body.Close()
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+		busy := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+		return busy, retryAfter, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return errors.New("couldn't read response body")
+		return false, -1, &NetworkError{Err: err}
+	}
+
+	// Errors come back as {"error": {...}} with a 200
+	var envelope struct {
+		Error *APIError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error != nil {
+		return envelope.Error.Code == "maxlag", retryAfter, envelope.Error
 	}
 
 	err = json.Unmarshal(body, &result)
 	if err != nil {
-		return errors.New("couldn't decode JSON response")
+		return false, -1, fmt.Errorf("couldn't decode JSON response: %w", err)
 	}
-	return nil
+	return false, -1, nil
 }
 
-func (c *Client) LoadSearchList(queryText string) (map[int]Article, error) {
+// Reads a Retry-After header in seconds or as a date, -1 if missing
+func parseRetryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header)); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(0, time.Until(at))
+	}
+	return -1
+}
+
+// Wait before retry number attempt (0-based): what the server asked
+// for, or else doubling each time with some jitter so clients
+// throttled together don't all come back at once
+func backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter >= 0 {
+		return retryAfter
+	}
+	delay := retryBackoff << attempt
+	return delay + rand.N(delay/2+1)
+}
+
+func (c *Client) userAgent() string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+	return DefaultUserAgent()
+}
+
+// SearchResult is an article matching a search
+type SearchResult struct {
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"`
+	Url       string    `json:"url"`
+	WordCount int       `json:"wordcount"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Most results the API gives in one request
+const maxSearchLimit = 500
+
+// Searches for up to limit articles, skipping the first offset,
+// and asks for more pages of results until it has enough
+func (c *Client) Search(ctx context.Context, queryText string, limit int, offset int) ([]SearchResult, error) {
 	if strings.TrimSpace(queryText) == "" {
 		return nil, nil
 	}
+	key := cacheKey("search", c.ApiUrl, queryText, strconv.Itoa(limit), strconv.Itoa(offset))
+	var results []SearchResult
+	if found, fresh := c.cache.get(key, &results); found && fresh {
+		return results, nil
+	}
+	results = nil
 
-	params := url.Values{}
-	params.Add("action", "query")
-	params.Add("list", "search")
-	params.Add("srsearch", queryText)
-	params.Add("utf8", "")
-	params.Add("format", "json")
-	params.Add("srlimit", "6")
-	params.Add("srprop", "snippet")
+	for len(results) < limit {
+		params := url.Values{}
+		params.Add("action", "query")
+		params.Add("list", "search")
+		params.Add("srsearch", queryText)
+		params.Add("utf8", "")
+		params.Add("format", "json")
+		params.Add("srlimit", strconv.Itoa(min(limit-len(results), maxSearchLimit)))
+		params.Add("sroffset", strconv.Itoa(offset))
+		params.Add("srprop", "snippet|wordcount|timestamp")
 
-	apiUrl := c.ApiUrl + params.Encode()
-	var result WikipediaPageQueryJSON
-	err := c.fetch(&result, apiUrl)
-	if err != nil {
-		return nil, err
+		apiUrl := c.ApiUrl + params.Encode()
+		var result WikipediaPageQueryJSON
+		err := c.fetch(ctx, &result, apiUrl)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range result.Query.Search {
+			results = append(results, SearchResult{
+				Title: entry.Title,
+				// Snippets are HTML with the matches in <span>s
+				Snippet:   strings.TrimSpace(PlainText(ParseWikitext(entry.Snippet).Children)),
+				Url:       c.articleUrl(entry.Title),
+				WordCount: entry.WordCount,
+				Timestamp: entry.Timestamp,
+			})
+		}
+		if result.Continue == nil || len(result.Query.Search) == 0 {
+			break
+		}
+		offset = result.Continue.Sroffset
 	}
+	results = results[:min(len(results), limit)]
+	c.cache.put(key, results)
+	return results, nil
+}
 
-	if len(result.Query.Search) == 0 {
-		return nil, nil
+// The path of the wiki's article URLs, e.g. /wiki/$1, which links
+// in its HTML point to. Empty when the title's in the query instead.
+func (c *Client) articlePath() string {
+	u, err := url.Parse(c.articleUrl("$1"))
+	if err != nil || !strings.Contains(u.Path, "$1") {
+		return ""
 	}
+	return u.Path
+}
 
-	articles := make(map[int]Article)
-	for i, entry := range result.Query.Search {
-		articles[i] = Article{
-			Title: entry.Title,
-			//TODO use tea.Batch in update loop to send
-			// multiple API calls to get the page extract
-			// for each search result instead of using
-			// the snippet.
-
-			// Might be able to replace cleaning entirely with the "explaintext"
-			// https://www.mediawiki.org/wiki/Extension:TextExtracts
-			Description: CleanWikimediaHTML(entry.Snippet),
-			Content:     "",
-			Url:         fmt.Sprintf("%s/%s", c.WikiUrl, strings.ReplaceAll(entry.Title, " ", "_")),
+func (c *Client) articleUrl(title string) string {
+	title = strings.ReplaceAll(title, " ", "_")
+	if strings.Contains(c.WikiUrl, "$1") {
+		return strings.Replace(c.WikiUrl, "$1", url.PathEscape(title), 1)
+	}
+	return fmt.Sprintf("%s/%s", c.WikiUrl, title)
+}
+
+// cachedArticle is what the disk cache keeps of an article,
+// its wikitext or, when loaded as HTML, its HTML
+type cachedArticle struct {
+	Title     string     `json:"title"`
+	RevID     int        `json:"revid"`
+	Wikitext  string     `json:"wikitext"`
+	HTML      string     `json:"html,omitempty"`
+	LangLinks []LangLink `json:"langlinks"`
+}
+
+// cachedRevision is what the disk cache keeps under an article's
+// title, the revision last known to be its latest. Each revision's
+// content has an entry of its own, so they don't overwrite each other.
+type cachedRevision struct {
+	RevID int `json:"revid"`
+}
+
+// Loads an article from the cache while it's within the TTL, or
+// while its latest revision is one the cache has, otherwise from the API
+func (c *Client) LoadArticle(ctx context.Context, article Article) (Article, error) {
+	kind := "article"
+	if c.html {
+		kind = "article html"
+	}
+	revisionKey := func(revID int) string {
+		return cacheKey(kind, c.ApiUrl, article.Title, strconv.Itoa(revID))
+	}
+	titleKey := cacheKey(kind, c.ApiUrl, article.Title)
+	var latest cachedRevision
+	if found, fresh := c.cache.get(titleKey, &latest); found {
+		revID := latest.RevID
+		checked := false
+		// Offline or while the API's down, the cached revision will do
+		if !fresh {
+			if current, err := c.latestRevision(ctx, article.Title); err == nil {
+				revID, checked = current, true
+			}
+		}
+		var cached cachedArticle
+		if found, _ := c.cache.get(revisionKey(revID), &cached); found && revID != 0 {
+			switch {
+			case revID != latest.RevID:
+				c.cache.put(titleKey, cachedRevision{RevID: revID})
+			case checked:
+				c.cache.touch(titleKey)
+			}
+			// Keeps the revision from being pruned first
+			c.cache.touch(revisionKey(revID))
+			return c.newArticle(article, cached), nil
 		}
 	}
-	return articles, nil
+
+	var err error
+	var cached cachedArticle
+	if c.html {
+		cached, err = c.articleHTML(ctx, article.Title)
+	} else {
+		cached, err = c.articleWikitext(ctx, article.Title)
+	}
+	if err != nil {
+		return article, err
+	}
+	c.cache.put(revisionKey(cached.RevID), cached)
+	c.cache.put(titleKey, cachedRevision{RevID: cached.RevID})
+	return c.newArticle(article, cached), nil
 }
 
-func (c *Client) LoadArticle(article Article) (Article, error) {
+func (c *Client) articleWikitext(ctx context.Context, title string) (cachedArticle, error) {
 	params := url.Values{}
 	params.Add("action", "query")
 	params.Add("formatversion", "2")
-	params.Add("prop", "revisions")
-	params.Add("rvprop", "content")
+	params.Add("prop", "revisions|langlinks")
+	params.Add("lllimit", "max")
+	params.Add("rvprop", "content|ids")
 	params.Add("rvslots", "*")
-	params.Add("titles", article.Title)
+	params.Add("titles", title)
+	params.Add("redirects", "1")
 	params.Add("format", "json")
 
 	apiUrl := c.ApiUrl + params.Encode()
 
 	var result WikipediaPageJSON
-	err := c.fetch(&result, apiUrl)
+	err := c.fetch(ctx, &result, apiUrl)
 	if err != nil {
-		return article, err
+		return cachedArticle{}, err
+	}
+	page, err := resultPage(result, title)
+	if err != nil {
+		return cachedArticle{}, err
 	}
+	return cachedArticle{
+		Title:     page.Title,
+		RevID:     page.Revisions[0].RevID,
+		Wikitext:  page.Revisions[0].Slots.Main.Content,
+		LangLinks: readableLangLinks(page.LangLinks),
+	}, nil
+}
 
-	if len(result.Query.Pages) == 0 {
-		return article, errors.New("no pages found")
+// The article as the wiki renders it, with every template expanded
+func (c *Client) articleHTML(ctx context.Context, title string) (cachedArticle, error) {
+	params := url.Values{}
+	params.Add("action", "parse")
+	params.Add("formatversion", "2")
+	params.Add("prop", "text|langlinks|revid")
+	params.Add("page", title)
+	params.Add("redirects", "1")
+	params.Add("disableeditsection", "1")
+	params.Add("disabletoc", "1")
+	params.Add("format", "json")
+
+	var result WikipediaParseJSON
+	err := c.fetch(ctx, &result, c.ApiUrl+params.Encode())
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case "missingtitle":
+			return cachedArticle{}, fmt.Errorf("%w: %q", ErrNotFound, title)
+		case "invalidtitle":
+			return cachedArticle{}, fmt.Errorf("%w: %q: %s", ErrInvalidTitle, title, apiErr.Info)
+		}
 	}
+	if err != nil {
+		return cachedArticle{}, err
+	}
+	page := result.Parse
+	if page.Title == "" {
+		return cachedArticle{}, fmt.Errorf("%w: %q", ErrNotFound, title)
+	}
+	return cachedArticle{
+		Title:     page.Title,
+		RevID:     page.RevID,
+		HTML:      page.Text,
+		LangLinks: readableLangLinks(page.LangLinks),
+	}, nil
+}
 
+// The page a query for title found, or why there's none
+func resultPage(result WikipediaPageJSON, title string) (wikipediaPage, error) {
+	if len(result.Query.Pages) == 0 {
+		return wikipediaPage{}, fmt.Errorf("%w: %q", ErrNotFound, title)
+	}
 	page := result.Query.Pages[0]
+	switch {
+	case page.Invalid:
+		return page, fmt.Errorf("%w: %q: %s", ErrInvalidTitle, title, page.InvalidReason)
+	// Missing pages come back without revisions
+	case page.Missing || len(page.Revisions) == 0:
+		return page, fmt.Errorf("%w: %q", ErrNotFound, title)
+	}
+	return page, nil
+}
+
+// Only links to Wikipedias we know how to read
+func readableLangLinks(langLinks []wikipediaLangLink) []LangLink {
+	var links []LangLink
+	for _, link := range langLinks {
+		if _, ok := WikipediaLangs[link.Lang]; ok {
+			links = append(links, LangLink(link))
+		}
+	}
+	return links
+}
+
+// Fetches the interlanguage links of the article titled title
+func (c *Client) LangLinks(ctx context.Context, title string) ([]LangLink, error) {
+	params := url.Values{}
+	params.Add("action", "query")
+	params.Add("formatversion", "2")
+	params.Add("prop", "langlinks")
+	params.Add("lllimit", "max")
+	params.Add("titles", title)
+	params.Add("redirects", "1")
+	params.Add("format", "json")
+
+	var result WikipediaPageJSON
+	if err := c.fetch(ctx, &result, c.ApiUrl+params.Encode()); err != nil {
+		return nil, err
+	}
+	if len(result.Query.Pages) == 0 || result.Query.Pages[0].Missing {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, title)
+	}
+	return readableLangLinks(result.Query.Pages[0].LangLinks), nil
+}
+
+// Picks up to n articles at random
+func (c *Client) Random(ctx context.Context, n int) ([]string, error) {
+	params := url.Values{}
+	params.Add("action", "query")
+	params.Add("formatversion", "2")
+	params.Add("list", "random")
+	params.Add("rnnamespace", "0")
+	params.Add("rnlimit", strconv.Itoa(n))
+	params.Add("format", "json")
+
+	var result WikipediaRandomJSON
+	if err := c.fetch(ctx, &result, c.ApiUrl+params.Encode()); err != nil {
+		return nil, err
+	}
+	var titles []string
+	for _, page := range result.Query.Random {
+		titles = append(titles, page.Title)
+	}
+	return titles, nil
+}
+
+// Asks for just the id of an article's latest revision
+func (c *Client) latestRevision(ctx context.Context, title string) (int, error) {
+	params := url.Values{}
+	params.Add("action", "query")
+	params.Add("formatversion", "2")
+	params.Add("prop", "revisions")
+	params.Add("rvprop", "ids")
+	params.Add("titles", title)
+	params.Add("redirects", "1")
+	params.Add("format", "json")
+
+	var result WikipediaPageJSON
+	if err := c.fetch(ctx, &result, c.ApiUrl+params.Encode()); err != nil {
+		return 0, err
+	}
+	page, err := resultPage(result, title)
+	if err != nil {
+		return 0, err
+	}
+	return page.Revisions[0].RevID, nil
+}
+
+// Fills in article from a loaded page
+func (c *Client) newArticle(article Article, page cachedArticle) Article {
 	article.Title = page.Title
-	content := page.Revisions[0].Slots.Main.Content
-	article.Content = CleanWikimediaHTML(content)
-	return article, nil
+	article.Url = c.articleUrl(page.Title)
+	article.Lang = c.Lang
+	article.LangLinks = page.LangLinks
+	if page.HTML != "" {
+		article.Document = ParseHTML(page.HTML, c.articlePath())
+	} else {
+		article.Document = ParseWikitext(page.Wikitext)
+	}
+	return article
 }
@@ -1,9 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFetch(t *testing.T) {
@@ -39,7 +48,7 @@ func TestFetch(t *testing.T) {
 
 			client := &Client{}
 			var result WikipediaPageQueryJSON
-			err := client.fetch(&result, ts.URL)
+			err := client.fetch(context.Background(), &result, ts.URL)
 
 			if (err != nil) != test.expectedError {
 				t.Fatalf("fetch() error = %v, expectedError %v", err, test.expectedError)
@@ -51,3 +60,499 @@ func TestFetch(t *testing.T) {
 		})
 	}
 }
+
+// Serves search results "Result 0" to "Result n-1", two at a time
+func searchServer(n int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var offset, limit int
+		fmt.Sscan(r.URL.Query().Get("sroffset"), &offset)
+		fmt.Sscan(r.URL.Query().Get("srlimit"), &limit)
+		var hits []string
+		for i := offset; i < min(offset+min(limit, 2), n); i++ {
+			hits = append(hits, fmt.Sprintf(`{"title": "Result %d", "snippet": "<span class=\"searchmatch\">match</span> &amp; more", "wordcount": %d, "timestamp": "2024-05-01T12:00:00Z"}`, i, i*100))
+		}
+		next := ""
+		if offset+len(hits) < n {
+			next = fmt.Sprintf(`"continue": {"sroffset": %d, "continue": "-||"}, `, offset+len(hits))
+		}
+		fmt.Fprintf(w, `{%s"query": {"search": [%s]}}`, next, strings.Join(hits, ","))
+	}))
+}
+
+func TestSearch(t *testing.T) {
+	tests := map[string]struct {
+		available int
+		limit     int
+		offset    int
+		titles    []string
+	}{
+		"one page": {
+			available: 5,
+			limit:     2,
+			titles:    []string{"Result 0", "Result 1"},
+		},
+		"follows continue": {
+			available: 5,
+			limit:     3,
+			offset:    1,
+			titles:    []string{"Result 1", "Result 2", "Result 3"},
+		},
+		"runs out": {
+			available: 3,
+			limit:     10,
+			titles:    []string{"Result 0", "Result 1", "Result 2"},
+		},
+		"no results": {
+			available: 0,
+			limit:     10,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ts := searchServer(test.available)
+			defer ts.Close()
+
+			client := &Client{ApiUrl: ts.URL + "/?", WikiUrl: "https://en.wikipedia.org/wiki"}
+			results, err := client.Search(context.Background(), "match", test.limit, test.offset)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			var titles []string
+			for _, result := range results {
+				titles = append(titles, result.Title)
+			}
+			if !reflect.DeepEqual(titles, test.titles) {
+				t.Fatalf("Search() titles = %v, expected %v", titles, test.titles)
+			}
+			if len(results) > 0 && (results[0].Snippet != "match & more" || results[0].Url != "https://en.wikipedia.org/wiki/"+strings.ReplaceAll(test.titles[0], " ", "_")) {
+				t.Fatalf("Search() first result = %+v", results[0])
+			}
+		})
+	}
+}
+
+func TestLoadArticleLangLinks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("prop"); got != "revisions|langlinks" {
+			t.Errorf("prop = %q, expected revisions|langlinks", got)
+		}
+		w.Write([]byte(`{"query": {"pages": [{"title": "Giraffe",
+			"langlinks": [{"lang": "de", "title": "Giraffen"}, {"lang": "xx-fake", "title": "Nope"}, {"lang": "ja", "title": "キリン"}],
+			"revisions": [{"slots": {"main": {"content": "text"}}}]}]}}`))
+	}))
+	defer ts.Close()
+
+	client := &Client{Lang: "en", ApiUrl: ts.URL + "/?"}
+	article, err := client.LoadArticle(context.Background(), Article{Title: "Giraffe"})
+	if err != nil {
+		t.Fatalf("LoadArticle() error = %v", err)
+	}
+	expected := []LangLink{{Lang: "de", Title: "Giraffen"}, {Lang: "ja", Title: "キリン"}}
+	if !reflect.DeepEqual(article.LangLinks, expected) || article.Lang != "en" {
+		t.Fatalf("LoadArticle() lang %q, links %+v, expected en, %+v", article.Lang, article.LangLinks, expected)
+	}
+}
+
+func TestRandomAndLangLinks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("list") == "random":
+			if got := r.URL.Query().Get("rnlimit"); got != "2" {
+				t.Errorf("rnlimit = %q, expected 2", got)
+			}
+			w.Write([]byte(`{"query": {"random": [{"id": 1, "ns": 0, "title": "Okapi"}, {"id": 2, "ns": 0, "title": "Zebra"}]}}`))
+		case r.URL.Query().Get("prop") == "langlinks":
+			w.Write([]byte(`{"query": {"pages": [{"title": "Giraffe",
+				"langlinks": [{"lang": "de", "title": "Giraffen"}, {"lang": "xx-fake", "title": "Nope"}]}]}}`))
+		}
+	}))
+	defer ts.Close()
+
+	client := &Client{Lang: "en", ApiUrl: ts.URL + "/?"}
+	titles, err := client.Random(context.Background(), 2)
+	if err != nil || !reflect.DeepEqual(titles, []string{"Okapi", "Zebra"}) {
+		t.Fatalf("Random() = %q, %v", titles, err)
+	}
+	links, err := client.LangLinks(context.Background(), "Giraffe")
+	if err != nil || !reflect.DeepEqual(links, []LangLink{{Lang: "de", Title: "Giraffen"}}) {
+		t.Fatalf("LangLinks() = %+v, %v", links, err)
+	}
+}
+
+func TestWithWiki(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/w/api.php":
+			w.Write([]byte(`{"query": {"general": {"sitename": "Corp Wiki", "lang": "fr", "server": "//wiki.example.com", "articlepath": "/wiki/$1"}}}`))
+		case "/index.php":
+			w.Write([]byte(`{"query": {"general": {"sitename": "Old Wiki", "lang": "en", "server": "https://old.example.com", "articlepath": "/index.php?title=$1"}}}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer ts.Close()
+
+	base := &Client{Lang: "en", wikis: map[string]string{"corp": ts.URL + "/w/api.php"}}
+	tests := map[string]struct {
+		wiki        string
+		site        string
+		lang        string
+		articleUrl  string
+		articlePath string
+		err         error
+	}{
+		"by URL": {
+			wiki:        ts.URL + "/w/api.php",
+			site:        "Corp Wiki",
+			lang:        "fr",
+			articleUrl:  "http://wiki.example.com/wiki/Coffee_machine",
+			articlePath: "/wiki/$1",
+		},
+		"by name": {
+			wiki:        "corp",
+			site:        "Corp Wiki",
+			lang:        "fr",
+			articleUrl:  "http://wiki.example.com/wiki/Coffee_machine",
+			articlePath: "/wiki/$1",
+		},
+		"query article path": {
+			wiki:        ts.URL + "/index.php",
+			site:        "Old Wiki",
+			lang:        "en",
+			articleUrl:  "https://old.example.com/index.php?title=Coffee_machine",
+			articlePath: "",
+		},
+		"unknown name": {
+			wiki: "nope",
+			err:  ErrUnknownWiki,
+		},
+		"not a wiki": {
+			wiki: ts.URL + "/elsewhere",
+			err:  ErrNotMediaWiki,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := base.WithWiki(context.Background(), test.wiki)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("WithWiki() error = %v, expected %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			if client.SiteName() != test.site || client.Lang != test.lang || client.articleUrl("Coffee machine") != test.articleUrl {
+				t.Fatalf("WithWiki() site %q, lang %q, article URL %q", client.SiteName(), client.Lang, client.articleUrl("Coffee machine"))
+			}
+			if client.articlePath() != test.articlePath {
+				t.Fatalf("articlePath() = %q, expected %q", client.articlePath(), test.articlePath)
+			}
+			// Only Wikimedia wikis come in other languages
+			if _, err := client.WithLang("de"); !errors.Is(err, ErrOneLanguage) {
+				t.Fatalf("WithLang() error = %v, expected ErrOneLanguage", err)
+			}
+		})
+	}
+
+	wiktionary := &Client{Lang: "en", ApiUrl: "https://en.wiktionary.org/w/api.php?", Site: "Wiktionary"}
+	german, err := wiktionary.WithLang("de")
+	if err != nil {
+		t.Fatalf("WithLang() error = %v", err)
+	}
+	if german.ApiUrl != "https://de.wiktionary.org/w/api.php?" || german.SiteName() != "German Wiktionary" {
+		t.Fatalf("WithLang() API URL %q, site %q", german.ApiUrl, german.SiteName())
+	}
+
+	// Wikipedias look words up in the Wiktionary in their language
+	german, err = (&Client{Lang: "de"}).Wiktionary()
+	if err != nil {
+		t.Fatalf("Wiktionary() error = %v", err)
+	}
+	if german.ApiUrl != "https://de.wiktionary.org/w/api.php?" || german.SiteName() != "German Wiktionary" {
+		t.Fatalf("Wiktionary() API URL %q, site %q", german.ApiUrl, german.SiteName())
+	}
+}
+
+func TestLoadArticleCache(t *testing.T) {
+	revID := 1
+	down := false
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rvprop := r.URL.Query().Get("rvprop")
+		requests = append(requests, rvprop)
+		if down {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		content := ""
+		if rvprop != "ids" {
+			content = fmt.Sprintf(`, "slots": {"main": {"content": "Revision %d"}}`, revID)
+		}
+		fmt.Fprintf(w, `{"query": {"pages": [{"title": "Giraffe", "revisions": [{"revid": %d%s}]}]}}`, revID, content)
+	}))
+	defer ts.Close()
+
+	cache := newDiskCache(t.TempDir(), time.Hour, DefaultCacheSize)
+	client := &Client{Lang: "en", ApiUrl: ts.URL + "/?", cache: cache}
+	load := func(expected string, expectedRequests ...string) {
+		t.Helper()
+		requests = nil
+		article, err := client.LoadArticle(context.Background(), Article{Title: "Giraffe"})
+		if err != nil {
+			t.Fatalf("LoadArticle() error = %v", err)
+		}
+		text := Render(article.Document, 0).String()
+		if text != expected || !reflect.DeepEqual(requests, expectedRequests) {
+			t.Fatalf("LoadArticle() = %q with requests %q, expected %q with %q", text, requests, expected, expectedRequests)
+		}
+	}
+	stale := func() {
+		old := time.Now().Add(-2 * time.Hour)
+		os.Chtimes(cache.path(cacheKey("article", client.ApiUrl, "Giraffe")), old, old)
+	}
+
+	load("Revision 1", "content|ids")
+	// Fresh, so no requests
+	load("Revision 1")
+	// Stale but unchanged, so only the revision is checked
+	stale()
+	load("Revision 1", "ids")
+	load("Revision 1")
+	// Stale and edited since
+	stale()
+	revID = 2
+	load("Revision 2", "ids", "content|ids")
+	// Reverted, and revision 1 is still cached alongside revision 2
+	stale()
+	revID = 1
+	load("Revision 1", "ids")
+	load("Revision 1")
+	// Stale while the API's down, so the cached revision is
+	// used and checked again next time
+	stale()
+	down = true
+	load("Revision 1", "ids")
+	load("Revision 1", "ids")
+	down = false
+
+	client = client.withoutCache()
+	load("Revision 1", "content|ids")
+}
+
+func TestFetchTimeoutAndCancel(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	var result WikipediaPageQueryJSON
+	client := &Client{HTTPClient: &http.Client{Timeout: 50 * time.Millisecond}}
+	if err := client.fetch(context.Background(), &result, ts.URL); err == nil {
+		t.Fatalf("fetch() from a stalled server didn't time out")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client = &Client{}
+	if err := client.fetch(ctx, &result, ts.URL); !errors.Is(err, context.Canceled) {
+		t.Fatalf("fetch() error = %v, expected context.Canceled", err)
+	}
+}
+
+func TestFetchRetries(t *testing.T) {
+	type response struct {
+		status     int
+		retryAfter string
+		body       string
+	}
+	ok := response{status: http.StatusOK, body: `{"query": {"search": [{"title": "Go"}]}}`}
+	busy := response{status: http.StatusTooManyRequests, retryAfter: "0"}
+	lagging := response{status: http.StatusOK, retryAfter: "0", body: `{"error": {"code": "maxlag", "info": "Waiting for a database server: 6 seconds lagged."}}`}
+
+	tests := map[string]struct {
+		responses []response
+		requests  int
+		check     func(error) bool
+	}{
+		"retries when throttled": {
+			responses: []response{busy, {status: http.StatusServiceUnavailable, retryAfter: "0"}, ok},
+			requests:  3,
+			check:     func(err error) bool { return err == nil },
+		},
+		"retries when lagging": {
+			responses: []response{lagging, ok},
+			requests:  2,
+			check:     func(err error) bool { return err == nil },
+		},
+		"gives up": {
+			responses: []response{busy, busy, busy, busy, ok},
+			requests:  maxRetries + 1,
+			check: func(err error) bool {
+				var statusErr *StatusError
+				return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests
+			},
+		},
+		"API errors with a 200": {
+			responses: []response{{status: http.StatusOK, body: `{"error": {"code": "badvalue", "info": "Unrecognized value"}}`}},
+			requests:  1,
+			check: func(err error) bool {
+				var apiErr *APIError
+				return errors.As(err, &apiErr) && apiErr.Code == "badvalue" && apiErr.Info == "Unrecognized value"
+			},
+		},
+		"server errors aren't retried": {
+			responses: []response{{status: http.StatusInternalServerError}, ok},
+			requests:  1,
+			check:     func(err error) bool { return err != nil },
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			requests := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasPrefix(r.UserAgent(), "wki/") {
+					t.Errorf("User-Agent = %q, expected wki's own", r.UserAgent())
+				}
+				if got := r.URL.Query().Get("maxlag"); got != strconv.Itoa(maxLag) {
+					t.Errorf("maxlag = %q, expected %d", got, maxLag)
+				}
+				resp := test.responses[requests]
+				requests++
+				if resp.retryAfter != "" {
+					w.Header().Set("Retry-After", resp.retryAfter)
+				}
+				w.WriteHeader(resp.status)
+				w.Write([]byte(resp.body))
+			}))
+			defer ts.Close()
+
+			client := &Client{}
+			var result WikipediaPageQueryJSON
+			err := client.fetch(context.Background(), &result, ts.URL+"/?action=query")
+			if !test.check(err) {
+				t.Fatalf("fetch() error = %v", err)
+			}
+			if requests != test.requests {
+				t.Fatalf("fetch() made %d requests, expected %d", requests, test.requests)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if got := backoff(2, 3*time.Second); got != 3*time.Second {
+		t.Fatalf("backoff() = %v, expected the server's Retry-After", got)
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		base := retryBackoff << attempt
+		if got := backoff(attempt, -1); got < base || got > base+base/2 {
+			t.Fatalf("backoff(%d) = %v, expected between %v and %v", attempt, got, base, base+base/2)
+		}
+	}
+}
+
+func TestLoadArticleHTML(t *testing.T) {
+	var query url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`{"parse": {"title": "Giraffe", "revid": 7, "langlinks": [{"lang": "de", "title": "Giraffen"}, {"lang": "xx", "title": "?"}],
+			"text": "<div class=\"mw-parser-output\"><p>The <b>giraffe</b> is {{convert}}-free: 5.5 metres (18 ft) tall.<sup class=\"reference\"><a href=\"#cite_note-1\">[1]</a></sup></p>` +
+			`<div class=\"mw-heading mw-heading2\"><h2 id=\"Range\">Range</h2><span class=\"mw-editsection\">edit</span></div><p>Africa</p>` +
+			`<div class=\"reflist\"><ol class=\"references\"><li id=\"cite_note-1\"><span class=\"reference-text\">A book</span></li></ol></div></div>"}}`))
+	}))
+	defer ts.Close()
+
+	client := (&Client{Lang: "en", ApiUrl: ts.URL + "/?"}).withHTML()
+	article, err := client.LoadArticle(context.Background(), Article{Title: "giraffe"})
+	if err != nil {
+		t.Fatalf("LoadArticle() error = %v", err)
+	}
+	if query.Get("action") != "parse" || query.Get("page") != "giraffe" {
+		t.Fatalf("LoadArticle() asked for %v, expected the parsed page", query)
+	}
+	expected := "The giraffe is {{convert}}-free: 5.5 metres (18 ft) tall.[1]\n\nRange\n\nAfrica\n\n1. A book"
+	text := Render(article.Document, 0).String()
+	if article.Title != "Giraffe" || text != expected {
+		t.Fatalf("LoadArticle() = %q with %q, expected %q", article.Title, text, expected)
+	}
+	if !reflect.DeepEqual(article.LangLinks, []LangLink{{Lang: "de", Title: "Giraffen"}}) {
+		t.Fatalf("LoadArticle() language links = %v", article.LangLinks)
+	}
+}
+
+func TestLoadArticleErrors(t *testing.T) {
+	tests := map[string]struct {
+		apiResponse string
+		statusCode  int
+		target      error
+		// Loading rendered HTML
+		html bool
+	}{
+		"missing": {
+			apiResponse: `{"query": {"pages": [{"title": "Girafe", "missing": true}]}}`,
+			statusCode:  http.StatusOK,
+			target:      ErrNotFound,
+		},
+		"invalid": {
+			apiResponse: `{"query": {"pages": [{"title": "[[", "invalid": true, "invalidreason": "The requested page title contains invalid characters"}]}}`,
+			statusCode:  http.StatusOK,
+			target:      ErrInvalidTitle,
+		},
+		"rate limited": {
+			apiResponse: `{"error": {"code": "ratelimited", "info": "You've exceeded your rate limit."}}`,
+			statusCode:  http.StatusOK,
+			target:      ErrRateLimited,
+		},
+		"missing HTML": {
+			apiResponse: `{"error": {"code": "missingtitle", "info": "The page you specified doesn't exist."}}`,
+			statusCode:  http.StatusOK,
+			target:      ErrNotFound,
+			html:        true,
+		},
+		"invalid HTML": {
+			apiResponse: `{"error": {"code": "invalidtitle", "info": "Bad title \"[[\"."}}`,
+			statusCode:  http.StatusOK,
+			target:      ErrInvalidTitle,
+			html:        true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.statusCode)
+				w.Write([]byte(test.apiResponse))
+			}))
+			defer ts.Close()
+
+			client := &Client{ApiUrl: ts.URL + "/?", html: test.html}
+			_, err := client.LoadArticle(context.Background(), Article{Title: "Girafe"})
+			if !errors.Is(err, test.target) {
+				t.Fatalf("LoadArticle() error = %v, expected %v", err, test.target)
+			}
+		})
+	}
+}
+
+func TestNetworkError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	client := &Client{}
+	var result WikipediaPageQueryJSON
+	err := client.fetch(context.Background(), &result, ts.URL)
+	var networkErr *NetworkError
+	if !errors.As(err, &networkErr) {
+		t.Fatalf("fetch() from a closed server error = %v, expected a *NetworkError", err)
+	}
+	if got := errorInfo(err, "English Wikipedia"); got != "Couldn't reach English Wikipedia" {
+		t.Fatalf("errorInfo() = %q", got)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Config holds the defaults read from the config file, e.g.
+// ~/.config/wki/config.json on Linux
+type Config struct {
+	// Language code of the Wikipedia to use
+	Lang string `json:"lang"`
+	// How long requests may take, e.g. "30s"
+	Timeout string `json:"timeout"`
+	// Pause in typing before searching, e.g. "500ms"
+	Debounce string `json:"debounce"`
+	// Sent with API requests, e.g. "wki (jane@example.com)"
+	UserAgent string `json:"user_agent"`
+	// How long cached articles are used before checking
+	// they're current, e.g. "1h"
+	CacheTTL string `json:"cache_ttl"`
+	// Most megabytes the cache may take up
+	CacheSize int64 `json:"cache_size_mb"`
+	// API URLs of other wikis by name, for wki -w NAME, e.g.
+	// {"corp": "https://wiki.example.com/w/api.php"}
+	Wikis map[string]string `json:"wikis"`
+}
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wki", "config.json"), nil
+}
+
+// Reads the config file at path. A missing file is an empty config.
+func loadConfig(path string) (Config, error) {
+	var config Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("couldn't read config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Language to use when none is given on the command line:
+// $WKI_LANG, then the config file, then English
+func defaultLang(config Config) string {
+	if lang := os.Getenv("WKI_LANG"); lang != "" {
+		return lang
+	}
+	if config.Lang != "" {
+		return config.Lang
+	}
+	return "en"
+}
+
+// A client for lang's Wikipedia with the configured timeout
+func newClient(lang string, config Config) (*Client, error) {
+	client, err := NewClient(lang, DefaultWikiUrl, DefaultApiUrl)
+	if err != nil {
+		return nil, err
+	}
+	client.HTTPClient.Timeout, err = configDuration("timeout", config.Timeout, DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	client.UserAgent = config.UserAgent
+	client.wikis = config.Wikis
+	client.cache, err = configCache(config)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// The disk cache with the configured limits, nil when
+// there's nowhere to keep one
+func configCache(config Config) (*diskCache, error) {
+	ttl, err := configDuration("cache_ttl", config.CacheTTL, DefaultCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(DefaultCacheSize)
+	if config.CacheSize < 0 {
+		return nil, fmt.Errorf("bad cache_size_mb %d in config", config.CacheSize)
+	}
+	if config.CacheSize > 0 {
+		size = config.CacheSize << 20
+	}
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, nil
+	}
+	return newDiskCache(dir, ttl, size), nil
+}
+
+// Parses a duration from the config, fallback when it isn't set
+func configDuration(name string, value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("bad %s %q in config", name, value)
+	}
+	return d, nil
+}
+
+// Reads the config file from the usual place for the platform
+func userConfig() (Config, error) {
+	path, err := configPath()
+	if err != nil {
+		// Nowhere to look, so nothing to read
+		return Config{}, nil
+	}
+	return loadConfig(path)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultLang(t *testing.T) {
+	tests := map[string]struct {
+		config string
+		env    string
+		lang   string
+	}{
+		"no config": {
+			lang: "en",
+		},
+		"config file": {
+			config: `{"lang": "de"}`,
+			lang:   "de",
+		},
+		"environment over config file": {
+			config: `{"lang": "de"}`,
+			env:    "fr",
+			lang:   "fr",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if test.config != "" {
+				if err := os.WriteFile(path, []byte(test.config), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("WKI_LANG", test.env)
+
+			config, err := loadConfig(path)
+			if err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+			if got := defaultLang(config); got != test.lang {
+				t.Fatalf("defaultLang() = %q, expected %q", got, test.lang)
+			}
+		})
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"lang": `), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Fatal("loadConfig() expected an error for malformed JSON")
+	}
+}
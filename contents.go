@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Heading shown for the part of an article above its first section
+const leadSectionTitle = "(Top)"
+
+// Width of the table of contents sidebar
+func (m model) contentsWidth() int {
+	if !m.showContents {
+		return 0
+	}
+	return min(32, m.width/3)
+}
+
+// Width left over for the article text
+func (m model) articleWidth() int {
+	return m.width - m.contentsWidth() - m.factsWidth()
+}
+
+// Index of the section the reader is in, -1 above the first heading
+func (m model) currentSection() int {
+	if m.rendering == nil {
+		return -1
+	}
+	current := -1
+	for i, section := range m.rendering.Sections {
+		if section.Line > m.viewport.YOffset {
+			break
+		}
+		current = i
+	}
+	return current
+}
+
+func (m model) currentSectionTitle() string {
+	if i := m.currentSection(); i >= 0 {
+		return m.rendering.Sections[i].Title
+	}
+	return leadSectionTitle
+}
+
+// Scrolls to the next (step 1) or previous (step -1) section
+func (m *model) jumpSection(step int) {
+	if m.rendering == nil {
+		return
+	}
+	sections := m.rendering.Sections
+	offset := m.viewport.YOffset
+	if step > 0 {
+		for _, section := range sections {
+			if section.Line > offset {
+				m.viewport.SetYOffset(section.Line)
+				return
+			}
+		}
+		return
+	}
+	for i := len(sections) - 1; i >= 0; i-- {
+		if sections[i].Line < offset {
+			m.viewport.SetYOffset(sections[i].Line)
+			return
+		}
+	}
+	m.viewport.GotoTop()
+}
+
+// Shows or hides the sidebar
+func (m *model) toggleContents() {
+	m.showContents = !m.showContents
+	m.fitArticle()
+}
+
+// Re-wraps the article around the sidebars, keeping its place
+func (m *model) fitArticle() {
+	percent := m.viewport.ScrollPercent()
+	m.viewport.Width = m.articleWidth()
+	m.renderArticle()
+	m.viewport.SetYOffset(int(percent * float64(m.viewport.TotalLineCount()-m.viewport.Height)))
+}
+
+func (m model) contentsView() string {
+	width := m.contentsWidth() - 2
+	style := lipgloss.NewStyle().
+		Width(width).
+		Height(m.viewport.Height).
+		MaxHeight(m.viewport.Height).
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderRight(true).
+		PaddingRight(1)
+	lineStyle := lipgloss.NewStyle().MaxWidth(width)
+
+	entries := []string{leadSectionTitle}
+	for _, section := range m.rendering.Sections {
+		indent := strings.Repeat(" ", max(0, section.Level-2)*2)
+		entries = append(entries, indent+section.Title)
+	}
+	current := m.currentSection() + 1
+
+	// Keep the current section in view
+	start := max(0, min(current-m.viewport.Height/2, len(entries)-m.viewport.Height))
+	var lines []string
+	for i := start; i < len(entries) && i < start+m.viewport.Height; i++ {
+		line := lineStyle.Render(entries[i])
+		if i == current {
+			line = currentSectionStyle(line)
+		}
+		lines = append(lines, line)
+	}
+	return style.Render(strings.Join(lines, "\n"))
+}
+
+func (m *model) openSections() {
+	if m.rendering == nil {
+		return
+	}
+	var titles []string
+	for _, section := range m.rendering.Sections {
+		titles = append(titles, strings.Repeat("  ", max(0, section.Level-2))+section.Title)
+	}
+	m.popup = newFilterPopup("Go to section", "Section", titles)
+	m.pageName = "sections"
+}
+
+func SectionsView(m model) string {
+	return m.popup.view(m.width, m.height)
+}
+
+func SectionsUpdate(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.pageName = "article"
+	case tea.KeyUp:
+		m.popup.move(-1)
+	case tea.KeyDown:
+		m.popup.move(1)
+	case tea.KeyEnter:
+		m.pageName = "article"
+		if i, ok := m.popup.selected(); ok {
+			m.viewport.SetYOffset(m.rendering.Sections[i].Line)
+		}
+	default:
+		return m, m.popup.updateFilter(msg)
+	}
+	return m, nil
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A unit {{convert}} knows, as a multiple of its dimension's base unit
+type convertUnit struct {
+	symbol, name, plural string
+	dimension            string
+	factor               float64
+	// Added after scaling, for temperatures
+	offset float64
+	// What it's converted to when the template doesn't say
+	to string
+}
+
+// https://en.wikipedia.org/wiki/Module:Convert/data
+var convertUnits = map[string]convertUnit{
+	"m":   {"m", "metre", "metres", "length", 1, 0, "ft"},
+	"km":  {"km", "kilometre", "kilometres", "length", 1000, 0, "mi"},
+	"cm":  {"cm", "centimetre", "centimetres", "length", 0.01, 0, "in"},
+	"mm":  {"mm", "millimetre", "millimetres", "length", 0.001, 0, "in"},
+	"mi":  {"mi", "mile", "miles", "length", 1609.344, 0, "km"},
+	"ft":  {"ft", "foot", "feet", "length", 0.3048, 0, "m"},
+	"in":  {"in", "inch", "inches", "length", 0.0254, 0, "cm"},
+	"yd":  {"yd", "yard", "yards", "length", 0.9144, 0, "m"},
+	"nmi": {"nmi", "nautical mile", "nautical miles", "length", 1852, 0, "km"},
+
+	"m2":   {"m²", "square metre", "square metres", "area", 1, 0, "sqft"},
+	"km2":  {"km²", "square kilometre", "square kilometres", "area", 1e6, 0, "sqmi"},
+	"ha":   {"ha", "hectare", "hectares", "area", 1e4, 0, "acre"},
+	"acre": {"acres", "acre", "acres", "area", 4046.8564224, 0, "ha"},
+	"sqmi": {"sq mi", "square mile", "square miles", "area", 2589988.110336, 0, "km2"},
+	"sqft": {"sq ft", "square foot", "square feet", "area", 0.09290304, 0, "m2"},
+
+	"kg": {"kg", "kilogram", "kilograms", "mass", 1, 0, "lb"},
+	"g":  {"g", "gram", "grams", "mass", 0.001, 0, "oz"},
+	"t":  {"t", "tonne", "tonnes", "mass", 1000, 0, "ST"},
+	"lb": {"lb", "pound", "pounds", "mass", 0.45359237, 0, "kg"},
+	"oz": {"oz", "ounce", "ounces", "mass", 0.028349523125, 0, "g"},
+	"ST": {"short tons", "short ton", "short tons", "mass", 907.18474, 0, "t"},
+	"LT": {"long tons", "long ton", "long tons", "mass", 1016.0469088, 0, "t"},
+
+	// Kelvins are the base
+	"C": {"°C", "degree Celsius", "degrees Celsius", "temperature", 1, 273.15, "F"},
+	"F": {"°F", "degree Fahrenheit", "degrees Fahrenheit", "temperature", 5.0 / 9, 459.67 * 5 / 9, "C"},
+	"K": {"K", "kelvin", "kelvins", "temperature", 1, 0, "C"},
+
+	"km/h": {"km/h", "kilometre per hour", "kilometres per hour", "speed", 1 / 3.6, 0, "mph"},
+	"mph":  {"mph", "mile per hour", "miles per hour", "speed", 0.44704, 0, "km/h"},
+	"m/s":  {"m/s", "metre per second", "metres per second", "speed", 1, 0, "ft/s"},
+	"ft/s": {"ft/s", "foot per second", "feet per second", "speed", 0.3048, 0, "m/s"},
+	"kn":   {"kn", "knot", "knots", "speed", 1852 / 3600.0, 0, "km/h"},
+
+	"L":      {"L", "litre", "litres", "volume", 0.001, 0, "USgal"},
+	"m3":     {"m³", "cubic metre", "cubic metres", "volume", 1, 0, "cuft"},
+	"cuft":   {"cu ft", "cubic foot", "cubic feet", "volume", 0.028316846592, 0, "m3"},
+	"USgal":  {"US gal", "US gallon", "US gallons", "volume", 0.003785411784, 0, "L"},
+	"impgal": {"imp gal", "imperial gallon", "imperial gallons", "volume", 0.00454609, 0, "L"},
+
+	"W":  {"W", "watt", "watts", "power", 1, 0, "hp"},
+	"kW": {"kW", "kilowatt", "kilowatts", "power", 1000, 0, "hp"},
+	"hp": {"hp", "horsepower", "horsepower", "power", 745.69987158227, 0, "kW"},
+}
+
+// Other names units go by
+var convertAliases = map[string]string{
+	"sqkm": "km2", "acres": "acre", "sqm": "m2", "kmh": "km/h", "l": "L",
+	"°C": "C", "°F": "F", "feet": "ft", "foot": "ft", "miles": "mi", "mile": "mi",
+}
+
+func lookUpUnit(code string) (convertUnit, bool) {
+	if alias, ok := convertAliases[code]; ok {
+		code = alias
+	}
+	unit, ok := convertUnits[code]
+	return unit, ok
+}
+
+// Words between the values of a range, e.g. {{convert|5|to|10|km}}
+var convertRanges = map[string]string{
+	"-": "–", "–": "–", "to": " to ", "and": " and ", "or": " or ",
+	"+": " + ", "x": " × ", "by": " by ", "to(-)": " to ",
+}
+
+// An amount as given to {{convert}}
+type convertAmount struct {
+	// As written, and in the base unit
+	values []string
+	bases  []float64
+	// Range words between the values
+	ranges []string
+	unit   convertUnit
+	// Feet and inches and the like
+	parts []convertUnit
+}
+
+// Measurements like {{convert|5|km}}, shown as 5 kilometres (3.1 mi)
+func convertTemplate(p templateParams) []Node {
+	var args []string
+	for i := range p.positional {
+		args = append(args, p.text(i+1))
+	}
+	amount, rest, ok := parseConvertAmount(args)
+	if !ok {
+		return []Node{&Text{Value: strings.Join(args, " ")}}
+	}
+
+	targets := []string{amount.unit.to}
+	if len(rest) > 0 && rest[0] != "" {
+		targets = strings.Fields(rest[0])
+	}
+	decimals, hasDecimals := 0, false
+	if len(rest) > 1 {
+		if n, err := strconv.Atoi(rest[1]); err == nil {
+			decimals, hasDecimals = n, true
+		}
+	}
+	sigfig, _ := strconv.Atoi(p.option("sigfig"))
+
+	abbr := p.option("abbr")
+	if abbr == "" && p.key == "cvt" {
+		abbr = "on"
+	}
+	// Temperatures are written with their symbols unless asked not to
+	if abbr == "" && amount.unit.dimension == "temperature" {
+		abbr = "on"
+	}
+	adjective := p.option("adj") == "on"
+	input := amount.String(abbr == "on" || abbr == "in", adjective)
+
+	var outputs []string
+	for _, target := range targets {
+		if target == "ftin" && amount.unit.dimension == "length" {
+			outputs = append(outputs, amount.feetAndInches(abbr != "off" && abbr != "in"))
+			continue
+		}
+		unit, ok := lookUpUnit(target)
+		if !ok || unit.dimension != amount.unit.dimension {
+			continue
+		}
+		var values []string
+		for i, base := range amount.bases {
+			value := (base - unit.offset) / unit.factor
+			switch {
+			case hasDecimals:
+				values = append(values, formatDecimals(value, decimals))
+			case sigfig > 0:
+				values = append(values, formatSignificant(value, sigfig))
+			case unit.offset != 0 || amount.unit.offset != 0:
+				// Temperatures are as precise as they're given
+				values = append(values, formatDecimals(value, decimalPlaces(amount.values[i])))
+			case len(amount.parts) > 0:
+				// As precise as the smallest part, e.g. to the inch
+				last := len(amount.parts) - 1
+				smallest := amount.parts[last].factor * math.Pow(10, -float64(decimalPlaces(amount.values[last+1])))
+				values = append(values, formatDecimals(value, int(math.Ceil(-math.Log10(smallest/unit.factor)))))
+			default:
+				figures := max(2, significantFigures(amount.values[i]))
+				values = append(values, formatSignificant(value, figures))
+			}
+		}
+		out := convertAmount{values: values, ranges: amount.ranges, unit: unit}
+		outputs = append(outputs, out.String(abbr != "off" && abbr != "in", false))
+	}
+	if len(outputs) == 0 {
+		return []Node{&Text{Value: input}}
+	}
+	output := strings.Join(outputs, "; ")
+
+	var text string
+	switch p.option("disp") {
+	case "flip":
+		text = output + " (" + input + ")"
+	case "or":
+		text = input + " or " + output
+	case "output only", "out":
+		text = output
+	case "number":
+		text = strings.Fields(output)[0]
+	case "x", "comma":
+		text = input + ", " + output
+	default:
+		text = input + " (" + output + ")"
+	}
+	return []Node{&Text{Value: text}}
+}
+
+// Reads the values, range words and units the arguments start with,
+// returning the arguments after them
+func parseConvertAmount(args []string) (convertAmount, []string, bool) {
+	var amount convertAmount
+	i := 0
+	readValue := func() (float64, bool) {
+		if i >= len(args) {
+			return 0, false
+		}
+		value, err := strconv.ParseFloat(strings.ReplaceAll(args[i], ",", ""), 64)
+		if err != nil {
+			return 0, false
+		}
+		amount.values = append(amount.values, args[i])
+		i++
+		return value, true
+	}
+	first, ok := readValue()
+	if !ok {
+		return amount, nil, false
+	}
+	values := []float64{first}
+	for i+1 < len(args) {
+		word, ok := convertRanges[args[i]]
+		if !ok {
+			break
+		}
+		i++
+		value, ok := readValue()
+		if !ok {
+			return amount, nil, false
+		}
+		amount.ranges = append(amount.ranges, word)
+		values = append(values, value)
+	}
+	if i >= len(args) {
+		return amount, nil, false
+	}
+	unit, ok := lookUpUnit(args[i])
+	if !ok {
+		return amount, nil, false
+	}
+	i++
+	amount.unit = unit
+	for _, value := range values {
+		amount.bases = append(amount.bases, value*unit.factor+unit.offset)
+	}
+
+	// More parts of a single value, e.g. {{convert|6|ft|2|in}}
+	for len(values) == 1 && i+1 < len(args) {
+		part, ok := lookUpUnit(args[i+1])
+		if !ok || part.dimension != unit.dimension || part.offset != 0 {
+			break
+		}
+		value, err := strconv.ParseFloat(args[i], 64)
+		if err != nil {
+			break
+		}
+		amount.values = append(amount.values, args[i])
+		amount.parts = append(amount.parts, part)
+		amount.bases[0] += value * part.factor
+		i += 2
+	}
+	return amount, args[i:], true
+}
+
+// e.g. 5 to 10 kilometres, or 5-kilometre as an adjective
+func (a convertAmount) String(symbol bool, adjective bool) string {
+	unitName := func(u convertUnit, value string) string {
+		switch {
+		case symbol:
+			return " " + u.symbol
+		case adjective:
+			return "-" + u.name
+		case value == "1" && len(a.ranges) == 0:
+			return " " + u.name
+		}
+		return " " + u.plural
+	}
+	var b strings.Builder
+	if len(a.parts) > 0 {
+		// Values with several parts aren't ranges
+		b.WriteString(formatAmount(strings.ReplaceAll(a.values[0], ",", "")) + unitName(a.unit, a.values[0]))
+		for i, part := range a.parts {
+			b.WriteString(" " + a.values[i+1] + unitName(part, a.values[i+1]))
+		}
+		return b.String()
+	}
+	for i, value := range a.values {
+		if i > 0 {
+			b.WriteString(a.ranges[i-1])
+		}
+		b.WriteString(formatAmount(strings.ReplaceAll(value, ",", "")))
+	}
+	return b.String() + unitName(a.unit, a.values[len(a.values)-1])
+}
+
+// Lengths in whole feet and inches, e.g. 6 ft 2 in
+func (a convertAmount) feetAndInches(symbol bool) string {
+	var parts []string
+	for _, base := range a.bases {
+		inches := int(math.Round(base / 0.0254))
+		feet, inches := inches/12, inches%12
+		if symbol {
+			parts = append(parts, strconv.Itoa(feet)+" ft "+strconv.Itoa(inches)+" in")
+			continue
+		}
+		feetName, inchName := "feet", "inches"
+		if feet == 1 {
+			feetName = "foot"
+		}
+		if inches == 1 {
+			inchName = "inch"
+		}
+		parts = append(parts, strconv.Itoa(feet)+" "+feetName+" "+strconv.Itoa(inches)+" "+inchName)
+	}
+	out := parts[0]
+	for i, part := range parts[1:] {
+		out += a.ranges[i] + part
+	}
+	return out
+}
+
+// Digits after the decimal point
+func decimalPlaces(value string) int {
+	if _, fraction, ok := strings.Cut(value, "."); ok {
+		return len(fraction)
+	}
+	return 0
+}
+
+// Significant figures of a value as written, trailing zeros
+// of whole numbers left out
+func significantFigures(value string) int {
+	digits := strings.TrimLeft(strings.Map(func(c rune) rune {
+		if c >= '0' && c <= '9' {
+			return c
+		}
+		return -1
+	}, value), "0")
+	if !strings.Contains(value, ".") {
+		digits = strings.TrimRight(digits, "0")
+	}
+	return max(len(digits), 1)
+}
+
+// value with n decimals, or rounded to tens, hundreds... when n is negative
+func formatDecimals(value float64, n int) string {
+	if n < 0 {
+		scale := math.Pow(10, float64(-n))
+		value = math.Round(value/scale) * scale
+		n = 0
+	}
+	s := strconv.FormatFloat(value, 'f', n, 64)
+	if strings.Trim(s, "-0.") == "" {
+		s = strings.TrimPrefix(s, "-")
+	}
+	return formatAmount(s)
+}
+
+func formatSignificant(value float64, figures int) string {
+	if value == 0 {
+		return "0"
+	}
+	magnitude := int(math.Floor(math.Log10(math.Abs(value))))
+	return formatDecimals(value, figures-1-magnitude)
+}
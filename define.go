@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Lets the user pick one of the words in view to look up,
+// with the selected link's text first
+func (m *model) openDefine() {
+	if m.dictionary == nil {
+		m.info = "Words can't be looked up offline"
+		return
+	}
+	if m.rendering == nil {
+		return
+	}
+	var words []string
+	if m.focusedLink > 0 && m.rendering.Links[m.focusedLink-1].Citation == 0 {
+		words = append(words, m.linkText(m.focusedLink))
+	}
+	for _, word := range m.wordsInView() {
+		if len(words) == 0 || !strings.EqualFold(word, words[0]) {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		m.info = "No words in view"
+		return
+	}
+	m.popup = newFilterPopup("Define", "word", words)
+	m.pageName = "define"
+}
+
+// Text of the nth (1-based) link as shown
+func (m model) linkText(link int) string {
+	var b strings.Builder
+	for _, line := range m.rendering.Lines {
+		for _, seg := range line {
+			if seg.link == link {
+				b.WriteString(seg.text)
+			}
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// The words on the lines in view, each once, in order
+func (m model) wordsInView() []string {
+	top := min(m.viewport.YOffset, len(m.rendering.Lines))
+	bottom := min(top+m.viewport.Height, len(m.rendering.Lines))
+	var words []string
+	seen := map[string]bool{}
+	for _, line := range m.rendering.Lines[top:bottom] {
+		var text strings.Builder
+		for _, seg := range line {
+			text.WriteString(seg.text)
+		}
+		split := strings.FieldsFunc(text.String(), func(c rune) bool {
+			return !unicode.IsLetter(c) && c != '-' && c != '\''
+		})
+		for _, word := range split {
+			word = strings.Trim(word, "-'")
+			if key := strings.ToLower(word); word != "" && !seen[key] {
+				seen[key] = true
+				words = append(words, word)
+			}
+		}
+	}
+	return words
+}
+
+// Looks word up, preferring the entry for the language being read
+func (m *model) defineCmd(word string) tea.Cmd {
+	m.info = fmt.Sprintf("Looking up %s...", word)
+	src, lang := m.dictionary, WikipediaLangs[m.source.Language()]
+	return func() tea.Msg {
+		dictionary, err := lookUp(context.Background(), src, word, lang)
+		return definitionMsg{dictionary: dictionary, err: err}
+	}
+}
+
+type definitionMsg struct {
+	dictionary *Dictionary
+	err        error
+}
+
+// Shows a looked up word's card in a popup
+func (m *model) showDefinition(msg definitionMsg) {
+	if msg.err != nil {
+		m.info = errorInfo(msg.err, m.dictionary.SiteName())
+		return
+	}
+	m.info = ""
+	m.definition = Render(msg.dictionary.Document(), definitionWidth(m.width))
+	m.definitionTop = 0
+	m.pageName = "definition"
+}
+
+func definitionWidth(width int) int {
+	return max(min(width-10, 72), 10)
+}
+
+// Rows of the card that fit in the popup
+func (m model) definitionRows() int {
+	return max(m.height-8, 1)
+}
+
+func DefinitionView(m model) string {
+	lines := strings.Split(m.definition.String(), "\n")
+	end := min(m.definitionTop+m.definitionRows(), len(lines))
+	card := lipgloss.NewStyle().Width(definitionWidth(m.width)).Render(strings.Join(lines[m.definitionTop:end], "\n"))
+	hint := "esc to close"
+	if len(lines) > m.definitionRows() {
+		hint = "↑/↓ to scroll, " + hint
+	}
+	s := popupStyle.Render(card + "\n\n" + noteStyle(hint))
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, s)
+}
+
+func DefinitionUpdate(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "enter", "q":
+		m.pageName = "article"
+	case "up", "k":
+		m.definitionTop = max(m.definitionTop-1, 0)
+	case "down", "j":
+		last := max(len(m.definition.Lines)-m.definitionRows(), 0)
+		m.definitionTop = min(m.definitionTop+1, last)
+	}
+	return m, nil
+}
+
+// The popup of words in view to look up
+func DefineView(m model) string {
+	return m.popup.view(m.width, m.height)
+}
+
+func DefineUpdate(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.pageName = "article"
+	case tea.KeyUp:
+		m.popup.move(-1)
+	case tea.KeyDown:
+		m.popup.move(1)
+	case tea.KeyEnter:
+		m.pageName = "article"
+		if i, ok := m.popup.selected(); ok {
+			cmd := m.defineCmd(m.popup.items[i])
+			return m, cmd
+		}
+	default:
+		return m, m.popup.updateFilter(msg)
+	}
+	return m, nil
+}
@@ -0,0 +1,1010 @@
+package main
+
+// Reading Wikipedia offline from an index built out of a MediaWiki
+// XML dump, see https://meta.wikimedia.org/wiki/Data_dumps
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Files making up a dump index
+const (
+	// What the dump is of, as JSON
+	dumpInfoFile = "info.json"
+	// Each article's wikitext as a zstd frame of its own
+	dumpTextFile = "text.zst"
+	// Title records sorted by their lowercase title
+	dumpTitlesFile = "titles"
+	// Where each title record starts, 8 bytes each
+	dumpOffsetsFile = "titles.idx"
+	// Word records sorted by word, listing the numbers of the
+	// articles using it, counting from 0 in the dump's order
+	dumpWordsFile = "words"
+	// Where each word record starts, 8 bytes each
+	dumpWordOffsetsFile = "words.idx"
+	// Which title record each article's is, by number, 4 bytes each
+	dumpPagesFile = "pages"
+)
+
+var ErrNotDumpIndex = errors.New("not a dump index, make one with wki index")
+
+// What a dump index is of
+type dumpInfo struct {
+	// Site name, e.g. Wikipedia
+	Name string `json:"name"`
+	Lang string `json:"lang"`
+	// Article URLs are this followed by the title, e.g.
+	// https://en.wikipedia.org/wiki/
+	ArticleUrl string `json:"article_url"`
+	Articles   int    `json:"articles"`
+	FullText   bool   `json:"full_text"`
+}
+
+// A page of the dump as indexed: either an article's
+// place in the text file or the title it redirects to
+type dumpRecord struct {
+	title    string
+	redirect string
+	offset   uint64
+	length   uint64
+}
+
+// Titles are looked up without regard to case
+func dumpKey(title string) string {
+	return strings.ToLower(strings.TrimSpace(strings.ReplaceAll(title, "_", " ")))
+}
+
+func (r dumpRecord) append(b []byte) []byte {
+	for _, s := range []string{dumpKey(r.title), r.title, r.redirect} {
+		b = binary.AppendUvarint(b, uint64(len(s)))
+		b = append(b, s...)
+	}
+	b = binary.AppendUvarint(b, r.offset)
+	return binary.AppendUvarint(b, r.length)
+}
+
+// Reads a record written by append, returning its key too
+func readDumpRecord(r io.ByteReader) (string, dumpRecord, error) {
+	var fields [3]string
+	for i := range fields {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return "", dumpRecord{}, err
+		}
+		s := make([]byte, n)
+		for j := range s {
+			if s[j], err = r.ReadByte(); err != nil {
+				return "", dumpRecord{}, err
+			}
+		}
+		fields[i] = string(s)
+	}
+	record := dumpRecord{title: fields[1], redirect: fields[2]}
+	var err error
+	if record.offset, err = binary.ReadUvarint(r); err != nil {
+		return "", record, err
+	}
+	record.length, err = binary.ReadUvarint(r)
+	return fields[0], record, err
+}
+
+// Lowercase words of wikitext for the full-text index
+func dumpWords(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	slices.Sort(words)
+	words = slices.Compact(words)
+	// Long runs are URLs and the like more than words
+	return slices.DeleteFunc(words, func(w string) bool {
+		return len(w) > 32
+	})
+}
+
+// The page elements of a dump that matter here
+type dumpPage struct {
+	Title    string `xml:"title"`
+	NS       int    `xml:"ns"`
+	Redirect struct {
+		Title string `xml:"title,attr"`
+	} `xml:"redirect"`
+	Text string `xml:"revision>text"`
+}
+
+// How much of an index is sorted in memory at a time. The rest waits
+// on disk in sorted runs, which are merged once the dump is read.
+var (
+	// Title records per run
+	dumpRunTitles = 1 << 18
+	// Article numbers listed under words per run
+	dumpRunPostings = 1 << 24
+)
+
+// Builds an index in dir of the articles in a dump, read from r. With
+// fullText, it also indexes the words of each article, which takes a
+// lot more room and time. Titles and words are sorted a run at a
+// time, so memory doesn't grow with the dump. Reports how many
+// articles were indexed.
+func buildDumpIndex(ctx context.Context, r io.Reader, dir string, fullText bool) (int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	// The index isn't one until it's finished
+	if err := os.Remove(filepath.Join(dir, dumpInfoFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	textFile, err := os.Create(filepath.Join(dir, dumpTextFile))
+	if err != nil {
+		return 0, err
+	}
+	defer textFile.Close()
+	text := bufio.NewWriter(textFile)
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return 0, err
+	}
+	defer encoder.Close()
+
+	info := dumpInfo{FullText: fullText}
+	titles := &dumpRuns{dir: dir, name: dumpTitlesFile}
+	defer titles.remove()
+	words := newWordIndex(dir)
+	defer words.runs.remove()
+	var offset uint64
+	var frame []byte
+
+	decoder := xml.NewDecoder(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrNotDumpIndex, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "mediawiki":
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "lang" {
+					info.Lang = attr.Value
+				}
+			}
+		case "sitename":
+			if err := decoder.DecodeElement(&info.Name, &start); err != nil {
+				return 0, err
+			}
+		case "base":
+			// The main page's URL, e.g. https://en.wikipedia.org/wiki/Main_Page
+			var base string
+			if err := decoder.DecodeElement(&base, &start); err != nil {
+				return 0, err
+			}
+			if i := strings.LastIndex(base, "/"); i >= 0 {
+				info.ArticleUrl = base[:i+1]
+			}
+		case "page":
+			var page dumpPage
+			if err := decoder.DecodeElement(&page, &start); err != nil {
+				return 0, err
+			}
+			// Only articles, not talk, user or other pages
+			if page.NS != 0 || page.Title == "" {
+				continue
+			}
+			record := dumpRecord{title: page.Title, redirect: page.Redirect.Title}
+			// Runs keep each article's number, counting from 1,
+			// to find its title from the words that it has
+			var number uint64
+			if record.redirect == "" {
+				frame = encoder.EncodeAll([]byte(page.Text), frame[:0])
+				if _, err := text.Write(frame); err != nil {
+					return 0, err
+				}
+				record.offset, record.length = offset, uint64(len(frame))
+				offset += uint64(len(frame))
+				info.Articles++
+				number = uint64(info.Articles)
+				if fullText {
+					if err := words.add(uint32(info.Articles-1), dumpWords(page.Text)); err != nil {
+						return 0, err
+					}
+				}
+			}
+			titles.add(dumpKey(record.title), record.append(binary.AppendUvarint(nil, number)))
+			if len(titles.batch) >= dumpRunTitles {
+				if err := titles.spill(); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+	if err := text.Flush(); err != nil {
+		return 0, err
+	}
+
+	if err := writeDumpTitles(dir, titles, fullText); err != nil {
+		return 0, err
+	}
+	if fullText {
+		if err := words.write(dir); err != nil {
+			return 0, err
+		}
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	return info.Articles, os.WriteFile(filepath.Join(dir, dumpInfoFile), data, 0o644)
+}
+
+// Writes the title records in order, and with fullText
+// where each article's record is by its number
+func writeDumpTitles(dir string, titles *dumpRuns, fullText bool) error {
+	table, err := createDumpTable(dir, dumpTitlesFile, dumpOffsetsFile)
+	if err != nil {
+		return err
+	}
+	var pages *os.File
+	if fullText {
+		if pages, err = os.Create(filepath.Join(dir, dumpPagesFile)); err != nil {
+			table.close()
+			return err
+		}
+	}
+	var i uint32
+	err = titles.merge(func(key string, value []byte) error {
+		r := bytes.NewReader(value)
+		number, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		if pages != nil && number > 0 {
+			if _, err := pages.WriteAt(binary.LittleEndian.AppendUint32(nil, i), int64(number-1)*4); err != nil {
+				return err
+			}
+		}
+		i++
+		return table.write(value[len(value)-r.Len():])
+	})
+	if closeErr := table.close(); err == nil {
+		err = closeErr
+	}
+	if pages != nil {
+		if closeErr := pages.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// wordIndex builds the words table of a full-text index,
+// listing under each word the numbers of the articles having it
+type wordIndex struct {
+	runs     dumpRuns
+	postings map[string][]uint32
+	count    int
+}
+
+func newWordIndex(dir string) *wordIndex {
+	return &wordIndex{runs: dumpRuns{dir: dir, name: dumpWordsFile}, postings: map[string][]uint32{}}
+}
+
+// Adds article n's words
+func (w *wordIndex) add(n uint32, words []string) error {
+	for _, word := range words {
+		w.postings[word] = append(w.postings[word], n)
+	}
+	w.count += len(words)
+	if w.count < dumpRunPostings {
+		return nil
+	}
+	return w.spill()
+}
+
+// Moves the postings held in memory to a run of their own
+func (w *wordIndex) spill() error {
+	for word, numbers := range w.postings {
+		slices.Sort(numbers)
+		w.runs.add(word, appendPostings(nil, numbers))
+	}
+	clear(w.postings)
+	w.count = 0
+	return w.runs.spill()
+}
+
+// Writes the words table in dir, joining up each word's
+// articles from the runs they're spread over
+func (w *wordIndex) write(dir string) error {
+	if err := w.spill(); err != nil {
+		return err
+	}
+	table, err := createDumpTable(dir, dumpWordsFile, dumpWordOffsetsFile)
+	if err != nil {
+		return err
+	}
+	var word string
+	var numbers []uint32
+	flush := func() error {
+		if len(numbers) == 0 {
+			return nil
+		}
+		// Articles added out of order end up in runs out of order
+		slices.Sort(numbers)
+		b := binary.AppendUvarint(nil, uint64(len(word)))
+		b = append(b, word...)
+		return table.write(appendPostings(b, numbers))
+	}
+	err = w.runs.merge(func(key string, value []byte) error {
+		if key != word {
+			if err := flush(); err != nil {
+				return err
+			}
+			word, numbers = key, numbers[:0]
+		}
+		more, err := readPostings(bytes.NewReader(value))
+		numbers = append(numbers, more...)
+		return err
+	})
+	if err == nil {
+		err = flush()
+	}
+	if closeErr := table.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Appends a count of article numbers, then each as
+// the difference from the one before, which stays small
+func appendPostings(b []byte, numbers []uint32) []byte {
+	b = binary.AppendUvarint(b, uint64(len(numbers)))
+	var last uint32
+	for _, n := range numbers {
+		b = binary.AppendUvarint(b, uint64(n-last))
+		last = n
+	}
+	return b
+}
+
+// Reads article numbers written by appendPostings
+func readPostings(r io.ByteReader) ([]uint32, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]uint32, 0, count)
+	var last uint32
+	for range count {
+		delta, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		last += uint32(delta)
+		numbers = append(numbers, last)
+	}
+	return numbers, nil
+}
+
+// A value to sort by its key
+type dumpEntry struct {
+	key   string
+	value []byte
+}
+
+// dumpRuns sorts more entries than fit in memory. Each batch of
+// them is sorted into a run file of its own in dir, and the runs
+// are merged at the end.
+type dumpRuns struct {
+	dir string
+	// What the entries are of, to name the runs after
+	name  string
+	batch []dumpEntry
+	paths []string
+}
+
+func (s *dumpRuns) add(key string, value []byte) {
+	s.batch = append(s.batch, dumpEntry{key: key, value: value})
+}
+
+// Writes the batch to a run, sorted by key
+func (s *dumpRuns) spill() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	slices.SortStableFunc(s.batch, func(a, b dumpEntry) int {
+		return strings.Compare(a.key, b.key)
+	})
+	file, err := os.CreateTemp(s.dir, s.name+"-*.run")
+	if err != nil {
+		return err
+	}
+	s.paths = append(s.paths, file.Name())
+	w := bufio.NewWriter(file)
+	var b []byte
+	for _, entry := range s.batch {
+		b = binary.AppendUvarint(b[:0], uint64(len(entry.key)))
+		b = append(b, entry.key...)
+		b = binary.AppendUvarint(b, uint64(len(entry.value)))
+		b = append(b, entry.value...)
+		if _, err := w.Write(b); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	clear(s.batch)
+	s.batch = s.batch[:0]
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Calls emit with every entry in order of key, and entries
+// with the same key in the order they were added
+func (s *dumpRuns) merge(emit func(key string, value []byte) error) error {
+	if err := s.spill(); err != nil {
+		return err
+	}
+	var runs dumpRunHeap
+	for i, path := range s.paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		run := &dumpRun{r: bufio.NewReader(file), n: i}
+		if ok, err := run.next(); err != nil {
+			return err
+		} else if ok {
+			runs = append(runs, run)
+		}
+	}
+	heap.Init(&runs)
+	for len(runs) > 0 {
+		run := runs[0]
+		if err := emit(run.key, run.value); err != nil {
+			return err
+		}
+		ok, err := run.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&runs, 0)
+		} else {
+			heap.Pop(&runs)
+		}
+	}
+	return nil
+}
+
+// Deletes the runs
+func (s *dumpRuns) remove() {
+	for _, path := range s.paths {
+		os.Remove(path)
+	}
+	s.paths = nil
+}
+
+// A run being merged, at its next entry
+type dumpRun struct {
+	r *bufio.Reader
+	// Runs written earlier go first among equal keys
+	n     int
+	key   string
+	value []byte
+}
+
+// Reads the run's next entry, reporting false at its end
+func (r *dumpRun) next() (bool, error) {
+	key, err := readDumpBytes(r.r)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if r.value, err = readDumpBytes(r.r); err != nil {
+		return false, err
+	}
+	r.key = string(key)
+	return true, nil
+}
+
+// Runs ordered by their next entry, for container/heap
+type dumpRunHeap []*dumpRun
+
+func (h dumpRunHeap) Len() int { return len(h) }
+func (h dumpRunHeap) Less(i, j int) bool {
+	return h[i].key < h[j].key || h[i].key == h[j].key && h[i].n < h[j].n
+}
+func (h dumpRunHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *dumpRunHeap) Push(x any)   { *h = append(*h, x.(*dumpRun)) }
+func (h *dumpRunHeap) Pop() any {
+	old := *h
+	run := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return run
+}
+
+// dumpTable writes records to one file and where
+// each starts to another, 8 bytes each
+type dumpTable struct {
+	dataFile, offsetsFile *os.File
+	data, offsets         *bufio.Writer
+	size                  uint64
+}
+
+func createDumpTable(dir string, name string, offsetsName string) (*dumpTable, error) {
+	dataFile, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	offsetsFile, err := os.Create(filepath.Join(dir, offsetsName))
+	if err != nil {
+		dataFile.Close()
+		return nil, err
+	}
+	return &dumpTable{
+		dataFile:    dataFile,
+		offsetsFile: offsetsFile,
+		data:        bufio.NewWriter(dataFile),
+		offsets:     bufio.NewWriter(offsetsFile),
+	}, nil
+}
+
+func (t *dumpTable) write(record []byte) error {
+	if _, err := t.offsets.Write(binary.LittleEndian.AppendUint64(nil, t.size)); err != nil {
+		return err
+	}
+	if _, err := t.data.Write(record); err != nil {
+		return err
+	}
+	t.size += uint64(len(record))
+	return nil
+}
+
+// Flushes and closes both files
+func (t *dumpTable) close() error {
+	errs := []error{t.data.Flush(), t.offsets.Flush(), t.dataFile.Close(), t.offsetsFile.Close()}
+	return errors.Join(errs...)
+}
+
+// Reads a dump from path, decompressing .bz2 dumps on the way
+func openDump(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".bz2") {
+		return file, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{bufio.NewReader(bzip2.NewReader(bufio.NewReader(file))), file}, nil
+}
+
+// Where wki index puts the index of a dump by default,
+// e.g. enwiki-latest-pages-articles.xml.bz2 goes in
+// enwiki-latest-pages-articles, and an archive.zim in archive.zim.index
+func defaultIndexDir(dumpPath string) string {
+	dir := strings.TrimSuffix(dumpPath, ".bz2")
+	dir = strings.TrimSuffix(dir, ".xml")
+	if dir == dumpPath {
+		dir += ".index"
+	}
+	return dir
+}
+
+// dumpIndex reads searches and articles from an index made
+// by wki index, the same way Client does from a Wikipedia's API
+type dumpIndex struct {
+	info dumpInfo
+	// Open index files by name
+	files map[string]*os.File
+	// Count of title records
+	titles int
+	// Words of the articles, with a full-text index
+	words *wordTable
+
+	decoder *zstd.Decoder
+}
+
+// Opens the dump index in dir
+func openDumpIndex(dir string) (*dumpIndex, error) {
+	data, err := os.ReadFile(filepath.Join(dir, dumpInfoFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNotDumpIndex)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := &dumpIndex{files: map[string]*os.File{}}
+	if err := json.Unmarshal(data, &d.info); err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNotDumpIndex)
+	}
+	names := []string{dumpTextFile, dumpTitlesFile, dumpOffsetsFile}
+	if d.info.FullText {
+		names = append(names, dumpPagesFile)
+	}
+	for _, name := range names {
+		file, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			d.Close()
+			return nil, err
+		}
+		d.files[name] = file
+	}
+	if d.titles, err = tableCount(d.files[dumpOffsetsFile]); err != nil {
+		d.Close()
+		return nil, err
+	}
+	if d.info.FullText {
+		if d.words, err = openWordTable(dir); err != nil {
+			d.Close()
+			return nil, err
+		}
+	}
+	if d.decoder, err = zstd.NewReader(nil); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *dumpIndex) Close() error {
+	for _, file := range d.files {
+		file.Close()
+	}
+	if d.words != nil {
+		d.words.Close()
+	}
+	if d.decoder != nil {
+		d.decoder.Close()
+	}
+	return nil
+}
+
+// Number of records in a table from the size of its offsets
+func tableCount(offsets *os.File) (int, error) {
+	info, err := offsets.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return int(info.Size() / 8), nil
+}
+
+// A reader for record i of a table
+func tableRecord(data *os.File, offsets *os.File, i int) (*bufio.Reader, error) {
+	buf := make([]byte, 8)
+	if _, err := offsets.ReadAt(buf, int64(i)*8); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotDumpIndex, err)
+	}
+	offset := int64(binary.LittleEndian.Uint64(buf))
+	return bufio.NewReaderSize(io.NewSectionReader(data, offset, 1<<62), 256), nil
+}
+
+func (d *dumpIndex) title(i int) (string, dumpRecord, error) {
+	r, err := tableRecord(d.files[dumpTitlesFile], d.files[dumpOffsetsFile], i)
+	if err != nil {
+		return "", dumpRecord{}, err
+	}
+	return readDumpRecord(r)
+}
+
+// Index of the first title record whose key sorts at or after key
+func (d *dumpIndex) searchKey(key string) int {
+	return sort.Search(d.titles, func(i int) bool {
+		k, _, err := d.title(i)
+		return err != nil || k >= key
+	})
+}
+
+// wordTable reads the words table of a full-text index
+type wordTable struct {
+	data, offsets *os.File
+	// Count of word records
+	n int
+}
+
+// Opens the words table in dir
+func openWordTable(dir string) (*wordTable, error) {
+	data, err := os.Open(filepath.Join(dir, dumpWordsFile))
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := os.Open(filepath.Join(dir, dumpWordOffsetsFile))
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	w := &wordTable{data: data, offsets: offsets}
+	if w.n, err = tableCount(offsets); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *wordTable) Close() error {
+	return errors.Join(w.data.Close(), w.offsets.Close())
+}
+
+// Numbers of the articles whose text has word
+func (w *wordTable) postings(word string) ([]uint32, error) {
+	i := sort.Search(w.n, func(i int) bool {
+		r, err := tableRecord(w.data, w.offsets, i)
+		if err != nil {
+			return true
+		}
+		found, _ := readDumpWord(r)
+		return found >= word
+	})
+	if i == w.n {
+		return nil, nil
+	}
+	r, err := tableRecord(w.data, w.offsets, i)
+	if err != nil {
+		return nil, err
+	}
+	if found, err := readDumpWord(r); err != nil || found != word {
+		return nil, err
+	}
+	return readPostings(r)
+}
+
+// Numbers of the articles having every word of query
+func (w *wordTable) search(query string) ([]uint32, error) {
+	var matches []uint32
+	for i, word := range dumpWords(query) {
+		numbers, err := w.postings(word)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			matches = numbers
+			continue
+		}
+		matches = slices.DeleteFunc(matches, func(n uint32) bool {
+			_, found := slices.BinarySearch(numbers, n)
+			return !found
+		})
+	}
+	return matches, nil
+}
+
+// Index of the title record of article n
+func (d *dumpIndex) page(n uint32) (int, error) {
+	buf := make([]byte, 4)
+	if _, err := d.files[dumpPagesFile].ReadAt(buf, int64(n)*4); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrNotDumpIndex, err)
+	}
+	return int(binary.LittleEndian.Uint32(buf)), nil
+}
+
+func readDumpWord(r *bufio.Reader) (string, error) {
+	word, err := readDumpBytes(r)
+	return string(word), err
+}
+
+// Reads bytes written after their length
+func readDumpBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Searches titles starting with query, then, with a full-text
+// index, the articles having every word of the query
+func (d *dumpIndex) Search(ctx context.Context, query string, limit int, offset int) ([]SearchResult, error) {
+	key := dumpKey(query)
+	if key == "" {
+		return nil, nil
+	}
+	var results []SearchResult
+	seen := map[string]bool{}
+	skipped := 0
+	add := func(record dumpRecord) {
+		if seen[record.title] || len(results) >= limit {
+			return
+		}
+		seen[record.title] = true
+		if skipped < offset {
+			skipped++
+			return
+		}
+		results = append(results, SearchResult{Title: record.title, Url: d.articleUrl(record.title)})
+	}
+
+	for i := d.searchKey(key); i < d.titles && len(results) < limit; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		k, record, err := d.title(i)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(k, key) {
+			break
+		}
+		add(record)
+	}
+
+	if d.words == nil || len(results) >= limit {
+		return results, nil
+	}
+	matches, err := d.words.search(query)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range matches {
+		if len(results) >= limit {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		i, err := d.page(id)
+		if err != nil {
+			return nil, err
+		}
+		_, record, err := d.title(i)
+		if err != nil {
+			return nil, err
+		}
+		add(record)
+	}
+	return results, nil
+}
+
+func (d *dumpIndex) articleUrl(title string) string {
+	if d.info.ArticleUrl == "" {
+		return ""
+	}
+	return d.info.ArticleUrl + strings.ReplaceAll(title, " ", "_")
+}
+
+// The record for title, preferring one matching its case
+func (d *dumpIndex) find(title string) (dumpRecord, bool, error) {
+	key := dumpKey(title)
+	var found *dumpRecord
+	for i := d.searchKey(key); i < d.titles; i++ {
+		k, record, err := d.title(i)
+		if err != nil {
+			return record, false, err
+		}
+		if k != key {
+			break
+		}
+		if found == nil || record.title == title {
+			found = &record
+		}
+	}
+	if found == nil {
+		return dumpRecord{}, false, nil
+	}
+	return *found, true, nil
+}
+
+// Loads an article by title, following redirects
+func (d *dumpIndex) LoadArticle(ctx context.Context, article Article) (Article, error) {
+	title, _, _ := strings.Cut(article.Title, "#")
+	title = strings.TrimSpace(strings.ReplaceAll(title, "_", " "))
+	var record dumpRecord
+	for range maxRedirects + 1 {
+		var found bool
+		var err error
+		if record, found, err = d.find(title); err != nil {
+			return article, err
+		}
+		if !found {
+			return article, fmt.Errorf("%w: %q", ErrNotFound, article.Title)
+		}
+		if record.redirect == "" {
+			break
+		}
+		title, _, _ = strings.Cut(record.redirect, "#")
+	}
+	if record.redirect != "" {
+		return article, fmt.Errorf("%w: too many redirects from %q", ErrNotFound, article.Title)
+	}
+	if err := ctx.Err(); err != nil {
+		return article, err
+	}
+
+	frame := make([]byte, record.length)
+	if _, err := d.files[dumpTextFile].ReadAt(frame, int64(record.offset)); err != nil {
+		return article, fmt.Errorf("%w: %v", ErrNotDumpIndex, err)
+	}
+	wikitext, err := d.decoder.DecodeAll(frame, nil)
+	if err != nil {
+		return article, fmt.Errorf("%w: %v", ErrNotDumpIndex, err)
+	}
+
+	article.Title = record.title
+	article.Url = d.articleUrl(record.title)
+	article.Lang = d.Language()
+	article.LangLinks = nil
+	article.Document = ParseWikitext(string(wikitext))
+	return article, nil
+}
+
+// Picks up to n articles at random, skipping redirects
+func (d *dumpIndex) Random(ctx context.Context, n int) ([]string, error) {
+	var titles []string
+	for tries := 0; len(titles) < n && tries < 100*n && d.titles > 0; tries++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		_, record, err := d.title(rand.IntN(d.titles))
+		if err != nil {
+			return nil, err
+		}
+		if record.redirect == "" {
+			titles = append(titles, record.title)
+		}
+	}
+	return titles, nil
+}
+
+// Dumps don't keep links to other languages
+func (d *dumpIndex) LangLinks(ctx context.Context, title string) ([]LangLink, error) {
+	return nil, nil
+}
+
+func (d *dumpIndex) Facts(ctx context.Context, title string) ([]Fact, error) {
+	return nil, nil
+}
+
+func (d *dumpIndex) Language() string {
+	return d.info.Lang
+}
+
+func (d *dumpIndex) SiteName() string {
+	if lang, ok := WikipediaLangs[d.info.Lang]; ok && d.info.Name != "" {
+		return lang + " " + d.info.Name
+	}
+	if d.info.Name != "" {
+		return d.info.Name
+	}
+	return "dump"
+}
+
+func (d *dumpIndex) InLang(lang string) (Source, error) {
+	return nil, fmt.Errorf("%w: %s", ErrOneLanguage, d.info.Lang)
+}
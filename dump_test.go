@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDump = `<mediawiki xmlns="http://www.mediawiki.org/xml/export-0.11/" xml:lang="en">
+  <siteinfo>
+    <sitename>Wikipedia</sitename>
+    <base>https://en.wikipedia.org/wiki/Main_Page</base>
+  </siteinfo>
+  <page>
+    <title>Giraffe</title>
+    <ns>0</ns>
+    <revision><text xml:space="preserve">The '''giraffe''' is a tall [[mammal]] of the savanna.</text></revision>
+  </page>
+  <page>
+    <title>Giraffe family</title>
+    <ns>0</ns>
+    <revision><text xml:space="preserve">Giraffes and the okapi.</text></revision>
+  </page>
+  <page>
+    <title>Camelopard</title>
+    <ns>0</ns>
+    <redirect title="Giraffe" />
+    <revision><text xml:space="preserve">#REDIRECT [[Giraffe]]</text></revision>
+  </page>
+  <page>
+    <title>Okapi</title>
+    <ns>0</ns>
+    <revision><text xml:space="preserve">A forest relative of the giraffe.</text></revision>
+  </page>
+  <page>
+    <title>Talk:Giraffe</title>
+    <ns>1</ns>
+    <revision><text xml:space="preserve">Chat.</text></revision>
+  </page>
+</mediawiki>`
+
+// Indexes testDump with wki index and opens the index
+func testDumpIndex(t *testing.T, args ...string) *dumpIndex {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testwiki-pages-articles.xml")
+	if err := os.WriteFile(path, []byte(testDump), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := runIndex(context.Background(), nil, append(args, path), &stdout, &stderr); code != exitOK {
+		t.Fatalf("runIndex() = %d, stderr %q", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Indexed 3 articles") {
+		t.Fatalf("runIndex() stdout = %q", stdout.String())
+	}
+	index, err := openDumpIndex(filepath.Join(dir, "testwiki-pages-articles"))
+	if err != nil {
+		t.Fatalf("openDumpIndex() error = %v", err)
+	}
+	t.Cleanup(func() { index.Close() })
+	return index
+}
+
+func TestDumpSearch(t *testing.T) {
+	titles := testDumpIndex(t)
+	fullText := testDumpIndex(t, "--full-text")
+	tests := map[string]struct {
+		index  *dumpIndex
+		query  string
+		limit  int
+		offset int
+		titles []string
+	}{
+		"title prefix": {
+			index:  titles,
+			query:  "giraffe",
+			limit:  10,
+			titles: []string{"Giraffe", "Giraffe family"},
+		},
+		"paged": {
+			index:  titles,
+			query:  "Gir",
+			limit:  1,
+			offset: 1,
+			titles: []string{"Giraffe family"},
+		},
+		"titles only": {
+			index: titles,
+			query: "savanna",
+			limit: 10,
+		},
+		"full text": {
+			index:  fullText,
+			query:  "savanna",
+			limit:  10,
+			titles: []string{"Giraffe"},
+		},
+		"titles then full text": {
+			index:  fullText,
+			query:  "giraffe",
+			limit:  10,
+			titles: []string{"Giraffe", "Giraffe family", "Okapi"},
+		},
+		"every word": {
+			index:  fullText,
+			query:  "forest giraffe",
+			limit:  10,
+			titles: []string{"Okapi"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			results, err := test.index.Search(context.Background(), test.query, test.limit, test.offset)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			var titles []string
+			for _, result := range results {
+				titles = append(titles, result.Title)
+			}
+			if !reflect.DeepEqual(titles, test.titles) {
+				t.Fatalf("Search() titles = %q, expected %q", titles, test.titles)
+			}
+		})
+	}
+}
+
+// Sorting the index a run at a time gives the same searches
+func TestDumpIndexRuns(t *testing.T) {
+	titles, postings := dumpRunTitles, dumpRunPostings
+	dumpRunTitles, dumpRunPostings = 1, 1
+	t.Cleanup(func() { dumpRunTitles, dumpRunPostings = titles, postings })
+
+	dir := t.TempDir()
+	if _, err := buildDumpIndex(context.Background(), strings.NewReader(testDump), dir, true); err != nil {
+		t.Fatalf("buildDumpIndex() error = %v", err)
+	}
+	if runs, _ := filepath.Glob(filepath.Join(dir, "*.run")); len(runs) > 0 {
+		t.Fatalf("buildDumpIndex() left runs %q", runs)
+	}
+	index, err := openDumpIndex(dir)
+	if err != nil {
+		t.Fatalf("openDumpIndex() error = %v", err)
+	}
+	defer index.Close()
+
+	tests := map[string][]string{
+		"giraffe":        {"Giraffe", "Giraffe family", "Okapi"},
+		"camel":          {"Camelopard"},
+		"forest giraffe": {"Okapi"},
+		"the":            {"Giraffe", "Giraffe family", "Okapi"},
+	}
+	for query, expected := range tests {
+		results, err := index.Search(context.Background(), query, 10, 0)
+		if err != nil {
+			t.Fatalf("Search(%q) error = %v", query, err)
+		}
+		var got []string
+		for _, result := range results {
+			got = append(got, result.Title)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Search(%q) titles = %q, expected %q", query, got, expected)
+		}
+	}
+}
+
+func TestDumpIndexRebuildFails(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := buildDumpIndex(context.Background(), strings.NewReader(testDump), dir, false); err != nil {
+		t.Fatalf("buildDumpIndex() error = %v", err)
+	}
+	// Cut off part way, so the old index's files are half rewritten
+	broken := testDump[:len(testDump)/2]
+	if _, err := buildDumpIndex(context.Background(), strings.NewReader(broken), dir, false); err == nil {
+		t.Fatalf("buildDumpIndex() of a broken dump didn't fail")
+	}
+	if _, err := openDumpIndex(dir); !errors.Is(err, ErrNotDumpIndex) {
+		t.Fatalf("openDumpIndex() after a failed rebuild error = %v, expected ErrNotDumpIndex", err)
+	}
+}
+
+func TestDumpLoadArticle(t *testing.T) {
+	index := testDumpIndex(t)
+	tests := map[string]struct {
+		title    string
+		expected string
+		content  string
+		err      error
+	}{
+		"by title": {
+			title:    "Giraffe family",
+			expected: "Giraffe family",
+			content:  "Giraffes and the okapi.",
+		},
+		"any case": {
+			title:    "giraffe_Family",
+			expected: "Giraffe family",
+			content:  "Giraffes and the okapi.",
+		},
+		"redirect": {
+			title:    "Camelopard",
+			expected: "Giraffe",
+			content:  "is a tall mammal of the savanna.",
+		},
+		"not an article": {
+			title: "Talk:Giraffe",
+			err:   ErrNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			article, err := index.LoadArticle(context.Background(), Article{Title: test.title})
+			if !errors.Is(err, test.err) {
+				t.Fatalf("LoadArticle() error = %v, expected %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			text := Render(article.Document, 0).String()
+			if article.Title != test.expected || !strings.Contains(text, test.content) {
+				t.Fatalf("LoadArticle() = %q with %q, expected %q with %q", article.Title, text, test.expected, test.content)
+			}
+			if article.Url != "https://en.wikipedia.org/wiki/"+strings.ReplaceAll(test.expected, " ", "_") || article.Lang != "en" {
+				t.Fatalf("LoadArticle() url %q, lang %q", article.Url, article.Lang)
+			}
+		})
+	}
+
+	if index.SiteName() != "English Wikipedia" {
+		t.Fatalf("SiteName() = %q", index.SiteName())
+	}
+	if _, err := openDumpIndex(t.TempDir()); !errors.Is(err, ErrNotDumpIndex) {
+		t.Fatalf("openDumpIndex() error = %v, expected ErrNotDumpIndex", err)
+	}
+}
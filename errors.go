@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Errors the client returns, wrapped with details, so callers
+// can tell them apart with errors.Is
+var (
+	// The article doesn't exist
+	ErrNotFound = errors.New("article not found")
+	// The title can't be an article, e.g. it has a "[" in it
+	ErrInvalidTitle = errors.New("invalid title")
+	// The API kept turning requests away for being busy
+	ErrRateLimited = errors.New("rate limited")
+	// The wiki doesn't come in other languages, like
+	// archives read offline and wikis outside Wikimedia
+	ErrOneLanguage = errors.New("the wiki has only one language")
+	// There's no Wikipedia in a language of that code
+	ErrUnknownLang = errors.New("no such Wikipedia language")
+	// The config names no wiki by that name
+	ErrUnknownWiki = errors.New("no such wiki in the config")
+	// The URL given for a wiki's API isn't one
+	ErrNotMediaWiki = errors.New("not a MediaWiki API")
+)
+
+// APIError is an error the MediaWiki API reported in its response,
+// see https://www.mediawiki.org/wiki/API:Errors_and_warnings
+type APIError struct {
+	Code string `json:"code"`
+	Info string `json:"info"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("MediaWiki API error %s: %s", e.Code, e.Info)
+}
+
+// Lagging and rate limit errors match ErrRateLimited
+func (e *APIError) Is(target error) bool {
+	return target == ErrRateLimited && (e.Code == "maxlag" || e.Code == "ratelimited")
+}
+
+// StatusError is a response with an HTTP status other than 200 OK
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status: %s", e.Status)
+}
+
+// 429s and 503s match ErrRateLimited
+func (e *StatusError) Is(target error) bool {
+	return target == ErrRateLimited &&
+		(e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable)
+}
+
+// NetworkError is a request that failed before getting a response,
+// wrapping the cause, e.g. a timeout or context.Canceled
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("couldn't reach the API: %v", e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// Describes an error from site, e.g. English Wikipedia, for the info line
+func errorInfo(err error, site string) string {
+	var networkErr *NetworkError
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		return site + " is busy, try again in a moment"
+	case errors.As(err, &networkErr):
+		return "Couldn't reach " + site
+	}
+	return err.Error()
+}
+
+// Appends the titles of close matches to a not found error's info
+func notFoundInfo(info string, suggestions []string) string {
+	if len(suggestions) == 0 {
+		return info
+	}
+	return fmt.Sprintf("%s. Did you mean: %s?", info, strings.Join(suggestions, ", "))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Width of the Wikidata facts sidebar
+func (m model) factsWidth() int {
+	if !m.showFacts {
+		return 0
+	}
+	return min(36, m.width/3)
+}
+
+// Shows or hides the sidebar, loading the facts of the shown
+// article the first time it's shown for it
+func (m *model) toggleFacts() tea.Cmd {
+	m.showFacts = !m.showFacts
+	m.fitArticle()
+	return m.factsCmd()
+}
+
+// Loads the facts of the shown article while the sidebar's open,
+// unless they're loaded or on their way
+func (m *model) factsCmd() tea.Cmd {
+	title := m.shownArticle
+	if !m.showFacts || title == "" || m.factsFor == title {
+		return nil
+	}
+	m.factsFor = title
+	m.facts = nil
+	m.factsLoading = true
+	src := m.source
+	return func() tea.Msg {
+		facts, err := src.Facts(context.Background(), title)
+		return factsMsg{title: title, facts: facts, err: err}
+	}
+}
+
+type factsMsg struct {
+	title string
+	facts []Fact
+	err   error
+}
+
+func (m *model) receiveFacts(msg factsMsg) {
+	// Facts of an article since left behind
+	if msg.title != m.factsFor {
+		return
+	}
+	m.factsLoading = false
+	if msg.err != nil {
+		m.info = errorInfo(msg.err, m.source.SiteName())
+		// Try again when next shown
+		m.factsFor = ""
+		return
+	}
+	m.facts = msg.facts
+}
+
+func (m model) factsView() string {
+	width := m.factsWidth() - 2
+	style := lipgloss.NewStyle().
+		Width(width).
+		Height(m.viewport.Height).
+		MaxHeight(m.viewport.Height).
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderLeft(true).
+		PaddingLeft(1)
+	valueStyle := lipgloss.NewStyle().Width(width - 1).PaddingLeft(2)
+
+	lines := []string{articleBoldedStyle("Facts"), ""}
+	switch {
+	case m.factsLoading:
+		lines = append(lines, noteStyle("Loading..."))
+	case len(m.facts) == 0:
+		lines = append(lines, noteStyle("No facts from Wikidata"))
+	}
+	for _, fact := range m.facts {
+		lines = append(lines, noteStyle(fact.Label))
+		for _, value := range fact.Values {
+			lines = append(lines, valueStyle.Render(value))
+		}
+	}
+	return style.Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// historyEntry is a search or article the user visited
+type historyEntry struct {
+	pageName string
+	// Search text for "search" entries
+	query string
+	// Wikipedia searched for "search" entries
+	lang string
+	// Loaded article for "article" entries
+	article Article
+	// Scroll offset of the article reader
+	yOffset int
+}
+
+func (e historyEntry) label() string {
+	if e.pageName == "article" {
+		return e.article.Title
+	}
+	if e.query == "" {
+		return "search"
+	}
+	return fmt.Sprintf("search: %s", e.query)
+}
+
+// history is a browser-style back/forward stack
+type history struct {
+	entries []historyEntry
+	// Entry being shown
+	index int
+}
+
+func (h *history) current() *historyEntry {
+	if len(h.entries) == 0 {
+		return nil
+	}
+	return &h.entries[h.index]
+}
+
+// Adds an entry after the current one, dropping any forward entries
+func (h *history) push(entry historyEntry) {
+	if len(h.entries) > 0 {
+		h.entries = slices.Clip(h.entries[:h.index+1])
+	}
+	h.entries = append(h.entries, entry)
+	h.index = len(h.entries) - 1
+}
+
+// Moves step entries back (-1) or forward (1)
+func (h *history) move(step int) (historyEntry, bool) {
+	next := h.index + step
+	if next < 0 || next >= len(h.entries) {
+		return historyEntry{}, false
+	}
+	h.index = next
+	return h.entries[next], true
+}
+
+// Entries up to the current one, most recent last,
+// trimmed from the left to fit width
+func (h *history) breadcrumb(width int) string {
+	if len(h.entries) == 0 {
+		return ""
+	}
+	var labels []string
+	for _, entry := range h.entries[:h.index+1] {
+		labels = append(labels, entry.label())
+	}
+	crumb := strings.Join(labels, " › ")
+	for len(labels) > 1 && lipgloss.Width(crumb) > width {
+		labels = labels[1:]
+		crumb = "… › " + strings.Join(labels, " › ")
+	}
+	return crumb
+}
+
+// Saves the state of the page being left in the current entry
+func (m *model) recordCurrent() {
+	current := m.history.current()
+	switch m.pageName {
+	case "search":
+		entry := historyEntry{pageName: "search", query: m.textInput.Value(), lang: m.source.Language()}
+		if current != nil && current.pageName == "search" {
+			*current = entry
+		} else {
+			m.history.push(entry)
+		}
+	case "article":
+		if current != nil && current.pageName == "article" {
+			current.yOffset = m.viewport.YOffset
+		}
+	}
+}
+
+// Shows a newly loaded article as the latest history entry
+func (m *model) visitArticle(article Article) {
+	m.recordCurrent()
+	m.history.push(historyEntry{pageName: "article", article: article})
+	m.showArticle(article)
+}
+
+// Goes back (-1) or forward (1) through the history
+func (m *model) navigate(step int) tea.Cmd {
+	m.recordCurrent()
+	entry, ok := m.history.move(step)
+	if !ok {
+		return nil
+	}
+	return m.restore(entry)
+}
+
+// Shows a history entry the way it was left
+func (m *model) restore(entry historyEntry) tea.Cmd {
+	if entry.pageName == "search" {
+		m.stopLoading()
+		if err := m.useLang(entry.lang); err != nil {
+			m.info = err.Error()
+		}
+		m.pageName = "search"
+		m.cursor = 0
+		m.textInput.SetValue(entry.query)
+		m.textInput.CursorEnd()
+		return m.queryArticlesCmd()
+	}
+	m.showArticle(entry.article)
+	m.viewport.SetYOffset(entry.yOffset)
+	return m.factsCmd()
+}
+
+func (m *model) openHistory() {
+	m.recordCurrent()
+	var labels []string
+	for _, entry := range m.history.entries {
+		labels = append(labels, entry.label())
+	}
+	m.popup = newListPopup("History", labels, m.history.index)
+	m.pageName = "history"
+}
+
+func HistoryView(m model) string {
+	return m.popup.view(m.width, m.height)
+}
+
+func HistoryUpdate(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "up", "k":
+		m.popup.move(-1)
+	case "down", "j":
+		m.popup.move(1)
+	case "enter":
+		index, ok := m.popup.selected()
+		if !ok {
+			break
+		}
+		m.history.index = index
+		return m, m.restore(m.history.entries[m.history.index])
+	case "esc", "h", "q":
+		// Back to where the popup was opened
+		if current := m.history.current(); current != nil {
+			m.pageName = current.pageName
+		} else {
+			m.pageName = "search"
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestHistory(t *testing.T) {
+	var h history
+	if h.current() != nil {
+		t.Fatalf("empty history has a current entry")
+	}
+	h.push(historyEntry{pageName: "search", query: "gir"})
+	h.push(historyEntry{pageName: "article", article: Article{Title: "Giraffe"}})
+	h.push(historyEntry{pageName: "article", article: Article{Title: "Africa"}})
+
+	if got, expected := h.breadcrumb(100), "search: gir › Giraffe › Africa"; got != expected {
+		t.Fatalf("breadcrumb = %q, expected %q", got, expected)
+	}
+	if got, expected := h.breadcrumb(20), "… › Giraffe › Africa"; got != expected {
+		t.Fatalf("narrow breadcrumb = %q, expected %q", got, expected)
+	}
+
+	entry, ok := h.move(-1)
+	if !ok || entry.article.Title != "Giraffe" {
+		t.Fatalf("move(-1) = %+v, %v, expected Giraffe", entry, ok)
+	}
+	if _, ok := h.move(-1); !ok {
+		t.Fatalf("move(-1) to the search entry failed")
+	}
+	if _, ok := h.move(-1); ok {
+		t.Fatalf("moved back past the first entry")
+	}
+
+	// Visiting something new drops the forward entries
+	h.move(1)
+	h.push(historyEntry{pageName: "article", article: Article{Title: "Okapi"}})
+	if got, expected := h.breadcrumb(100), "search: gir › Giraffe › Okapi"; got != expected {
+		t.Fatalf("breadcrumb = %q, expected %q", got, expected)
+	}
+	if _, ok := h.move(1); ok {
+		t.Fatalf("forward entries survived a push")
+	}
+}
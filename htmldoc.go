@@ -0,0 +1,411 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ------------------------------------------
+// Article HTML to document tree
+// ------------------------------------------
+
+// Classes of elements that aren't part of the article's text,
+// like the navigation boxes templates make
+var hiddenHTMLClasses = []string{
+	"navbox", "vertical-navbox", "sidebar", "metadata",
+	"ambox", "hatnote", "thumb", "noprint", "mw-editsection",
+	"mw-empty-elt", "mw-cite-backlink",
+}
+
+// ParseHTML builds the same document tree as ParseWikitext from
+// an article's HTML, e.g. from a Kiwix archive, so both render alike.
+// articlePath is where the wiki's links to articles point, as in
+// /wiki/$1, or empty when they're relative like in archives.
+func ParseHTML(src string, articlePath string) *Document {
+	root, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return &Document{}
+	}
+	c := htmlConverter{notes: map[string]*html.Node{}, articlePath: articlePath}
+	c.collectNotes(root)
+	return &Document{Children: nestSections(c.blocks(root))}
+}
+
+type htmlConverter struct {
+	// Footnotes by id, from the list of references
+	notes       map[string]*html.Node
+	articlePath string
+}
+
+func (c *htmlConverter) collectNotes(n *html.Node) {
+	if n.DataAtom == atom.Li {
+		if id := htmlAttr(n, "id"); strings.HasPrefix(id, "cite_note") {
+			c.notes[id] = n
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.collectNotes(child)
+	}
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func hasClass(n *html.Node, classes ...string) bool {
+	for _, class := range strings.Fields(htmlAttr(n, "class")) {
+		if slices.Contains(classes, class) {
+			return true
+		}
+	}
+	return false
+}
+
+func hiddenHTML(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return n.Type != html.TextNode
+	}
+	switch n.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Noscript, atom.Link, atom.Meta,
+		atom.Img, atom.Figure, atom.Audio, atom.Video, atom.Math, atom.Svg, atom.H1:
+		return true
+	}
+	return hasClass(n, hiddenHTMLClasses...)
+}
+
+// The list of references, which the renderer lists itself
+func referencesHTML(n *html.Node) bool {
+	return n.DataAtom == atom.Ol && hasClass(n, "references") ||
+		n.DataAtom == atom.Div && hasClass(n, "reflist", "mw-references-wrap")
+}
+
+// Block nodes for n's children. Runs of inline
+// content between blocks form paragraphs.
+func (c *htmlConverter) blocks(n *html.Node) []Node {
+	var blocks []Node
+	var inline []Node
+	flush := func() {
+		if nodes := trimNodes(inline); len(nodes) > 0 {
+			blocks = append(blocks, &Paragraph{Children: nodes})
+		}
+		inline = nil
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if hiddenHTML(child) {
+			continue
+		}
+		if referencesHTML(child) {
+			flush()
+			blocks = append(blocks, &Paragraph{Children: []Node{&Tag{Name: "references"}}})
+			continue
+		}
+		switch child.DataAtom {
+		case atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			flush()
+			level := int(child.Data[1] - '0')
+			blocks = append(blocks, &Section{Level: level, Title: trimNodes(c.inline(child))})
+		case atom.P:
+			flush()
+			inline = c.inline(child)
+			flush()
+		case atom.Ul, atom.Ol, atom.Dl:
+			flush()
+			if list := c.list(child); len(list.Items) > 0 {
+				blocks = append(blocks, list)
+			}
+		case atom.Table:
+			flush()
+			if hasClass(child, "infobox") {
+				if infobox := c.infobox(child); len(infobox.Params) > 0 {
+					blocks = append(blocks, &Paragraph{Children: []Node{infobox}})
+				}
+				continue
+			}
+			if table := c.table(child); len(table.Rows) > 0 {
+				blocks = append(blocks, table)
+			}
+		case atom.Hr:
+			flush()
+			blocks = append(blocks, &HorizontalRule{})
+		case atom.Blockquote, atom.Pre:
+			flush()
+			inline = []Node{&Tag{Name: child.Data, Children: c.inline(child)}}
+			flush()
+		case atom.Html, atom.Body, atom.Div, atom.Section, atom.Details, atom.Summary,
+			atom.Main, atom.Article, atom.Center:
+			flush()
+			blocks = append(blocks, c.blocks(child)...)
+		default:
+			inline = append(inline, c.inlineNode(child)...)
+		}
+	}
+	flush()
+	return blocks
+}
+
+// Inline nodes for n's children
+func (c *htmlConverter) inline(n *html.Node) []Node {
+	var nodes []Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		nodes = append(nodes, c.inlineNode(child)...)
+	}
+	return mergeText(nodes)
+}
+
+func (c *htmlConverter) inlineNode(n *html.Node) []Node {
+	if n.DataAtom == atom.Img && hasClass(n, "mwe-math-fallback-image-inline", "mwe-math-fallback-image-display") {
+		return []Node{&Text{Value: mathText(htmlAttr(n, "alt"))}}
+	}
+	if hiddenHTML(n) {
+		return nil
+	}
+	if n.Type == html.TextNode {
+		return []Node{&Text{Value: n.Data}}
+	}
+	switch n.DataAtom {
+	case atom.B, atom.Strong:
+		return []Node{&Bold{Children: c.inline(n)}}
+	case atom.I, atom.Em:
+		return []Node{&Italic{Children: c.inline(n)}}
+	case atom.Br:
+		return []Node{&Tag{Name: "br"}}
+	case atom.A:
+		return c.anchor(n)
+	case atom.Sup:
+		if hasClass(n, "reference") {
+			return c.note(n)
+		}
+	case atom.P, atom.Div, atom.Li, atom.Dd, atom.Dt:
+		// Blocks inside inline content, e.g. in a table cell
+		return append(c.inline(n), &Text{Value: " "})
+	}
+	return []Node{&Tag{Name: n.Data, Children: c.inline(n)}}
+}
+
+func (c *htmlConverter) anchor(n *html.Node) []Node {
+	href := htmlAttr(n, "href")
+	children := c.inline(n)
+	switch {
+	case href == "" || strings.HasPrefix(href, "#"):
+		return children
+	case strings.Contains(href, "://") || strings.HasPrefix(href, "//") || hasClass(n, "external"):
+		return []Node{&ExternalLink{URL: href, Children: children}}
+	}
+	target, ok := htmlLinkTarget(href, c.articlePath)
+	if !ok {
+		return children
+	}
+	return []Node{&Link{Target: target, Children: children}}
+}
+
+// Article a relative link points at, e.g. ./Giraffe_family#Taxonomy
+// is "Giraffe family#Taxonomy", and whether there is one. Red links,
+// to articles not written yet, are left as text.
+func htmlLinkTarget(href string, articlePath string) (string, bool) {
+	path, fragment, _ := strings.Cut(href, "#")
+	path, rawQuery, _ := strings.Cut(path, "?")
+	query, _ := url.ParseQuery(rawQuery)
+	if query.Get("redlink") == "1" {
+		return "", false
+	}
+	prefix, _, _ := strings.Cut(articlePath, "$1")
+	switch {
+	case query.Has("title"):
+		// /w/index.php?title=Giraffe
+		path = url.PathEscape(query.Get("title"))
+	case prefix != "" && strings.HasPrefix(path, prefix):
+		path = path[len(prefix):]
+	case strings.HasPrefix(path, "../"):
+		// Older archives keep articles under A/
+		for strings.HasPrefix(path, "../") {
+			path = path[len("../"):]
+		}
+		path = strings.TrimPrefix(path, "A/")
+	default:
+		path = strings.TrimPrefix(path, "./")
+	}
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+	target := strings.ReplaceAll(path, "_", " ")
+	if fragment != "" {
+		target += "#" + fragment
+	}
+	return target, true
+}
+
+// A footnote marker as a ref holding the note it links to
+func (c *htmlConverter) note(n *html.Node) []Node {
+	var id string
+	for child := n.FirstChild; child != nil && id == ""; child = child.NextSibling {
+		if child.DataAtom == atom.A {
+			// #cite_note-1, or ./Giraffe#cite_note-1 from Parsoid
+			_, id, _ = strings.Cut(htmlAttr(child, "href"), "#")
+		}
+	}
+	ref := &Tag{Name: "ref", Attrs: map[string]string{"name": id}}
+	if note, ok := c.notes[id]; ok {
+		ref.Children = trimNodes(c.inline(note))
+	}
+	return []Node{ref}
+}
+
+// Formulas are pictures with their TeX as the alt text,
+// e.g. {\displaystyle E=mc^{2}}
+func mathText(alt string) string {
+	alt = strings.TrimSpace(alt)
+	if inner, ok := strings.CutPrefix(alt, "{\\displaystyle"); ok {
+		alt = strings.TrimSpace(strings.TrimSuffix(inner, "}"))
+	}
+	return alt
+}
+
+// Bulleted, numbered and definition lists
+func (c *htmlConverter) list(n *html.Node) *List {
+	marker := byte('*')
+	if n.DataAtom == atom.Ol {
+		marker = '#'
+	}
+	list := &List{}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if hiddenHTML(child) || child.Type != html.ElementNode {
+			continue
+		}
+		item := &ListItem{Marker: marker}
+		switch child.DataAtom {
+		case atom.Dt:
+			item.Marker = ';'
+		case atom.Dd:
+			item.Marker = ':'
+		case atom.Li:
+		default:
+			continue
+		}
+		var children []Node
+		for grandchild := child.FirstChild; grandchild != nil; grandchild = grandchild.NextSibling {
+			switch grandchild.DataAtom {
+			case atom.Ul, atom.Ol, atom.Dl:
+				sublist := c.list(grandchild)
+				if item.Sublist == nil {
+					item.Sublist = sublist
+				} else {
+					item.Sublist.Items = append(item.Sublist.Items, sublist.Items...)
+				}
+			default:
+				children = append(children, c.inlineNode(grandchild)...)
+			}
+		}
+		item.Children = trimNodes(children)
+		list.Items = append(list.Items, item)
+	}
+	return list
+}
+
+func (c *htmlConverter) table(n *html.Node) *Table {
+	table := &Table{Attrs: htmlAttrs(n)}
+	var rows func(n *html.Node)
+	rows = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			switch child.DataAtom {
+			case atom.Caption:
+				table.Caption = trimNodes(c.inline(child))
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				rows(child)
+			case atom.Tr:
+				table.Rows = append(table.Rows, c.row(child))
+			}
+		}
+	}
+	rows(n)
+	return table.prune()
+}
+
+// An infobox table as the template it was made from, so it's laid
+// out as the same card. Rows of a label and a value become labelN and
+// dataN, rows of one header cell headerN, and the heading its title.
+func (c *htmlConverter) infobox(n *html.Node) *Template {
+	infobox := &Template{Name: "Infobox"}
+	number := 0
+	param := func(name string, value []Node) {
+		infobox.Params = append(infobox.Params, &Param{Name: name + strconv.Itoa(number), Value: value})
+	}
+	var rows func(n *html.Node)
+	rows = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			switch child.DataAtom {
+			case atom.Caption:
+				infobox.Params = append(infobox.Params, &Param{Name: "above", Value: trimNodes(c.inline(child))})
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				rows(child)
+			case atom.Tr:
+				var label, value []Node
+				var heading bool
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom != atom.Td && cell.DataAtom != atom.Th || hiddenHTML(cell) {
+						continue
+					}
+					nodes := trimNodes(c.inline(cell))
+					switch {
+					case cell.DataAtom == atom.Th && label == nil && value == nil:
+						label = nodes
+						heading = hasClass(cell, "infobox-above")
+					case len(nodes) > 0:
+						if len(value) > 0 {
+							value = append(value, &Text{Value: " "})
+						}
+						value = append(value, nodes...)
+					}
+				}
+				if len(label) == 0 && len(value) == 0 {
+					// Pictures and the like
+					continue
+				}
+				number++
+				switch {
+				case heading && len(value) == 0:
+					infobox.Params = append(infobox.Params, &Param{Name: "above", Value: label})
+				case len(value) == 0:
+					param("header", label)
+				default:
+					param("label", label)
+					param("data", value)
+				}
+			}
+		}
+	}
+	rows(n)
+	return infobox
+}
+
+func (c *htmlConverter) row(n *html.Node) *TableRow {
+	row := &TableRow{Attrs: htmlAttrs(n)}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.DataAtom != atom.Td && child.DataAtom != atom.Th || hiddenHTML(child) {
+			continue
+		}
+		row.Cells = append(row.Cells, &TableCell{
+			Header:   child.DataAtom == atom.Th,
+			Attrs:    htmlAttrs(child),
+			Children: trimNodes(c.inline(child)),
+		})
+	}
+	return row
+}
+
+func htmlAttrs(n *html.Node) map[string]string {
+	attrs := map[string]string{}
+	for _, attr := range n.Attr {
+		attrs[attr.Key] = attr.Val
+	}
+	return attrs
+}
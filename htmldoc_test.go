@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestParseHTML(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected string
+	}{
+		"paragraphs and sections": {
+			input:    `<h1>Giraffe</h1><p>Lead <b>bold</b> <i>it</i></p><h2>History</h2><p>Old</p><h3>Recent</h3><p>New</p>`,
+			expected: `p("Lead " b("bold") " " i("it")) h2["History"](p("Old") h3["Recent"](p("New")))`,
+		},
+		"mwoffliner sections": {
+			input:    `<details><summary><h2>Habitat</h2></summary><section><p>Savanna</p></section></details>`,
+			expected: `h2["Habitat"](p("Savanna"))`,
+		},
+		"links": {
+			input:    `<p><a href="./Giraffe_family#Taxonomy">family</a> <a href="../A/Okapi">okapi</a> <a href="https://example.com" class="external">site</a> <a href="#cite">here</a></p>`,
+			expected: `p(link[Giraffe family#Taxonomy]("family") " " link[Okapi]("okapi") " " ext[https://example.com]("site") " here")`,
+		},
+		"wiki links": {
+			input: `<p><a href="/wiki/Giraffe_family">family</a> <a href="/w/index.php?title=Okapi_(animal)&amp;oldid=1">okapi</a> ` +
+				`<a href="/w/index.php?title=Zarafa&amp;action=edit&amp;redlink=1" class="new">zarafa</a></p>`,
+			expected: `p(link[Giraffe family]("family") " " link[Okapi (animal)]("okapi") " zarafa")`,
+		},
+		"lists": {
+			input:    "<ul><li>one<ul><li>nested</li></ul></li><li>two</li></ul><ol><li>first</li></ol><dl><dt>term</dt><dd>meaning</dd></dl>",
+			expected: `list{*("one")list{*("nested")} *("two")} list{#("first")} list{;("term") :("meaning")}`,
+		},
+		"table": {
+			input:    `<table class="wikitable"><caption>Sizes</caption><tr><th>Name</th></tr><tr><td colspan="2">Giraffe</td></tr></table>`,
+			expected: `table[class=wikitable]("Sizes"){th[]("Name") / td[colspan=2]("Giraffe")}`,
+		},
+		"hidden boxes": {
+			input:    `<table class="navbox"><tr><td>Giraffids</td></tr></table><div class="hatnote">See also</div><p>Text<span class="mw-editsection">edit</span></p>`,
+			expected: `p("Text")`,
+		},
+		"infobox": {
+			input: `<table class="infobox biota"><tbody><tr><th colspan="2" class="infobox-above">Giraffe</th></tr>` +
+				`<tr><td colspan="2"><img src="giraffe.jpg"></td></tr><tr><th colspan="2">Classification</th></tr>` +
+				`<tr><th>Kingdom:</th><td><a href="./Animal">Animalia</a></td></tr></tbody></table><p>Text</p>`,
+			expected: `p(tpl[Infobox](above="Giraffe" | header2="Classification" | label3="Kingdom:" | data3=link[Animal]("Animalia"))) p("Text")`,
+		},
+		"formulas": {
+			input:    `<p>Energy <span class="mwe-math-element"><math><mi>E</mi></math><img class="mwe-math-fallback-image-inline" alt="{\displaystyle E=mc^{2}}"></span></p>`,
+			expected: `p("Energy " <span >("E=mc^{2}"))`,
+		},
+		"references": {
+			input: `<p>Tall<sup class="reference"><a href="#cite_note-a-1">[1]</a></sup></p>` +
+				`<div class="reflist"><ol class="references"><li id="cite_note-a-1"><span class="mw-cite-backlink">^</span> <span class="reference-text">A <i>book</i></span></li></ol></div>`,
+			expected: `p("Tall" <ref name=cite_note-a-1>(<span >("A " i("book")))) p(<references >())`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := dump(ParseHTML(test.input, "/wiki/$1").Children)
+			if got != test.expected {
+				t.Fatalf("ParseHTML() = %s, expected %s", got, test.expected)
+			}
+		})
+	}
+}
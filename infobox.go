@@ -0,0 +1,298 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Infoboxes are laid out as bordered cards of labels and values,
+// where they are in the article, beside it, or not at all.
+
+// How Render lays out infoboxes
+type infoboxLayout int
+
+const (
+	// As cards where they are in the article
+	infoboxInline infoboxLayout = iota
+	// The lead's left out, to be shown beside the article
+	infoboxAside
+	infoboxHidden
+)
+
+// Terminals at least this wide show the lead's infobox beside the article
+const infoboxAsideMinWidth = 120
+
+// Width of the infobox column beside the article
+func infoboxAsideWidth(width int) int {
+	return min(48, width/3)
+}
+
+// Parameters that are pictures, maps and other things we can't show
+var hiddenInfoboxParams = []string{
+	"image", "caption", "alt", "logo", "map", "pushpin", "signature",
+	"upright", "size", "module", "embed", "footnotes", "child", "coordinates",
+	"fetchwikidata", "onlysourced", "noicon", "qid", "bodyclass", "style",
+}
+
+// Parameters naming the subject, shown as the card's title
+var infoboxTitleParams = []string{"name", "title", "above", "common_name", "conventional_long_name"}
+
+// infoboxRow is a line of the card: a label and its value,
+// or a value across both columns when there's no label
+type infoboxRow struct {
+	label  []Node
+	value  []Node
+	header bool
+}
+
+// Whether a template is an infobox worth showing as a card.
+// Navboxes and other boxes keep disappearing.
+func isInfoboxCard(t *Template) bool {
+	key := t.Key()
+	switch {
+	case strings.HasPrefix(key, "infobox"):
+		return true
+	case key == "taxobox", strings.HasSuffix(key, "speciesbox"), key == "automatic taxobox":
+		return true
+	}
+	return false
+}
+
+// The infobox at the top of the article, if it has one
+func leadInfobox(doc *Document) *Template {
+	if doc == nil {
+		return nil
+	}
+	for _, node := range doc.Children {
+		switch n := node.(type) {
+		case *Section:
+			return nil
+		case *Paragraph:
+			for _, child := range n.Children {
+				if t, ok := child.(*Template); ok && isInfoboxCard(t) {
+					return t
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func hiddenInfoboxParam(name string) bool {
+	for _, hidden := range hiddenInfoboxParams {
+		if strings.Contains(name, hidden) {
+			return true
+		}
+	}
+	return false
+}
+
+// The card's title and rows. Generic infoboxes pair up
+// labelN and dataN, with headerN between; the rest
+// are labelled with their parameter names.
+func infoboxRows(t *Template) ([]Node, []infoboxRow) {
+	var title []Node
+	for _, name := range infoboxTitleParams {
+		if value, ok := t.Named(name); ok && len(trimNodes(value)) > 0 {
+			title = value
+			break
+		}
+	}
+
+	numbered := map[int]*infoboxRow{}
+	var numbers []int
+	var rows []infoboxRow
+	for _, param := range t.Params {
+		name := strings.ToLower(strings.TrimSpace(param.Name))
+		if name == "" || hiddenInfoboxParam(name) || slices.Contains(infoboxTitleParams, name) {
+			continue
+		}
+		value := trimNodes(param.Value)
+		if len(value) == 0 {
+			continue
+		}
+		for _, kind := range []string{"label", "data", "header"} {
+			n, err := strconv.Atoi(strings.TrimPrefix(name, kind))
+			if !strings.HasPrefix(name, kind) || err != nil {
+				continue
+			}
+			row, ok := numbered[n]
+			if !ok {
+				row = &infoboxRow{}
+				numbered[n] = row
+				numbers = append(numbers, n)
+			}
+			switch kind {
+			case "label":
+				row.label = value
+			case "header":
+				row.header = true
+				fallthrough
+			default:
+				row.value = value
+			}
+			name = ""
+		}
+		if name != "" {
+			label := strings.ReplaceAll(strings.ReplaceAll(name, "_", " "), "-", " ")
+			rows = append(rows, infoboxRow{label: []Node{&Text{Value: capitalize(label)}}, value: value})
+		}
+	}
+	if len(numbers) > 0 {
+		sort.Ints(numbers)
+		var pairs []infoboxRow
+		for _, n := range numbers {
+			pairs = append(pairs, *numbered[n])
+		}
+		rows = append(pairs, rows...)
+	}
+	return title, rows
+}
+
+// Lays out an infobox as a card no wider than the renderer's
+// width, or only as wide as it needs when that's 0:
+//
+//	┌──────────────────────┐
+//	│ Giraffe              │
+//	├───────┬──────────────┤
+//	│ Genus │ Giraffa      │
+//	└───────┴──────────────┘
+func (r *renderer) infobox(t *Template) [][]segment {
+	titleNodes, rows := infoboxRows(t)
+	title := r.inline(titleNodes, formatBold)
+	type cell struct {
+		label, value []segment
+		// Spans both columns
+		wide bool
+	}
+	var cells []cell
+	for _, row := range rows {
+		format := textFormat(0)
+		if row.header {
+			format = formatBold
+		}
+		c := cell{label: r.inline(row.label, formatBold), value: r.inline(row.value, format)}
+		c.wide = len(row.label) == 0
+		if len(wrap(c.value, 0, "", "")) > 0 {
+			cells = append(cells, c)
+		}
+	}
+	if len(cells) == 0 {
+		return nil
+	}
+
+	// Columns as wide as their widest line, then squeezed
+	// into the width with labels taking up to half
+	natural := func(segs []segment) int {
+		width := 0
+		for _, line := range wrap(segs, 0, "", "") {
+			width = max(width, lineWidth(line))
+		}
+		return width
+	}
+	labelWidth, valueWidth, wideWidth := 0, 0, natural(title)
+	for _, c := range cells {
+		if c.wide {
+			wideWidth = max(wideWidth, natural(c.value))
+			continue
+		}
+		labelWidth = max(labelWidth, natural(c.label))
+		valueWidth = max(valueWidth, natural(c.value))
+	}
+	valueWidth = max(valueWidth, wideWidth-labelWidth-3)
+	if r.width > 0 {
+		labelWidth = max(min(labelWidth, (r.width-7)/2), 1)
+		valueWidth = max(min(valueWidth, r.width-7-labelWidth), 1)
+	}
+	inner := labelWidth + valueWidth + 3
+
+	border := func(text string) segment {
+		return segment{text: text, format: formatNote}
+	}
+	pad := func(line []segment, width int) []segment {
+		padded := append([]segment{}, line...)
+		if gap := width - lineWidth(line); gap > 0 {
+			padded = append(padded, segment{text: strings.Repeat(" ", gap)})
+		}
+		return padded
+	}
+	// Rules between rows, only split where both rows have two columns
+	rule := func(left string, split string, right string) []segment {
+		if split == "─" {
+			return []segment{border(left + strings.Repeat("─", inner+2) + right)}
+		}
+		return []segment{border(left + strings.Repeat("─", labelWidth+2) + split + strings.Repeat("─", valueWidth+2) + right)}
+	}
+	wideLines := func(segs []segment) [][]segment {
+		var lines [][]segment
+		for _, line := range wrap(segs, inner, "", "") {
+			lines = append(lines, append(append([]segment{border("│ ")}, pad(line, inner)...), border(" │")))
+		}
+		return lines
+	}
+
+	var lines [][]segment
+	if len(title) > 0 {
+		lines = append(lines, rule("┌", "─", "┐"))
+		lines = append(lines, wideLines(title)...)
+	}
+	for i, c := range cells {
+		// The rule above the row
+		wideAbove := i == 0 && len(title) > 0 || i > 0 && cells[i-1].wide
+		switch {
+		case i == 0 && len(title) == 0 && c.wide:
+			lines = append(lines, rule("┌", "─", "┐"))
+		case i == 0 && len(title) == 0:
+			lines = append(lines, rule("┌", "┬", "┐"))
+		case wideAbove && c.wide:
+			lines = append(lines, rule("├", "─", "┤"))
+		case wideAbove:
+			lines = append(lines, rule("├", "┬", "┤"))
+		case c.wide:
+			lines = append(lines, rule("├", "┴", "┤"))
+		}
+		if c.wide {
+			lines = append(lines, wideLines(c.value)...)
+			continue
+		}
+		label := wrap(c.label, labelWidth, "", "")
+		value := wrap(c.value, valueWidth, "", "")
+		for j := 0; j < max(len(label), len(value)); j++ {
+			line := []segment{border("│ ")}
+			if j < len(label) {
+				line = append(line, pad(label[j], labelWidth)...)
+			} else {
+				line = append(line, pad(nil, labelWidth)...)
+			}
+			line = append(line, border(" │ "))
+			if j < len(value) {
+				line = append(line, pad(value[j], valueWidth)...)
+			} else {
+				line = append(line, pad(nil, valueWidth)...)
+			}
+			lines = append(lines, append(line, border(" │")))
+		}
+	}
+	if cells[len(cells)-1].wide {
+		return append(lines, rule("└", "─", "┘"))
+	}
+	return append(lines, rule("└", "┴", "┘"))
+}
+
+func lineWidth(line []segment) int {
+	width := 0
+	for _, seg := range line {
+		width += lipgloss.Width(seg.text)
+	}
+	return width
+}
+
+// Lays out the infobox on its own, for showing beside the article
+func RenderInfobox(t *Template, width int) *Rendering {
+	r := &renderer{width: width, refNames: map[string]int{}}
+	return &Rendering{Lines: r.infobox(t)}
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestRenderInfobox(t *testing.T) {
+	tests := map[string]struct {
+		wikitext string
+		width    int
+		expected string
+	}{
+		"named parameters": {
+			wikitext: "{{Infobox person\n| name = Ada Lovelace\n| image = Ada.jpg\n| birth_date = 10 December 1815\n| known_for = [[Analytical Engine]]\n}}",
+			expected: `┌────────────────────────────────┐
+│ Ada Lovelace                   │
+├────────────┬───────────────────┤
+│ Birth date │ 10 December 1815  │
+│ Known for  │ Analytical Engine │
+└────────────┴───────────────────┘`,
+		},
+		"numbered labels and headers": {
+			wikitext: "{{Infobox\n| above = Giraffe\n| header1 = Taxonomy\n| label2 = Genus\n| data2 = Giraffa\n| data3 = Extant\n}}",
+			expected: `┌─────────────────┐
+│ Giraffe         │
+├─────────────────┤
+│ Taxonomy        │
+├───────┬─────────┤
+│ Genus │ Giraffa │
+├───────┴─────────┤
+│ Extant          │
+└─────────────────┘`,
+		},
+		"lists": {
+			wikitext: "{{Infobox person\n| occupation = {{Plainlist|\n* [[Mathematician]]\n* Writer\n}}\n| spouse = William<br />King\n| known_for = {{hlist|Notes|Programs}}\n}}",
+			expected: `┌────────────┬──────────────────┐
+│ Occupation │ Mathematician    │
+│            │ Writer           │
+│ Spouse     │ William          │
+│            │ King             │
+│ Known for  │ Notes · Programs │
+└────────────┴──────────────────┘`,
+		},
+		"squeezed": {
+			wikitext: "{{Infobox person\n| occupation = Mathematician and writer\n}}",
+			width:    30,
+			expected: `┌────────────┬───────────────┐
+│ Occupation │ Mathematician │
+│            │ and writer    │
+└────────────┴───────────────┘`,
+		},
+		"nothing to show": {
+			wikitext: "{{Infobox person\n| image = Ada.jpg\n}}",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			doc := ParseWikitext(test.wikitext)
+			got := RenderInfobox(leadInfobox(doc), test.width).String()
+			if got != test.expected {
+				t.Fatalf("RenderInfobox() =\n%s\nexpected\n%s", got, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Language codes in the order the picker lists them
+func langCodes() []string {
+	codes := make([]string, 0, len(WikipediaLangs))
+	for code := range WikipediaLangs {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return WikipediaLangs[codes[i]] < WikipediaLangs[codes[j]]
+	})
+	return codes
+}
+
+func (m *model) openLanguages() {
+	m.recordCurrent()
+	var items []string
+	for _, code := range langCodes() {
+		items = append(items, fmt.Sprintf("%s (%s)", WikipediaLangs[code], code))
+	}
+	m.popup = newFilterPopup("Language", "English", items)
+	m.pageName = "languages"
+}
+
+// Reads lang's Wikipedia from now on, if it isn't already
+func (m *model) useLang(lang string) error {
+	if lang == "" || lang == m.source.Language() {
+		return nil
+	}
+	src, err := m.source.InLang(lang)
+	if err != nil {
+		return err
+	}
+	m.source = src
+	return nil
+}
+
+// Reads another language's Wikipedia from now on,
+// searching it for the current query
+func (m *model) switchLang(lang string) tea.Cmd {
+	if err := m.useLang(lang); err != nil {
+		m.info = err.Error()
+		return nil
+	}
+	m.stopLoading()
+	m.pageName = "search"
+	m.cursor = 0
+	m.Articles = DefaultArticleMap
+	return m.queryArticlesCmd()
+}
+
+// Lists the shown article's other language editions
+func (m *model) openLangLinks() {
+	if len(m.langLinks) == 0 {
+		m.info = "No other languages"
+		return
+	}
+	var items []string
+	for _, link := range m.langLinks {
+		items = append(items, fmt.Sprintf("%s (%s): %s", WikipediaLangs[link.Lang], link.Lang, link.Title))
+	}
+	m.popup = newFilterPopup("Read in", "German", items)
+	m.pageName = "langlinks"
+}
+
+// Shared by both language popups
+func LanguagesView(m model) string {
+	return m.popup.view(m.width, m.height)
+}
+
+func LanguagesUpdate(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		// Back to where the picker was opened
+		if current := m.history.current(); current != nil {
+			m.pageName = current.pageName
+		} else {
+			m.pageName = "search"
+		}
+	case tea.KeyUp:
+		m.popup.move(-1)
+	case tea.KeyDown:
+		m.popup.move(1)
+	case tea.KeyEnter:
+		if i, ok := m.popup.selected(); ok {
+			return m, m.switchLang(langCodes()[i])
+		}
+	default:
+		return m, m.popup.updateFilter(msg)
+	}
+	return m, nil
+}
+
+func LangLinksUpdate(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.pageName = "article"
+	case tea.KeyUp:
+		m.popup.move(-1)
+	case tea.KeyDown:
+		m.popup.move(1)
+	case tea.KeyEnter:
+		m.pageName = "article"
+		if i, ok := m.popup.selected(); ok {
+			link := m.langLinks[i]
+			m.info = fmt.Sprintf("Loading %s...", link.Title)
+			return m, m.loadArticleCmd(Article{Title: link.Title, Lang: link.Lang})
+		}
+	default:
+		return m, m.popup.updateFilter(msg)
+	}
+	return m, nil
+}
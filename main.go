@@ -3,9 +3,13 @@ package main
 // A Wikipedia TUI
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -13,6 +17,9 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
+// Set by the release build, e.g. -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
 const ExtendedUsage = `
 wki - Wikipedia at your fingertips
 
@@ -21,8 +28,88 @@ Type into the search bar to search for articles.
 - Move cursor ` + "`*`" + `:             up and down arrow keys
 - Open the selected article:   enter
 - Navigate the article reader: arrow keys or vim/less controls
-- Return to search page:       left arrow key
-- Quit:                        escape or Ctrl+C`
+- Select links in view:        tab and shift+tab
+- Follow the selected link:    enter, or show the citation
+                               behind a selected [1] marker
+- Go back and forward:         left and right arrow keys in the reader,
+                               alt+left and alt+right anywhere
+- Show history:                h in the reader, alt+h anywhere
+- Toggle table of contents:    t
+- Toggle facts from Wikidata:  i
+- Toggle the infobox:          I
+- Next and previous section:   ] and [
+- Go to a section:             /
+- Change language:             l in the reader, alt+l anywhere
+- Read the article in another
+  language:                    L
+- Read a random article:       alt+r
+- Look up a word in
+  Wiktionary:                  w, then pick a word in view,
+                               starting with the selected link
+- Stop loading an article:     escape
+- Quit:                        escape or Ctrl+C
+
+Read another language's Wikipedia with -l/--lang, e.g. wki -l de or
+wki get -l de Giraffe. Set a default with $WKI_LANG or {"lang": "de"}
+in wki/config.json under your config directory (~/.config on Linux).
+Requests give up after 15s, or the config file's "timeout", e.g. "30s".
+Searches start once typing pauses for 300ms, or the "debounce" set there.
+Set "user_agent" there to send your contact details with requests.
+
+Read another MediaWiki, like a company wiki, a Fandom wiki or Wiktionary,
+with --wiki and the URL of its api.php, e.g.
+wki --wiki https://en.wiktionary.org/w/api.php. Name the ones you use in
+the config, e.g. {"wikis": {"corp": "https://wiki.example.com/w/api.php"}},
+and pick them with -w, e.g. wki -w corp. Only Wikimedia wikis change
+language with -l.
+Articles and searches are cached in wki under your cache directory
+(~/.cache on Linux). Cached articles are used for 24h, or "cache_ttl",
+then only refetched once edited. The cache keeps to 100MB, or
+"cache_size_mb". Skip it with --no-cache, empty it with wki cache clear.
+
+Infoboxes are shown as cards of their labels and values, beside the
+article when the window's at least 120 columns wide and above it when
+it's narrower. Hide them with I, or with wki get --no-infobox.
+Templates wki doesn't know are left out; see where with --show-templates,
+which shows them as dim {{placeholders}}.
+Articles heavy with templates, like sports statistics or chemistry, read
+more faithfully with --render=html, which loads the HTML the wiki renders
+them to, every template expanded, instead of their wikitext.
+
+Read Wiktionary with --wiktionary, e.g. wki --wiktionary -t giraffe, to
+see entries as dictionary cards with their pronunciation, definitions,
+examples, etymology and translations, or print one with wki define.
+Cards follow the English Wiktionary's layout; entries laid out another
+way are shown as they are.
+
+Read offline from a Kiwix ZIM archive (https://library.kiwix.org) with
+--zim, e.g. wki --zim wikipedia_en_all_nopic.zim. Searches in an archive
+match the start of article titles, and once you've indexed it with
+wki index wikipedia_en_all_nopic.zim the words of articles too. wki
+can't read the full-text index archives come with, a Xapian database.
+
+Or read offline from a Wikipedia dump (https://dumps.wikimedia.org):
+index it with wki index, e.g. wki index enwiki-latest-pages-articles.xml.bz2,
+then read it with wki --dump enwiki-latest-pages-articles. Searches match
+the start of titles, and with wki index --full-text the words of articles.
+Words can't be looked up in Wiktionary while reading offline.
+
+Commands:
+- wki get [--width N] [--no-infobox] [--show-templates]
+      [--render wikitext|html] <title>
+                               print an article as plain text. Exits
+                               with 3 if there's no such article and
+                               1 on network errors
+- wki search [--limit N] [--offset N] [--format text|json|tsv] <query>
+                               print the articles matching a search,
+                               exiting with 3 if nothing matches
+- wki define [--in LANGUAGE] <word>
+                               print a word's Wiktionary entry, in
+                               every language with --in all
+- wki cache clear              delete everything in the cache
+- wki index [--out DIR] [--full-text] <dump>
+                               index a MediaWiki XML dump for --dump
+- wki index <archive.zim>      index the words of an archive for --zim`
 
 // Helper struct enabling multiple TUI pages
 // along with the pages map and model.pageName
@@ -33,8 +120,15 @@ type Page struct {
 
 // New Update/View methods go here
 var pages = map[string]Page{
-	"search":  {update: SearchUpdate, view: SearchView},
-	"article": {update: ArticleUpdate, view: ArticleView},
+	"search":     {update: SearchUpdate, view: SearchView},
+	"article":    {update: ArticleUpdate, view: ArticleView},
+	"history":    {update: HistoryUpdate, view: HistoryView},
+	"sections":   {update: SectionsUpdate, view: SectionsView},
+	"citation":   {update: CitationUpdate, view: CitationView},
+	"languages":  {update: LanguagesUpdate, view: LanguagesView},
+	"langlinks":  {update: LangLinksUpdate, view: LanguagesView},
+	"define":     {update: DefineUpdate, view: DefineView},
+	"definition": {update: DefinitionUpdate, view: DefinitionView},
 }
 
 // ---------------------------------------
@@ -43,38 +137,124 @@ var pages = map[string]Page{
 
 type model struct {
 	pageName string
-	client   *Client
+	source   Source
+	history  history
+	popup    listPopup
+	// Terminal size
+	width  int
+	height int
 	// Used in search view
 	textInput textinput.Model
 	Articles  map[int]Article
 	cursor    int
 	info      string
+	// Stops the search in flight
+	cancelSearch context.CancelFunc
+	searching    bool
+	// Stops the article load in flight
+	cancelLoad context.CancelFunc
+	// Pause in typing before searching, and the
+	// number of the latest keypress waiting on it
+	debounce  time.Duration
+	searchSeq int
 	// Article view
 	shownArticle string
-	viewport     viewport.Model
-	ready        bool
-	content      string
+	document     *Document
+	rendering    *Rendering
+	// The lead's infobox when it's beside the article
+	infobox     *Rendering
+	hideInfobox bool
+	// Unknown templates are shown as placeholders
+	showTemplates bool
+	focusedLink   int
+	langLinks     []LangLink
+	// Citation shown in the popup, 1-based
+	citation     int
+	showContents bool
+	// Wikidata facts sidebar, with the title of
+	// the article the facts are for
+	showFacts    bool
+	facts        []Fact
+	factsFor     string
+	factsLoading bool
+	// Where w looks words up, nil when it can't
+	dictionary Source
+	// Articles are Wiktionary entries, shown as dictionary cards
+	wiktionary bool
+	// Card of the word looked up and how far it's scrolled
+	definition    *Rendering
+	definitionTop int
+	viewport      viewport.Model
+	ready         bool
+	content       string
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
 		headerHeight := lipgloss.Height(m.headerView())
 		footerHeight := lipgloss.Height(m.footerView())
 		verticalMarginHeight := headerHeight + footerHeight
 
 		// Wait for window dimensions before initializing viewport
 		if !m.ready {
-			m.viewport = viewport.New(msg.Width, msg.Height-verticalMarginHeight)
+			m.viewport = viewport.New(m.articleWidth(), msg.Height-verticalMarginHeight)
 			m.viewport.YPosition = headerHeight
 			m.viewport.SetContent(m.content)
 			m.ready = true
 			// Render the viewport one line below the header.
 			m.viewport.YPosition = headerHeight + 1
 		} else {
-			m.viewport.Width = msg.Width
+			m.viewport.Width = m.articleWidth()
 			m.viewport.Height = msg.Height - verticalMarginHeight
 		}
+		// Re-wrap the article for the new width
+		m.renderArticle()
+	case articleResponseMsg:
+		// Dropped by leaving the page or pressing Esc
+		if errors.Is(msg.err, context.Canceled) {
+			return m, nil
+		}
+		m.cancelLoad = nil
+		if msg.err != nil {
+			m.info = notFoundInfo(errorInfo(msg.err, m.source.SiteName()), msg.suggestions)
+			return m, nil
+		}
+		// "Cache" the content of search results
+		for i, article := range m.Articles {
+			if article.Title == msg.article.Title {
+				m.Articles[i] = msg.article
+			}
+		}
+		m.visitArticle(msg.article)
+		cmd := m.factsCmd()
+		return m, cmd
+	case factsMsg:
+		m.receiveFacts(msg)
+		return m, nil
+	case definitionMsg:
+		m.showDefinition(msg)
+		return m, nil
+	case tea.KeyMsg:
+		if m.pageName != "search" && m.pageName != "article" {
+			break
+		}
+		switch msg.String() {
+		case "alt+left":
+			return m, m.navigate(-1)
+		case "alt+right":
+			return m, m.navigate(1)
+		case "alt+h":
+			m.openHistory()
+			return m, nil
+		case "alt+l":
+			m.openLanguages()
+			return m, nil
+		case "alt+r":
+			m.info = "Loading a random article..."
+			return m, m.randomArticleCmd()
+		}
 	}
 	// Use Update method of current page
 	if page, ok := pages[m.pageName]; ok {
@@ -91,14 +271,18 @@ func (m model) View() string {
 }
 
 func (m model) Init() tea.Cmd {
-	return textinput.Blink
+	// Search for the starting topic straight away
+	search := func() tea.Msg {
+		return searchTickMsg{seq: m.searchSeq}
+	}
+	return tea.Batch(textinput.Blink, search)
 }
 
 // --------------------
 // Initial model & main
 // --------------------
 
-func initialModel(topic string) model {
+func initialModel(topic string, src Source, debounce time.Duration) model {
 	ti := textinput.New()
 	ti.Placeholder = "Giraffe"
 	ti.Focus()
@@ -106,28 +290,60 @@ func initialModel(topic string) model {
 	ti.Width = 20
 	ti.SetValue(topic)
 
-	client, err := NewClient("en", DefaultWikiUrl, DefaultApiUrl)
-	if err != nil {
-		fmt.Println("fatal:", err)
-		os.Exit(1)
-	}
-
 	var vp viewport.Model
 	vp.Style = lipgloss.NewStyle()
 
 	return model{
 		pageName:  "search",
-		client:    client,
+		source:    src,
 		textInput: ti,
 		Articles:  DefaultArticleMap,
 		content:   "Waiting for content...",
 		ready:     false,
 		viewport:  vp,
+		debounce:  debounce,
 	}
 }
 
 func main() {
+	config, err := userConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "wki:", err)
+		os.Exit(exitError)
+	}
+
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			// Only get, search and define use the API, so a bad
+			// language doesn't stop the cache being cleared
+			var client *Client
+			switch os.Args[1] {
+			case "get", "search", "define":
+				client, err = newClient(defaultLang(config), config)
+			case "cache":
+				client = &Client{}
+				client.cache, err = configCache(config)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "wki:", err)
+				os.Exit(exitUsage)
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			code := command(ctx, client, os.Args[2:], os.Stdout, os.Stderr)
+			stop()
+			os.Exit(code)
+		}
+	}
+
 	topic := flag.String("t", "", "Optional starting topic to search\nExample: wki -t Lions")
+	lang := langFlag(flag.CommandLine, defaultLang(config))
+	wiki := wikiFlag(flag.CommandLine)
+	noCache := cacheFlag(flag.CommandLine)
+	wiktionary := flag.Bool("wiktionary", false, "Look words up in Wiktionary instead of reading Wikipedia")
+	showTemplates := templatesFlag(flag.CommandLine)
+	mode := renderFlag(flag.CommandLine)
+	zimPath := flag.String("zim", "", "Read articles offline from a Kiwix ZIM `archive`\nExample: wki --zim wikipedia_en_all_nopic.zim")
+	dumpDir := flag.String("dump", "", "Read articles offline from a `directory` made by wki index\nExample: wki --dump enwiki-latest-pages-articles")
 	help := flag.Bool("help", false, "Show this help menu")
 	flag.Parse()
 	if *help {
@@ -141,11 +357,87 @@ func main() {
 		os.Exit(1)
 	}
 
+	base, err := newClient(*lang, config)
+	client := base
+	if err == nil {
+		client, err = clientFor(context.Background(), flag.CommandLine, base, *lang, *wiki)
+	}
+	if err != nil {
+		fmt.Println("fatal:", err)
+		os.Exit(1)
+	}
+	if *noCache {
+		base, client = base.withoutCache(), client.withoutCache()
+	}
+	// Words are looked up in Wiktionary, even when reading another wiki
+	var dictionary Source
+	dictionary, err = base.Wiktionary()
+	if err == nil && *wiktionary {
+		client, err = client.Wiktionary()
+		dictionary = client
+	}
+	if err != nil {
+		fmt.Println("fatal:", err)
+		os.Exit(1)
+	}
+	debounce, err := configDuration("debounce", config.Debounce, DefaultDebounce)
+	if err != nil {
+		fmt.Println("fatal:", err)
+		os.Exit(1)
+	}
+	if *zimPath != "" && *dumpDir != "" {
+		fmt.Println("fatal: --zim and --dump can't be used together")
+		os.Exit(1)
+	}
+	if *wiktionary && (*zimPath != "" || *dumpDir != "") {
+		fmt.Println("fatal: --wiktionary can't be used with --zim or --dump")
+		os.Exit(1)
+	}
+	// Dictionary cards are read from wikitext, and dumps have nothing else
+	if *mode == "html" && (*wiktionary || *dumpDir != "") {
+		fmt.Println("fatal: --render=html can't be used with --wiktionary or --dump")
+		os.Exit(1)
+	}
+	if *mode == "html" {
+		client = client.withHTML()
+	}
+	var src Source = client
+	// Wiktionary is online, so there's nothing to look words up in offline
+	if *zimPath != "" || *dumpDir != "" {
+		dictionary = nil
+	}
+	// Closed by hand rather than deferred, as os.Exit skips deferred calls
+	closeSource := func() error { return nil }
+	if *dumpDir != "" {
+		index, err := openDumpIndex(*dumpDir)
+		if err != nil {
+			fmt.Println("fatal:", err)
+			os.Exit(1)
+		}
+		closeSource = index.Close
+		src = index
+	}
+	if *zimPath != "" {
+		archive, err := openZIM(*zimPath)
+		if err != nil {
+			fmt.Println("fatal:", err)
+			os.Exit(1)
+		}
+		closeSource = archive.Close
+		src = archive
+	}
+
+	m := initialModel(*topic, src, debounce)
+	m.dictionary = dictionary
+	m.wiktionary = *wiktionary
+	m.showTemplates = *showTemplates
 	p := tea.NewProgram(
-		initialModel(*topic),
+		m,
 		tea.WithAltScreen(),
 	)
-	if _, err := p.Run(); err != nil {
+	_, err = p.Run()
+	closeSource()
+	if err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func testSource() *fakeSource {
+	german := &fakeSource{lang: "de", articles: map[string]string{
+		"Giraffen": "Die '''Giraffen''' sind Paarhufer.",
+	}}
+	return &fakeSource{
+		lang: "en",
+		articles: map[string]string{
+			"Giraffe":        "The '''giraffe''' lives on the [[Savanna|savanna]].",
+			"Giraffe family": "Giraffes and okapis.",
+			"Savanna":        "A grassland.",
+		},
+		langLinks: map[string][]LangLink{"Giraffe": {{Lang: "de", Title: "Giraffen"}}},
+		others:    map[string]*fakeSource{"de": german},
+	}
+}
+
+// Runs cmd and feeds the messages the pages act on back into m,
+// along with whatever they lead to. Cursor blinks are left out.
+func process(m tea.Model, cmd tea.Cmd) tea.Model {
+	if cmd == nil {
+		return m
+	}
+	switch msg := cmd().(type) {
+	case tea.BatchMsg:
+		for _, cmd := range msg {
+			m = process(m, cmd)
+		}
+	case searchTickMsg, apiResponseMsg, articleResponseMsg, definitionMsg, factsMsg:
+		m, cmd = m.Update(msg)
+		m = process(m, cmd)
+	}
+	return m
+}
+
+// Sends a key press as the terminal would and handles what follows
+func press(m tea.Model, key string) tea.Model {
+	var msg tea.KeyMsg
+	switch key {
+	case "enter":
+		msg = tea.KeyMsg{Type: tea.KeyEnter}
+	case "tab":
+		msg = tea.KeyMsg{Type: tea.KeyTab}
+	case "left":
+		msg = tea.KeyMsg{Type: tea.KeyLeft}
+	case "alt+r":
+		msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}, Alt: true}
+	default:
+		msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+	}
+	// A blinking cursor in a popup's filter would keep the tests waiting
+	if shown, ok := m.(model); ok {
+		shown.popup.filter.Cursor.SetMode(cursor.CursorStatic)
+		m = shown
+	}
+	m, cmd := m.Update(msg)
+	return process(m, cmd)
+}
+
+func newTestModel(src Source) tea.Model {
+	m := initialModel("", src, time.Millisecond)
+	// A blinking cursor would keep the tests waiting
+	m.textInput.Cursor.SetMode(cursor.CursorStatic)
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	return next
+}
+
+func TestModelSearchAndRead(t *testing.T) {
+	m := newTestModel(testSource())
+	for _, key := range []string{"g", "i", "r"} {
+		m = press(m, key)
+	}
+	view := m.View()
+	if !strings.Contains(view, "Search the English Wikipedia") || !strings.Contains(view, "Giraffe family") {
+		t.Fatalf("search page doesn't list the matches:\n%s", view)
+	}
+
+	m = press(m, "enter")
+	if got := m.(model); got.pageName != "article" || got.shownArticle != "Giraffe" {
+		t.Fatalf("enter showed %q on the %s page, expected the Giraffe article", got.shownArticle, got.pageName)
+	}
+	if view := m.View(); !strings.Contains(view, "lives on the savanna") {
+		t.Fatalf("article page doesn't show the article:\n%s", view)
+	}
+
+	// Follow the link, then come back
+	m = press(press(m, "tab"), "enter")
+	if got := m.(model).shownArticle; got != "Savanna" {
+		t.Fatalf("following the link showed %q, expected Savanna", got)
+	}
+	m = press(m, "left")
+	if got := m.(model).shownArticle; got != "Giraffe" {
+		t.Fatalf("going back showed %q, expected Giraffe", got)
+	}
+}
+
+func TestModelStopLoading(t *testing.T) {
+	m := newTestModel(testSource())
+	for _, key := range []string{"g", "i", "r"} {
+		m = press(m, key)
+	}
+	m, load := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, quit := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if got := m.(model); quit != nil || got.cancelLoad != nil || got.info != "" {
+		t.Fatalf("esc while loading quit or left the load going with info %q", got.info)
+	}
+	m = process(m, load)
+	if got := m.(model); got.pageName != "search" {
+		t.Fatalf("the stopped load showed %q on the %s page", got.shownArticle, got.pageName)
+	}
+	if _, quit := m.Update(tea.KeyMsg{Type: tea.KeyEsc}); quit == nil {
+		t.Fatalf("esc with nothing loading didn't quit")
+	}
+}
+
+func TestModelNotFound(t *testing.T) {
+	m := newTestModel(testSource()).(model)
+	got := process(m, m.loadArticleCmd(Article{Title: "giraffe"})).(model)
+	if !strings.Contains(got.info, "Did you mean: Giraffe, Giraffe family?") {
+		t.Fatalf("loading a missing article left info %q, expected close matches", got.info)
+	}
+}
+
+func TestModelRandomArticle(t *testing.T) {
+	m := press(newTestModel(testSource()), "alt+r")
+	if got := m.(model); got.pageName != "article" || got.shownArticle != "Giraffe" {
+		t.Fatalf("alt+r showed %q on the %s page, expected the Giraffe article", got.shownArticle, got.pageName)
+	}
+}
+
+func TestModelLangLinks(t *testing.T) {
+	m := press(newTestModel(testSource()), "alt+r")
+	m = press(press(m, "L"), "enter")
+	got := m.(model)
+	if got.shownArticle != "Giraffen" || got.source.Language() != "de" {
+		t.Fatalf("reading in German showed %q from %q, expected Giraffen from de", got.shownArticle, got.source.Language())
+	}
+
+	// Languages the source doesn't have are an error, not a crash
+	if cmd := got.switchLang("fr"); cmd != nil || got.info == "" || got.source.Language() != "de" {
+		t.Fatalf("switching to a missing language left info %q and language %q", got.info, got.source.Language())
+	}
+	got.showArticle(Article{Title: "Girafe", Lang: "fr", Document: ParseWikitext("La girafe.")})
+	if got.info == "" || got.source.Language() != "de" {
+		t.Fatalf("showing an article in a missing language left info %q and language %q", got.info, got.source.Language())
+	}
+}
+
+func TestModelDefine(t *testing.T) {
+	m := newTestModel(testSource())
+	// Offline there's no dictionary to look in
+	if got := press(press(m, "alt+r"), "w").(model); got.pageName != "article" || got.info != "Words can't be looked up offline" {
+		t.Fatalf("w without a dictionary showed the %s page with info %q", got.pageName, got.info)
+	}
+	dictionary := &fakeSource{lang: "en", articles: map[string]string{
+		"savanna": "==English==\n===Noun===\n# A grassland with scattered trees.",
+		"lives":   "==English==\n===Verb===\n# {{lb|en|third-person singular}} of live",
+	}}
+	withDictionary := m.(model)
+	withDictionary.dictionary = dictionary
+	m = press(withDictionary, "alt+r")
+
+	// The selected link's word comes first
+	m = press(press(press(m, "tab"), "w"), "enter")
+	if got := m.(model).pageName; got != "definition" {
+		t.Fatalf("w showed the %s page, expected the definition", got)
+	}
+	if view := m.View(); !strings.Contains(view, "A grassland with scattered trees.") {
+		t.Fatalf("definition popup doesn't show the definition:\n%s", view)
+	}
+
+	// Then the words in view
+	m = press(press(m, "esc"), "w")
+	for _, key := range []string{"l", "i", "v"} {
+		m = press(m, key)
+	}
+	m = press(m, "enter")
+	if view := m.View(); !strings.Contains(view, "(third-person singular) of live") {
+		t.Fatalf("definition popup doesn't show the picked word's definition:\n%s", view)
+	}
+}
+
+func TestModelWiktionary(t *testing.T) {
+	src := &fakeSource{lang: "en", articles: map[string]string{"giraffe": testEntry}}
+	m := newTestModel(src)
+	wiktionary := m.(model)
+	wiktionary.wiktionary = true
+	m = press(wiktionary, "alt+r")
+	if view := m.View(); !strings.Contains(view, "1. (zoology) A tall African mammal") {
+		t.Fatalf("article page doesn't show the dictionary card:\n%s", view)
+	}
+}
+
+func TestModelFacts(t *testing.T) {
+	src := testSource()
+	src.facts = map[string][]Fact{"Giraffe": {{Label: "Parent taxon", Values: []string{"Giraffa"}}}}
+	m := press(press(newTestModel(src), "alt+r"), "i")
+	if view := m.View(); !strings.Contains(view, "Parent taxon") || !strings.Contains(view, "Giraffa") {
+		t.Fatalf("article page doesn't show the facts:\n%s", view)
+	}
+
+	// Facts follow the article shown
+	m = press(press(m, "tab"), "enter")
+	if view := m.View(); !strings.Contains(view, "No facts from Wikidata") {
+		t.Fatalf("article page shows facts of another article:\n%s", view)
+	}
+	m = press(m, "i")
+	if view := m.View(); strings.Contains(view, "Facts") {
+		t.Fatalf("i didn't hide the facts:\n%s", view)
+	}
+}
+
+func TestModelInfobox(t *testing.T) {
+	src := testSource()
+	src.articles["Giraffe"] = "{{Infobox animal\n| name = Giraffe\n| genus = Giraffa\n}}\nThe '''giraffe''' lives on the [[Savanna|savanna]]."
+	m := press(newTestModel(src), "alt+r")
+	view := m.View()
+	if !strings.Contains(view, "│ Genus │ Giraffa │") || strings.Index(view, "Giraffa") > strings.Index(view, "lives on") {
+		t.Fatalf("article page doesn't show the infobox above the article:\n%s", view)
+	}
+
+	// Wide windows have it beside the article
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 160, Height: 24})
+	lines := strings.Split(m.View(), "\n")
+	if !slices.ContainsFunc(lines, func(line string) bool {
+		return strings.Contains(line, "lives on") && strings.Contains(line, "┌")
+	}) {
+		t.Fatalf("article page doesn't show the infobox beside the article:\n%s", m.View())
+	}
+
+	m = press(m, "I")
+	if view := m.View(); strings.Contains(view, "Giraffa") {
+		t.Fatalf("I didn't hide the infobox:\n%s", view)
+	}
+}
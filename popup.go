@@ -0,0 +1,154 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// listPopup is a list of choices drawn in a box over the page
+type listPopup struct {
+	title string
+	items []string
+	// Index into matches
+	cursor int
+	// Typing narrows down the items when filtering
+	filtering bool
+	filter    textinput.Model
+	// Indexes into items matching the filter, best first
+	matches []int
+}
+
+func newListPopup(title string, items []string, cursor int) listPopup {
+	p := listPopup{title: title, items: items}
+	p.refilter()
+	p.cursor = max(0, min(cursor, len(items)-1))
+	return p
+}
+
+func newFilterPopup(title string, placeholder string, items []string) listPopup {
+	ti := textinput.New()
+	ti.Placeholder = placeholder
+	ti.Focus()
+	p := listPopup{title: title, items: items, filtering: true, filter: ti}
+	p.refilter()
+	return p
+}
+
+func (p *listPopup) move(step int) {
+	p.cursor = max(0, min(p.cursor+step, len(p.matches)-1))
+}
+
+// Index into items of the item under the cursor
+func (p listPopup) selected() (int, bool) {
+	if len(p.matches) == 0 {
+		return 0, false
+	}
+	return p.matches[p.cursor], true
+}
+
+// Passes typing on to the filter
+func (p *listPopup) updateFilter(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	p.filter, cmd = p.filter.Update(msg)
+	p.refilter()
+	return cmd
+}
+
+func (p *listPopup) refilter() {
+	type match struct {
+		index int
+		score int
+	}
+	var matches []match
+	for i, item := range p.items {
+		if score, ok := fuzzyScore(p.filter.Value(), item); ok {
+			matches = append(matches, match{i, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	p.matches = p.matches[:0]
+	for _, m := range matches {
+		p.matches = append(p.matches, m.index)
+	}
+	p.cursor = 0
+}
+
+func (p listPopup) view(width int, height int) string {
+	// Only a window of items around the cursor fits
+	rows := max(height-8, 1)
+	start := max(0, min(p.cursor-rows/2, len(p.matches)-rows))
+	end := min(start+rows, len(p.matches))
+	itemStyle := lipgloss.NewStyle().MaxWidth(max(width-10, 10))
+
+	s := articleBoldedStyle(p.title) + "\n"
+	if p.filtering {
+		s += "\n" + p.filter.View() + "\n"
+	}
+	if len(p.matches) == 0 {
+		s += "\n" + noteStyle("Nothing here")
+	}
+	for i := start; i < end; i++ {
+		cursor := " "
+		if p.cursor == i {
+			cursor = "*"
+		}
+		s += "\n" + itemStyle.Render(cursor+" "+p.items[p.matches[i]])
+	}
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, popupStyle.Render(s))
+}
+
+// Scores how well pattern matches s as a case-insensitive
+// subsequence. Runs of letters and letters starting words
+// count for more. An empty pattern matches everything.
+func fuzzyScore(pattern string, s string) (int, bool) {
+	want := []rune(strings.ToLower(pattern))
+	have := []rune(strings.ToLower(s))
+	if len(want) == 0 {
+		return 0, true
+	}
+
+	// best[i] is the top score for the pattern so far
+	// with its last letter matched at have[i], -1 if none
+	best := make([]int, len(have))
+	for j, c := range want {
+		next := make([]int, len(have))
+		earlier := -1 // top of best[:i-1]
+		for i := range have {
+			next[i] = -1
+			if i >= 2 {
+				earlier = max(earlier, best[i-2])
+			}
+			if have[i] != c {
+				continue
+			}
+			score := 1
+			if i == 0 || !unicode.IsLetter(have[i-1]) && !unicode.IsDigit(have[i-1]) {
+				score += 3
+			}
+			switch {
+			case j == 0:
+				next[i] = score
+			case i > 0 && best[i-1] >= 0 && best[i-1]+2 >= earlier:
+				next[i] = best[i-1] + 2 + score
+			case earlier >= 0:
+				next[i] = earlier + score
+			}
+		}
+		best = next
+	}
+
+	top := slices.Max(append(best, -1))
+	if top < 0 {
+		return 0, false
+	}
+	// Prefer shorter candidates among equals
+	return top*100 - len(have), true
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestFuzzyScore(t *testing.T) {
+	items := []string{"Early life", "Etymology", "History", "Legacy", "External links"}
+	tests := map[string]struct {
+		pattern string
+		best    string
+		matches int
+	}{
+		"empty pattern matches everything": {pattern: "", best: "Early life", matches: 5},
+		"subsequence":                      {pattern: "ety", best: "Etymology", matches: 1},
+		"case insensitive":                 {pattern: "HIST", best: "History", matches: 1},
+		"word starts":                      {pattern: "el", best: "Early life", matches: 3},
+		"no match":                         {pattern: "xyz", matches: 0},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newListPopup("test", items, 0)
+			p.filter.SetValue(test.pattern)
+			p.refilter()
+			if len(p.matches) != test.matches {
+				t.Fatalf("%q matched %d items, expected %d", test.pattern, len(p.matches), test.matches)
+			}
+			if i, ok := p.selected(); ok && items[i] != test.best {
+				t.Fatalf("best match for %q = %q, expected %q", test.pattern, items[i], test.best)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Requests per second to the API, and how many may go at once
+// after a quiet spell
+const (
+	defaultRequestRate  = 5
+	defaultRequestBurst = 5
+)
+
+// rateLimiter is a token bucket: each request takes a token,
+// and tokens come back at a steady rate up to the burst size
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// Takes a token at now, returning how long to wait before using it
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Gives back a token a request reserved but didn't use
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.burst, l.tokens+1)
+}
+
+// Blocks until a request may be made or ctx is done. Requests
+// given up on, like stale searches, don't count against the rate.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	delay := l.reserve(time.Now())
+	if delay == 0 {
+		if err := ctx.Err(); err != nil {
+			l.cancel()
+			return err
+		}
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
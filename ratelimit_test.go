@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		// Offsets from start of each request
+		at    []time.Duration
+		waits []time.Duration
+	}{
+		"burst goes through": {
+			at:    []time.Duration{0, 0},
+			waits: []time.Duration{0, 0},
+		},
+		"then requests queue up": {
+			at:    []time.Duration{0, 0, 0, 0},
+			waits: []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		"tokens come back": {
+			at:    []time.Duration{0, 0, 0, 300 * time.Millisecond},
+			waits: []time.Duration{0, 0, 100 * time.Millisecond, 0},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			l := newRateLimiter(10, 2)
+			for i, at := range test.at {
+				if got := l.reserve(start.Add(at)); got != test.waits[i] {
+					t.Fatalf("request %d waits %v, expected %v", i, got, test.waits[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	// One token, back after a second
+	l := newRateLimiter(1, 1)
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait() error = %v, expected context.DeadlineExceeded", err)
+	}
+	// The cancelled wait's token is back, so the next
+	// waits a second at most rather than two
+	if got := l.reserve(time.Now()); got > time.Second {
+		t.Fatalf("next request waits %v, expected at most 1s", got)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// <ref> tags turn into numbered markers like [1] and are listed
+// where the article asks for {{reflist}} or <references />,
+// or in a References section at the end when it doesn't.
+
+// Citation is the source a <ref> points to
+type Citation struct {
+	// name attribute, for refs used more than once
+	Name   string
+	Title  string
+	Author string
+	Date   string
+	// Journal, website or publisher the source appeared in
+	Work string
+	URL  string
+	DOI  string
+	// Content of refs that don't use a cite template
+	Text []Node
+}
+
+func (c Citation) empty() bool {
+	return c.Title == "" && c.Author == "" && c.URL == "" && c.DOI == "" && len(c.Text) == 0
+}
+
+func paramText(t *Template, names ...string) string {
+	for _, name := range names {
+		value, _ := t.Named(name)
+		if s := strings.TrimSpace(PlainText(value)); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// Authors given as author, author1..., or last and first name pairs
+func citationAuthors(t *Template) string {
+	var authors []string
+	for i := 0; i < 10; i++ {
+		n := ""
+		if i > 0 {
+			n = fmt.Sprint(i)
+		}
+		author := paramText(t, "author"+n)
+		if last := paramText(t, "last"+n); author == "" && last != "" {
+			author = last
+			if first := paramText(t, "first"+n); first != "" {
+				author += ", " + first
+			}
+		}
+		if author != "" {
+			authors = append(authors, author)
+		}
+	}
+	if len(authors) == 0 {
+		return paramText(t, "authors", "vauthors")
+	}
+	return strings.Join(authors, "; ")
+}
+
+// Reads the fields of a {{cite ...}} template, falling
+// back to the ref's own content without one
+func newCitation(name string, content []Node) Citation {
+	c := Citation{Name: name}
+	for _, node := range content {
+		t, ok := node.(*Template)
+		if !ok {
+			continue
+		}
+		if key := t.Key(); !strings.HasPrefix(key, "cite ") && key != "citation" {
+			continue
+		}
+		c.Title = paramText(t, "title", "chapter")
+		c.Author = citationAuthors(t)
+		c.Date = paramText(t, "date", "year")
+		c.Work = paramText(t, "journal", "website", "work", "newspaper", "magazine", "publisher")
+		c.URL = paramText(t, "url")
+		c.DOI = paramText(t, "doi")
+		return c
+	}
+	c.Text = trimNodes(content)
+	return c
+}
+
+// Labelled fields of a citation, for showing it on its own
+func (c Citation) fields() [][2]string {
+	var fields [][2]string
+	add := func(label string, value string) {
+		if value != "" {
+			fields = append(fields, [2]string{label, value})
+		}
+	}
+	add("Title", c.Title)
+	add("Author", c.Author)
+	add("Date", c.Date)
+	add("Source", c.Work)
+	add("URL", c.URL)
+	if c.DOI != "" {
+		add("DOI", c.DOI+" (https://doi.org/"+c.DOI+")")
+	}
+	if len(fields) == 0 {
+		add("Text", strings.TrimSpace(PlainText(c.Text)))
+	}
+	return fields
+}
+
+// Number of the citation a ref tag refers to, defining
+// it if the tag has content and it isn't known yet
+func (r *renderer) cite(t *Tag) int {
+	name := strings.TrimSpace(t.Attrs["name"])
+	n, ok := r.refNames[name]
+	if name == "" || !ok {
+		r.citations = append(r.citations, Citation{Name: name})
+		n = len(r.citations)
+		if name != "" {
+			r.refNames[name] = n
+		}
+	}
+	if len(t.Children) > 0 && r.citations[n-1].empty() {
+		r.citations[n-1] = newCitation(name, t.Children)
+	}
+	return n
+}
+
+// A ref's marker, which can be selected like a link
+func (r *renderer) ref(t *Tag) []segment {
+	n := r.cite(t)
+	r.links = append(r.links, RenderedLink{Citation: n})
+	return []segment{{text: fmt.Sprintf("[%d]", n), format: formatNote, link: len(r.links)}}
+}
+
+// Takes in refs defined inside a reference list,
+// as in {{reflist|refs=<ref name=a>...</ref>}}
+func (r *renderer) defineRefs(nodes []Node) {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *Tag:
+			if name := strings.TrimSpace(n.Attrs["name"]); n.Name == "ref" && name != "" {
+				if i, ok := r.refNames[name]; ok && len(n.Children) > 0 && r.citations[i-1].empty() {
+					r.citations[i-1] = newCitation(name, n.Children)
+				}
+				continue
+			}
+			r.defineRefs(n.Children)
+		case *Template:
+			for _, param := range n.Params {
+				r.defineRefs(param.Value)
+			}
+		case *Paragraph:
+			r.defineRefs(n.Children)
+		}
+	}
+}
+
+// Whether a paragraph stands in for the list of references
+func referenceList(nodes []Node) (Node, bool) {
+	var found Node
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *Text:
+			if strings.TrimSpace(n.Value) == "" {
+				continue
+			}
+		case *Template:
+			switch n.Key() {
+			case "reflist", "references", "notelist":
+				if found == nil {
+					found = n
+					continue
+				}
+			}
+		case *Tag:
+			if n.Name == "references" && found == nil {
+				found = n
+				continue
+			}
+		}
+		return nil, false
+	}
+	return found, found != nil
+}
+
+// Lists the citations not listed yet, numbered like their markers
+func (r *renderer) references() [][]segment {
+	width := len(fmt.Sprint(len(r.citations)))
+	var lines [][]segment
+	for i := r.listed; i < len(r.citations); i++ {
+		number := fmt.Sprintf("%*d. ", width, i+1)
+		hang := strings.Repeat(" ", len(number))
+		lines = append(lines, wrap(r.citation(r.citations[i]), r.width, number, hang)...)
+	}
+	r.listed = len(r.citations)
+	return lines
+}
+
+// A citation written out as a line of a reference list
+func (r *renderer) citation(c Citation) []segment {
+	if len(c.Text) > 0 {
+		return r.inline(c.Text, 0)
+	}
+	var segs []segment
+	add := func(text string, format textFormat) {
+		segs = append(segs, segment{text: text, format: format})
+	}
+	if c.Author != "" {
+		add(c.Author+" ", 0)
+	}
+	if c.Date != "" {
+		add("("+c.Date+"). ", 0)
+	} else if c.Author != "" {
+		segs[len(segs)-1].text = c.Author + ". "
+	}
+	if c.Title != "" {
+		add(strings.TrimSuffix(c.Title, "."), formatItalic)
+		add(". ", 0)
+	}
+	if c.Work != "" && c.Work != c.Title {
+		add(strings.TrimSuffix(c.Work, ".")+". ", 0)
+	}
+	if c.URL != "" {
+		add(c.URL, formatLink)
+		add(" ", 0)
+	}
+	if c.DOI != "" {
+		add("doi:"+c.DOI, 0)
+	}
+	if len(segs) == 0 {
+		add("Citation not found", formatNote)
+	}
+	return segs
+}
+
+// Shows the citation behind the nth (1-based) link
+func (m *model) openCitation(link int) {
+	m.citation = m.rendering.Links[link-1].Citation
+	m.pageName = "citation"
+}
+
+func CitationView(m model) string {
+	width := max(min(m.width-10, 72), 10)
+	labelStyle := lipgloss.NewStyle().Width(8)
+	valueStyle := lipgloss.NewStyle().Width(width - 8)
+
+	rows := []string{articleBoldedStyle(fmt.Sprintf("Citation [%d]", m.citation)), ""}
+	fields := m.rendering.Citations[m.citation-1].fields()
+	if len(fields) == 0 {
+		rows = append(rows, noteStyle("Citation not found"))
+	}
+	for _, field := range fields {
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top,
+			labelStyle.Render(noteStyle(field[0])), valueStyle.Render(field[1])))
+	}
+	rows = append(rows, "", noteStyle("esc to close"))
+	s := popupStyle.Render(strings.Join(rows, "\n"))
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, s)
+}
+
+func CitationUpdate(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "enter", "q":
+		m.pageName = "article"
+	}
+	return m, nil
+}
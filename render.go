@@ -0,0 +1,561 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// textFormat flags how a run of rendered text is styled
+type textFormat uint16
+
+const (
+	formatBold textFormat = 1 << iota
+	formatItalic
+	formatLink
+	formatDescription
+	formatHeading
+	// Borders and other secondary text
+	formatNote
+	// A hard line break such as <br>
+	formatBreak
+)
+
+// segment is a run of text sharing one format
+type segment struct {
+	text   string
+	format textFormat
+	// 1-based index into Rendering.Links, 0 for plain text
+	link int
+}
+
+func (s segment) sameStyle(other segment) bool {
+	return s.format == other.format && s.link == other.link
+}
+
+// Rendering is a Document laid out as terminal lines
+type Rendering struct {
+	Lines     [][]segment
+	Links     []RenderedLink
+	Sections  []RenderedSection
+	Citations []Citation
+}
+
+// RenderedLink is a followable wikilink or a footnote
+// marker, and the first line it appears on
+type RenderedLink struct {
+	Target string
+	// 1-based index into Rendering.Citations for markers
+	Citation int
+	Line     int
+}
+
+// RenderedSection is a heading and the line it's on
+type RenderedSection struct {
+	Title string
+	Level int
+	Line  int
+}
+
+// Styles each line and joins them
+func (r *Rendering) String() string {
+	return r.Highlighted(0)
+}
+
+// Like String, but with the nth (1-based) link highlighted
+func (r *Rendering) Highlighted(link int) string {
+	lines := make([]string, len(r.Lines))
+	for i, line := range r.Lines {
+		var b strings.Builder
+		for _, seg := range mergeSegments(line) {
+			style := styleFor(seg.format)
+			if link > 0 && seg.link == link {
+				style = highlightedLinkStyle
+			}
+			b.WriteString(style(seg.text))
+		}
+		lines[i] = b.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func styleFor(format textFormat) func(...string) string {
+	switch {
+	case format&formatDescription != 0:
+		return articleDescriptionStyle
+	case format&formatHeading != 0:
+		return articleHeadingStyle
+	case format&formatNote != 0:
+		return noteStyle
+	case format&formatLink != 0:
+		return linkStyle
+	case format&formatBold != 0 && format&formatItalic != 0:
+		return articleBoldedItalicStyle
+	case format&formatBold != 0:
+		return articleBoldedStyle
+	case format&formatItalic != 0:
+		return articleItalicStyle
+	}
+	return plainStyle
+}
+
+func plainStyle(strs ...string) string {
+	return strings.Join(strs, " ")
+}
+
+func mergeSegments(line []segment) []segment {
+	var merged []segment
+	for _, seg := range line {
+		if n := len(merged); n > 0 && merged[n-1].sameStyle(seg) {
+			merged[n-1].text += seg.text
+			continue
+		}
+		merged = append(merged, seg)
+	}
+	return merged
+}
+
+// renderer lays out a Document at a given width
+type renderer struct {
+	// 0 disables wrapping
+	width int
+	lines [][]segment
+	// Links seen so far, lines yet to be filled in
+	links []RenderedLink
+	// Link to attach to the segments being rendered
+	link     int
+	sections []RenderedSection
+	// Citations in order of first use, numbered by ref name
+	citations []Citation
+	refNames  map[string]int
+	// Citations shown in a reference list so far
+	listed  int
+	options renderOptions
+	// Left out of the lines when the infobox is aside
+	lead *Template
+}
+
+// Renders a Document as lines no wider than width,
+// or as unwrapped lines when width is 0
+func Render(doc *Document, width int) *Rendering {
+	return render(doc, width, renderOptions{})
+}
+
+// How a Document is rendered besides its width
+type renderOptions struct {
+	infobox infoboxLayout
+	// Shows templates we can't expand as dim {{name}}s
+	placeholders bool
+}
+
+func render(doc *Document, width int, options renderOptions) *Rendering {
+	r := &renderer{width: width, refNames: map[string]int{}, options: options}
+	if options.infobox == infoboxAside {
+		r.lead = leadInfobox(doc)
+	}
+	r.blocks(doc.Children)
+	if r.listed < len(r.citations) {
+		r.section(&Section{Level: 2, Title: []Node{&Text{Value: "References"}}})
+		r.block(r.references())
+	}
+	rendering := &Rendering{
+		Lines:     r.lines,
+		Links:     r.links,
+		Sections:  r.sections,
+		Citations: r.citations,
+	}
+	for i := range rendering.Links {
+		rendering.Links[i].Line = -1
+	}
+	for i, line := range r.lines {
+		for _, seg := range line {
+			if seg.link > 0 && rendering.Links[seg.link-1].Line < 0 {
+				rendering.Links[seg.link-1].Line = i
+			}
+		}
+	}
+	return rendering
+}
+
+// Adds a block of lines, separated from the previous one by a blank line
+func (r *renderer) block(lines [][]segment) {
+	if len(lines) == 0 {
+		return
+	}
+	if len(r.lines) > 0 {
+		r.lines = append(r.lines, nil)
+	}
+	r.lines = append(r.lines, lines...)
+}
+
+func (r *renderer) blocks(nodes []Node) {
+	// Loose inline nodes, e.g. in a table cell, form a paragraph
+	var inline []Node
+	flush := func() {
+		if len(inline) > 0 {
+			r.paragraph(inline)
+			inline = nil
+		}
+	}
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *Section:
+			flush()
+			r.section(n)
+		case *Paragraph:
+			flush()
+			if list, ok := referenceList(n.Children); ok {
+				r.defineRefs([]Node{list})
+				r.block(r.references())
+				continue
+			}
+			r.paragraph(n.Children)
+		case *List:
+			flush()
+			r.block(r.list(n, "", 0))
+		case *Table:
+			flush()
+			r.block(r.table(n))
+		case *HorizontalRule:
+			flush()
+			r.block([][]segment{{{text: strings.Repeat("─", max(r.width, 3))}}})
+		default:
+			inline = append(inline, node)
+		}
+	}
+	flush()
+}
+
+// The short description and infoboxes get blocks of their
+// own, even though they share a paragraph with the lead
+func (r *renderer) paragraph(nodes []Node) {
+	start := 0
+	for i, node := range nodes {
+		t, ok := node.(*Template)
+		switch {
+		case ok && isShortDescription(t):
+			r.block(wrap(r.inline(nodes[start:i], 0), r.width, "", ""))
+			r.block(wrap(r.inline(nodes[i:i+1], 0), r.width, "", ""))
+			start = i + 1
+		case ok && isInfoboxCard(t):
+			r.block(wrap(r.inline(nodes[start:i], 0), r.width, "", ""))
+			if r.options.infobox == infoboxInline || r.options.infobox == infoboxAside && t != r.lead {
+				r.block(r.infobox(t))
+			}
+			start = i + 1
+		}
+	}
+	r.block(wrap(r.inline(nodes[start:], 0), r.width, "", ""))
+}
+
+func (r *renderer) section(s *Section) {
+	title := wrap(r.inline(s.Title, formatHeading), r.width, "", "")
+	r.block(title)
+	if len(title) > 0 {
+		r.sections = append(r.sections, RenderedSection{
+			Title: strings.TrimSpace(PlainText(s.Title)),
+			Level: s.Level,
+			Line:  len(r.lines) - len(title),
+		})
+	}
+	r.blocks(s.Children)
+}
+
+// Bullets for each level of nesting, repeating when lists nest deeper
+var bullets = []string{"•", "◦", "▪"}
+
+// Lays out a list with its items hanging off their bullets
+// or numbers. Nested lists line up with their parent's text.
+func (r *renderer) list(l *List, indent string, depth int) [][]segment {
+	// Numbers are padded to line up, and restart
+	// whenever another kind of item comes between
+	numbered := 0
+	hasTerms := false
+	for _, item := range l.Items {
+		if item.Marker == '#' {
+			numbered++
+		}
+		hasTerms = hasTerms || item.Marker == ';'
+	}
+	numberWidth := len(strconv.Itoa(numbered))
+
+	var lines [][]segment
+	number := 0
+	for _, item := range l.Items {
+		itemIndent := indent
+		marker := ""
+		format := textFormat(0)
+		switch item.Marker {
+		case '#':
+			number++
+			marker = fmt.Sprintf("%*d. ", numberWidth, number)
+		case '*':
+			marker = bullets[depth%len(bullets)] + " "
+		case ';':
+			format = formatBold
+		case ':':
+			// Definitions and indented lines step in,
+			// continuations of list items don't
+			if hasTerms || depth == 0 {
+				itemIndent += "  "
+			}
+		}
+		if item.Marker != '#' {
+			number = 0
+		}
+
+		hang := itemIndent + strings.Repeat(" ", lipgloss.Width(marker))
+		if len(item.Children) > 0 {
+			lines = append(lines, wrap(r.inline(item.Children, format), r.width, itemIndent+marker, hang)...)
+		}
+		if item.Sublist != nil {
+			lines = append(lines, r.list(item.Sublist, hang, depth+1)...)
+		}
+	}
+	return lines
+}
+
+// Joins the inline content of block nodes
+func flattenBlocks(nodes []Node) []Node {
+	var inline []Node
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *Paragraph:
+			inline = append(inline, n.Children...)
+		case *List:
+			for _, item := range n.Items {
+				inline = append(inline, item.Children...)
+			}
+		case *Table:
+			for _, row := range n.Rows {
+				for _, cell := range row.Cells {
+					inline = append(inline, flattenBlocks(cell.Children)...)
+				}
+			}
+		case *Section, *HorizontalRule:
+		default:
+			inline = append(inline, node)
+		}
+		inline = append(inline, &Text{Value: " "})
+	}
+	return inline
+}
+
+func (r *renderer) inline(nodes []Node, format textFormat) []segment {
+	var segs []segment
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *Text:
+			segs = append(segs, segment{text: n.Value, format: format, link: r.link})
+		case *Bold:
+			segs = append(segs, r.inline(n.Children, format|formatBold)...)
+		case *Italic:
+			segs = append(segs, r.inline(n.Children, format|formatItalic)...)
+		case *Link:
+			if hiddenLink(n.Target) {
+				continue
+			}
+			label := n.Children
+			if len(label) == 0 {
+				label = []Node{&Text{Value: n.Target}}
+			}
+			// Links inside links, e.g. in a caption, aren't followable
+			outer := r.link
+			if target := linkTitle(n.Target); target != "" && outer == 0 {
+				r.links = append(r.links, RenderedLink{Target: target})
+				r.link = len(r.links)
+			}
+			segs = append(segs, r.inline(label, format|formatLink)...)
+			r.link = outer
+		case *ExternalLink:
+			label := n.Children
+			if len(label) == 0 {
+				label = []Node{&Text{Value: n.URL}}
+			}
+			segs = append(segs, r.inline(label, format|formatLink)...)
+		case *Template:
+			templateFormat := format
+			if isShortDescription(n) {
+				templateFormat |= formatDescription
+			}
+			expanded, known := expandTemplate(n)
+			if !known && r.options.placeholders {
+				segs = append(segs, segment{text: "{{" + strings.TrimSpace(n.Name) + "}}", format: formatNote, link: r.link})
+				continue
+			}
+			segs = append(segs, r.inline(expanded, templateFormat)...)
+		case *Tag:
+			segs = append(segs, r.tag(n, format)...)
+		case *Paragraph:
+			segs = append(segs, r.inline(n.Children, format)...)
+		}
+	}
+	return segs
+}
+
+func (r *renderer) tag(t *Tag, format textFormat) []segment {
+	switch t.Name {
+	case "ref":
+		return r.ref(t)
+	case "gallery", "includeonly", "templatedata", "templatestyles",
+		"timeline", "graph", "mapframe", "imagemap", "inputbox", "score":
+		return nil
+	case "br":
+		return []segment{{text: "\n", format: formatBreak, link: r.link}}
+	case "b", "strong":
+		format |= formatBold
+	case "i", "em", "cite", "var":
+		format |= formatItalic
+	}
+	return r.inline(t.Children, format)
+}
+
+// Files, categories and interlanguage links aren't part of the text
+func hiddenLink(target string) bool {
+	namespace, _, found := strings.Cut(target, ":")
+	if !found {
+		return false
+	}
+	namespace = strings.ToLower(strings.TrimSpace(namespace))
+	switch namespace {
+	case "file", "image", "category", "media":
+		return true
+	}
+	_, isLang := WikipediaLangs[namespace]
+	return isLang
+}
+
+// Title of the article a link target points at. Links within
+// the same article, like [[#History]], have none.
+func linkTitle(target string) string {
+	title, _, _ := strings.Cut(strings.TrimPrefix(target, ":"), "#")
+	return strings.TrimSpace(title)
+}
+
+// A wrapped word, possibly made of differently styled runs
+type word struct {
+	segs  []segment
+	width int
+	// Whitespace came before the word
+	space bool
+	// Hard line break
+	brk bool
+}
+
+func splitWords(segs []segment) []word {
+	var words []word
+	var cur word
+	space := false
+	flush := func() {
+		if len(cur.segs) > 0 {
+			words = append(words, cur)
+		}
+		cur = word{}
+	}
+	for _, seg := range segs {
+		if seg.format&formatBreak != 0 {
+			flush()
+			words = append(words, word{brk: true})
+			space = false
+			continue
+		}
+		for _, c := range seg.text {
+			// Non-breaking spaces stay inside words
+			if unicode.IsSpace(c) && c != '\u00a0' {
+				flush()
+				space = true
+				continue
+			}
+			if len(cur.segs) == 0 {
+				cur.space = space
+				space = false
+			}
+			if n := len(cur.segs); n > 0 && cur.segs[n-1].sameStyle(seg) {
+				cur.segs[n-1].text += string(c)
+			} else {
+				cur.segs = append(cur.segs, segment{text: string(c), format: seg.format, link: seg.link})
+			}
+		}
+	}
+	flush()
+	for i := range words {
+		for _, seg := range words[i].segs {
+			words[i].width += lipgloss.Width(seg.text)
+		}
+	}
+	return words
+}
+
+// Lays out inline segments as lines no wider than width (0 for
+// unlimited), starting the first with indent and the rest with
+// hang. Whitespace is collapsed.
+func wrap(segs []segment, width int, indent string, hang string) [][]segment {
+	var lines [][]segment
+	var line []segment
+	lineWidth := 0
+	prefix := indent
+	emit := func() {
+		if prefix != "" {
+			line = append([]segment{{text: prefix}}, line...)
+		}
+		lines = append(lines, line)
+		line, lineWidth, prefix = nil, 0, hang
+	}
+	avail := func() int {
+		return max(width-lipgloss.Width(prefix), 1)
+	}
+
+	for _, w := range splitWords(segs) {
+		if w.brk {
+			if lineWidth > 0 {
+				emit()
+			}
+			continue
+		}
+		gap := 0
+		if w.space && lineWidth > 0 {
+			gap = 1
+		}
+		if width > 0 && lineWidth > 0 && lineWidth+gap+w.width > avail() {
+			emit()
+			gap = 0
+		}
+		if gap > 0 {
+			space := segment{text: " "}
+			if last := line[len(line)-1]; last.sameStyle(w.segs[0]) {
+				space.format, space.link = last.format, last.link
+			}
+			line = append(line, space)
+			lineWidth++
+		}
+		// Words wider than the line, e.g. CJK text, are broken anywhere
+		long := width > 0 && w.width > avail()
+		for _, seg := range w.segs {
+			if !long {
+				line = append(line, seg)
+				continue
+			}
+			for _, c := range seg.text {
+				cw := lipgloss.Width(string(c))
+				if lineWidth > 0 && lineWidth+cw > avail() {
+					emit()
+				}
+				line = append(line, segment{text: string(c), format: seg.format, link: seg.link})
+				lineWidth += cw
+			}
+		}
+		if !long {
+			lineWidth += w.width
+		}
+	}
+	if lineWidth > 0 {
+		emit()
+	}
+	for i := range lines {
+		lines[i] = mergeSegments(lines[i])
+	}
+	return lines
+}
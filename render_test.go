@@ -0,0 +1,256 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderLinks(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		width int
+		links []RenderedLink
+	}{
+		"no links": {
+			input: "plain text",
+			links: nil,
+		},
+		"labels and fragments": {
+			input: "[[Fauna of Africa|African]] [[giraffe#Etymology|giraffe]] [[#History|below]]",
+			links: []RenderedLink{{Target: "Fauna of Africa", Line: 0}, {Target: "giraffe", Line: 0}},
+		},
+		"files and categories aren't followable": {
+			input: "[[File:Giraffe.jpg|thumb|A [[giraffe]]]] [[Category:Mammals]] [[Okapi]]",
+			links: []RenderedLink{{Target: "Okapi", Line: 0}},
+		},
+		"interlanguage links aren't followable": {
+			input: "[[de:Giraffen]] [[DE:Giraffen]] [[ fr:Girafe]] [[Okapi]]",
+			links: []RenderedLink{{Target: "Okapi", Line: 0}},
+		},
+		"lines after wrapping": {
+			input: "one [[two]] three [[four]]\n\n[[five]]",
+			width: 10,
+			links: []RenderedLink{{Target: "two", Line: 0}, {Target: "four", Line: 1}, {Target: "five", Line: 3}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got := Render(ParseWikitext(test.input), test.width).Links
+			if !reflect.DeepEqual(got, test.links) {
+				t.Fatalf("function Render\n---INPUT\n%q\n---GOT\n%+v\n---EXPECTED\n%+v\n---", test.input, got, test.links)
+			}
+		})
+	}
+}
+
+func TestRenderSections(t *testing.T) {
+	input := "lead\n== History ==\nold\n=== Early ''life'' ===\nolder\n== Today ==\nnew"
+	expected := []RenderedSection{
+		{Title: "History", Level: 2, Line: 2},
+		{Title: "Early life", Level: 3, Line: 6},
+		{Title: "Today", Level: 2, Line: 10},
+	}
+	got := Render(ParseWikitext(input), 0).Sections
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("function Render\n---GOT\n%+v\n---EXPECTED\n%+v\n---", got, expected)
+	}
+}
+
+func TestRenderTables(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		width  int
+		result string
+	}{
+		"header rule": {
+			input: "{|\n! A !! B\n|-\n| 1 || 2\n|-\n| 3 || 4\n|}",
+			result: `┌───┬───┐
+│ A │ B │
+├───┼───┤
+│ 1 │ 2 │
+│ 3 │ 4 │
+└───┴───┘`,
+		},
+		"rowspan and colspan": {
+			input: "{|\n| rowspan=2 | a || b\n|-\n| c\n|-\n| colspan=2 | d\n|}",
+			result: `┌───┬───┐
+│ a │ b │
+│   ├───┤
+│   │ c │
+├───┴───┤
+│ d     │
+└───────┘`,
+		},
+		"wrapped to width": {
+			input: "{|\n| one two three || four\n|}",
+			width: 16,
+			result: `┌───────┬──────┐
+│ one   │ four │
+│ two   │      │
+│ three │      │
+└───────┴──────┘`,
+		},
+		"records when too wide": {
+			input: "{|\n! Year !! Title\n|-\n| 1999 || First\n|-\n| 2001 || Second\n|}",
+			width: 10,
+			result: `Year: 1999
+Title:
+  First
+──────────
+Year: 2001
+Title:
+  Second`,
+		},
+		"records show spanned cells once": {
+			input: "{|\n! Year !! Title\n|-\n| rowspan=2 | 1999 || First\n|-\n| Second\n|}",
+			width: 10,
+			result: `Year: 1999
+Title:
+  First
+──────────
+Title:
+  Second`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if got := Render(ParseWikitext(test.input), test.width).String(); got != test.result {
+				t.Fatalf("function Render\n---INPUT\n%q\n---GOT\n%s\n---EXPECTED\n%s\n---", test.input, got, test.result)
+			}
+		})
+	}
+}
+
+func TestRenderLists(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		width  int
+		result string
+	}{
+		"nested bullets": {
+			input: "* a\n** b\n*** c\n* d",
+			result: `• a
+  ◦ b
+    ▪ c
+• d`,
+		},
+		"numbering per level": {
+			input: "# a\n## a1\n## a2\n# b\n#: still b\n# c",
+			result: `1. a
+   1. a1
+   2. a2
+2. b
+   still b
+3. c`,
+		},
+		"numbers line up": {
+			input:  "# a\n# b\n# c\n# d\n# e\n# f\n# g\n# h\n# i\n# j",
+			result: " 1. a\n 2. b\n 3. c\n 4. d\n 5. e\n 6. f\n 7. g\n 8. h\n 9. i\n10. j",
+		},
+		"definitions": {
+			input: "; Term\n: Definition\n; Other : Inline",
+			result: `Term
+  Definition
+Other
+  Inline`,
+		},
+		"hanging indentation": {
+			input: "* one two three four\n*# five six seven",
+			width: 12,
+			result: `• one two
+  three four
+  1. five
+     six
+     seven`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if got := Render(ParseWikitext(test.input), test.width).String(); got != test.result {
+				t.Fatalf("function Render\n---INPUT\n%q\n---GOT\n%s\n---EXPECTED\n%s\n---", test.input, got, test.result)
+			}
+		})
+	}
+}
+
+func TestRenderRefs(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		result string
+	}{
+		"markers and a references section": {
+			input: "Tall.<ref>{{cite web |title=Giraffes |url=https://example.com |website=Example}}</ref> Spotted.<ref>Plain source</ref>",
+			result: `Tall.[1] Spotted.[2]
+
+References
+
+1. Giraffes. Example. https://example.com
+2. Plain source`,
+		},
+		"named refs are numbered once": {
+			input: `a<ref name="x">{{cite book |last=Doe |first=Jane |year=2001 |title=Necks}}</ref> b<ref name=y>Y</ref> c<ref name=x />`,
+			result: `a[1] b[2] c[1]
+
+References
+
+1. Doe, Jane (2001). Necks.
+2. Y`,
+		},
+		"reused before being defined": {
+			input: "a<ref name=x /> b<ref name=x>X</ref>",
+			result: `a[1] b[1]
+
+References
+
+1. X`,
+		},
+		"listed where the article asks": {
+			input: "a<ref>{{cite journal |author=Roe |title=Spots. |journal=Zoology |doi=10.1000/1}}</ref>\n== Notes ==\n{{Reflist}}\n== See also ==\nmore",
+			result: `a[1]
+
+Notes
+
+1. Roe. Spots. Zoology. doi:10.1000/1
+
+See also
+
+more`,
+		},
+		"list-defined refs": {
+			input: "a<ref name=n />\n\n<references>\n<ref name=n>Defined later</ref>\n</references>",
+			result: `a[1]
+
+1. Defined later`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if got := Render(ParseWikitext(test.input), 0).String(); got != test.result {
+				t.Fatalf("function Render\n---INPUT\n%q\n---GOT\n%s\n---EXPECTED\n%s\n---", test.input, got, test.result)
+			}
+		})
+	}
+}
+
+func TestCitationFields(t *testing.T) {
+	doc := ParseWikitext("a<ref>{{Cite journal |last1=Woo |first1=Erin |last2=Efrati |first2=Amir |date=May 4, 2023 |title=Losses |doi=10.1/x |url=https://x.org}}</ref>")
+	got := Render(doc, 0).Citations[0].fields()
+	want := [][2]string{
+		{"Title", "Losses"},
+		{"Author", "Woo, Erin; Efrati, Amir"},
+		{"Date", "May 4, 2023"},
+		{"URL", "https://x.org"},
+		{"DOI", "10.1/x (https://doi.org/10.1/x)"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("function fields\n---GOT\n%v\n---EXPECTED\n%v\n---", got, want)
+	}
+}
@@ -1,15 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 )
 
 func SearchView(m model) string {
-	s := "wki - Search Wikipedia\n\n"
+	s := fmt.Sprintf("wki - Search the %s\n\n", m.source.SiteName())
 	s += m.textInput.View()
 	s += "\n\n"
 	for i := 0; i < len(m.Articles); i++ {
@@ -23,8 +25,12 @@ func SearchView(m model) string {
 	}
 
 	// The footer
-	s += "\nNavigate: ←↑↓→ ↲. Quit: ESC.\n"
-	s += m.info
+	s += "\nNavigate: ←↑↓→ ↲. Language: alt+l. Quit: ESC.\n"
+	if m.searching {
+		s += noteStyle("searching…")
+	} else {
+		s += m.info
+	}
 
 	// Send the UI for rendering
 	return s
@@ -44,7 +50,13 @@ func SearchUpdate(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.Type {
 
 		// These keys should exit the program.
-		case tea.KeyCtrlC, tea.KeyEsc:
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEsc:
+			// Stop loading an article before quitting
+			if m.stopLoading() {
+				return m, nil
+			}
 			return m, tea.Quit
 
 		// The "up" and "k" keys move the cursor up
@@ -71,60 +83,87 @@ func SearchUpdate(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 				break
 			}
 
-			m.pageName = "article"
-			m.shownArticle = article.Title
-
 			// "Cache" existing content
-			if m.Articles[m.cursor].Content != "" {
+			if article.Document != nil {
+				m.showArticle(article)
 				break
 			}
 
-			newArticle, err := m.client.LoadArticle(article)
-			if err != nil {
-				m.info = err.Error()
-				break
-			}
-
-			m.Articles[m.cursor] = newArticle
-			m.content = lipgloss.NewStyle().Width(m.viewport.Width).Render(newArticle.Content)
-			m.viewport.SetContent(m.content)
+			m.info = fmt.Sprintf("Loading %s...", article.Title)
+			return m, m.loadArticleCmd(article)
 		case tea.KeyLeft, tea.KeyRight:
 			m.textInput, cmd = m.textInput.Update(msg)
 			return m, cmd
 		default:
 			m.textInput, cmd = m.textInput.Update(msg)
-			return m, tea.Batch(cmd, m.queryArticlesCmd())
+			return m, tea.Batch(cmd, m.debounceSearch())
+		}
+	case searchTickMsg:
+		// Typing carried on since
+		if msg.seq != m.searchSeq {
+			break
 		}
+		return m, m.queryArticlesCmd()
 	case apiResponseMsg:
-		if msg.query != m.textInput.Value() {
+		// Drop results for old queries, or from before switching language
+		if msg.query != m.textInput.Value() || msg.lang != m.source.Language() {
+			break
+		}
+		if errors.Is(msg.err, context.Canceled) {
+			break
+		}
+		m.searching = false
+		if msg.err != nil {
+			m.info = errorInfo(msg.err, m.source.SiteName())
 			break
 		}
 		m.Articles = msg.articles
 	}
 	if strings.TrimSpace(m.textInput.Value()) == "" {
 		m.Articles = DefaultArticleMap
-	}
-
-	// Should be checked towards the end so we don't
-	// get stuck in an infinite loop
-	if m.Articles[0].Title == DefaultArticleMap[0].Title {
-		return m, tea.Batch(cmd, m.queryArticlesCmd())
+		m.searching = false
 	}
 	return m, cmd
 }
 
-func (m model) queryArticlesCmd() tea.Cmd {
+// How long typing has to pause before searching, by default
+const DefaultDebounce = 300 * time.Millisecond
+
+// Searches once typing settles. Each keypress starts a new wait,
+// and only the tick from the last one goes on to search.
+func (m *model) debounceSearch() tea.Cmd {
+	m.searchSeq++
+	seq := m.searchSeq
+	return tea.Tick(m.debounce, func(time.Time) tea.Msg {
+		return searchTickMsg{seq: seq}
+	})
+}
+
+type searchTickMsg struct {
+	seq int
+}
+
+// Searches for the query being typed, cancelling
+// the search for what was typed before
+func (m *model) queryArticlesCmd() tea.Cmd {
+	if m.cancelSearch != nil {
+		m.cancelSearch()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelSearch = cancel
 	query := m.textInput.Value()
+	m.searching = strings.TrimSpace(query) != ""
+	src := m.source
 	return func() tea.Msg {
-		articles, err := m.client.LoadSearchList(query)
-		if err != nil {
-			m.info = err.Error()
-		}
-		return apiResponseMsg{articles: articles, query: query}
+		defer cancel()
+		articles, err := loadSearchList(ctx, src, query)
+		return apiResponseMsg{articles: articles, query: query, lang: src.Language(), err: err}
 	}
 }
 
 type apiResponseMsg struct {
 	articles map[int]Article
 	query    string
+	lang     string
+	err      error
 }
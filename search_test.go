@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSearchDebounce(t *testing.T) {
+	var m tea.Model = initialModel("", &Client{Lang: "en"}, time.Millisecond)
+	for _, r := range "gir" {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+
+	// Ticks from earlier keypresses are dropped
+	m, cmd := m.Update(searchTickMsg{seq: 2})
+	if cmd != nil || m.(model).searching {
+		t.Fatalf("searched on a stale tick")
+	}
+	m, cmd = m.Update(searchTickMsg{seq: 3})
+	if cmd == nil || !m.(model).searching {
+		t.Fatalf("didn't search once typing settled")
+	}
+	if got := m.View(); !strings.Contains(got, "searching…") {
+		t.Fatalf("search page doesn't say it's searching:\n%s", got)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import "context"
+
+// Source is where searches and articles come from: a Wikipedia's
+// API through Client, or an archive on disk like a ZIM file.
+// The TUI reads everything through it.
+type Source interface {
+	Search(ctx context.Context, query string, limit int, offset int) ([]SearchResult, error)
+	LoadArticle(ctx context.Context, article Article) (Article, error)
+	// Titles of up to n articles picked at random
+	Random(ctx context.Context, n int) ([]string, error)
+	// The article titled title in other languages we can read
+	LangLinks(ctx context.Context, title string) ([]LangLink, error)
+	// Key facts about the subject of the article titled title,
+	// none when the source doesn't know any
+	Facts(ctx context.Context, title string) ([]Fact, error)
+	// Code of the language the articles are in, e.g. en
+	Language() string
+	// What to call the source, e.g. English Wikipedia
+	SiteName() string
+	// The same kind of source in another language
+	InLang(lang string) (Source, error)
+}
+
+// Titles of the closest matches for an article that wasn't found
+func suggestTitles(ctx context.Context, s Source, title string) []string {
+	results, _ := s.Search(ctx, title, 3, 0)
+	var titles []string
+	for _, result := range results {
+		titles = append(titles, result.Title)
+	}
+	return titles
+}
+
+// Loads the first few results of a search as articles to pick from
+func loadSearchList(ctx context.Context, s Source, queryText string) (map[int]Article, error) {
+	results, err := s.Search(ctx, queryText, 6, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	articles := make(map[int]Article)
+	for i, entry := range results {
+		articles[i] = Article{
+			Title: entry.Title,
+			//TODO use tea.Batch in update loop to send
+			// multiple API calls to get the page extract
+			// for each search result instead of using
+			// the snippet.
+
+			// Might be able to replace cleaning entirely with the "explaintext"
+			// https://www.mediawiki.org/wiki/Extension:TextExtracts
+			Description: entry.Snippet,
+			Url:         entry.Url,
+		}
+	}
+	return articles, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fakeSource serves articles from memory, so the
+// TUI can be tested without a network
+type fakeSource struct {
+	lang string
+	// Wikitext of each article by title
+	articles  map[string]string
+	langLinks map[string][]LangLink
+	facts     map[string][]Fact
+	// The same wiki in other languages, by code
+	others map[string]*fakeSource
+}
+
+func (f *fakeSource) titles() []string {
+	var titles []string
+	for title := range f.articles {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+	return titles
+}
+
+// Matches titles containing the query, in any case
+func (f *fakeSource) Search(ctx context.Context, query string, limit int, offset int) ([]SearchResult, error) {
+	var results []SearchResult
+	for _, title := range f.titles() {
+		if strings.Contains(strings.ToLower(title), strings.ToLower(strings.TrimSpace(query))) {
+			results = append(results, SearchResult{Title: title, Snippet: "About " + title})
+		}
+	}
+	results = results[min(offset, len(results)):]
+	return results[:min(limit, len(results))], nil
+}
+
+func (f *fakeSource) LoadArticle(ctx context.Context, article Article) (Article, error) {
+	if err := ctx.Err(); err != nil {
+		return article, err
+	}
+	wikitext, ok := f.articles[article.Title]
+	if !ok {
+		return article, fmt.Errorf("%w: %q", ErrNotFound, article.Title)
+	}
+	article.Lang = f.lang
+	article.LangLinks = f.langLinks[article.Title]
+	article.Document = ParseWikitext(wikitext)
+	return article, nil
+}
+
+// Not so random: the first titles in order
+func (f *fakeSource) Random(ctx context.Context, n int) ([]string, error) {
+	titles := f.titles()
+	return titles[:min(n, len(titles))], nil
+}
+
+func (f *fakeSource) LangLinks(ctx context.Context, title string) ([]LangLink, error) {
+	return f.langLinks[title], nil
+}
+
+func (f *fakeSource) Facts(ctx context.Context, title string) ([]Fact, error) {
+	return f.facts[title], nil
+}
+
+func (f *fakeSource) Language() string {
+	return f.lang
+}
+
+func (f *fakeSource) SiteName() string {
+	return WikipediaLangs[f.lang] + " Wikipedia"
+}
+
+func (f *fakeSource) InLang(lang string) (Source, error) {
+	other, ok := f.others[lang]
+	if !ok {
+		return nil, fmt.Errorf("no %s wiki", lang)
+	}
+	return other, nil
+}
+
+func TestLoadSearchList(t *testing.T) {
+	src := &fakeSource{lang: "en", articles: map[string]string{"Giraffe": "", "Giraffe family": "", "Okapi": ""}}
+	articles, err := loadSearchList(context.Background(), src, "giraffe")
+	if err != nil {
+		t.Fatalf("loadSearchList() error = %v", err)
+	}
+	expected := map[int]Article{
+		0: {Title: "Giraffe", Description: "About Giraffe"},
+		1: {Title: "Giraffe family", Description: "About Giraffe family"},
+	}
+	if !reflect.DeepEqual(articles, expected) {
+		t.Fatalf("loadSearchList() = %+v, expected %+v", articles, expected)
+	}
+	if articles, _ := loadSearchList(context.Background(), src, "zebra"); articles != nil {
+		t.Fatalf("loadSearchList() = %+v for no matches, expected nil", articles)
+	}
+}
@@ -15,10 +15,25 @@ var (
 		return titleStyle.BorderStyle(b)
 	}()
 
+	currentSectionStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("#04B575")).
+				Render
+
+	popupStyle = lipgloss.NewStyle().
+			BorderStyle(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#04B575")).
+			Padding(0, 1)
+
 	linkStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#04B575")).
 			Render
 
+	highlightedLinkStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#FFFFFF")).
+				Background(lipgloss.Color("#04B575")).
+				Render
+
 	listArticleStyle = lipgloss.NewStyle().
 				Foreground(lipgloss.Color("#04B575")).
 				Render
@@ -36,6 +51,10 @@ var (
 					Bold(true).
 					Italic(true).
 					Render
+	articleHeadingStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("#7D56F4")).
+				Render
 	noteStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#808080")).
 			Render
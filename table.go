@@ -0,0 +1,406 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Tables are laid out on a grid of slots that honors rowspan
+// and colspan, with columns sized to fit the rendering width.
+// Tables that can't fit are shown one record per row instead.
+
+// gridCell is a table cell placed on the grid
+type gridCell struct {
+	header   bool
+	row, col int
+	rowspan  int
+	colspan  int
+	segs     []segment
+	// Content wrapped to the cell's width
+	lines [][]segment
+}
+
+type tableGrid struct {
+	cells []*gridCell
+	// slots[row][col] is the cell covering a slot, nil when empty
+	slots  [][]*gridCell
+	widths []int
+	// Lines of text in each row
+	heights []int
+	// Whether a rule is drawn below each row
+	ruled []bool
+}
+
+// Widest a column gets to be to fit its longest word
+const maxMinColumnWidth = 20
+
+func (g *tableGrid) rows() int {
+	return len(g.slots)
+}
+
+func (g *tableGrid) cols() int {
+	return len(g.widths)
+}
+
+// Cell covering a slot, nil when empty or out of range
+func (g *tableGrid) slot(row int, col int) *gridCell {
+	if row < 0 || row >= len(g.slots) || col < 0 || col >= len(g.slots[row]) {
+		return nil
+	}
+	return g.slots[row][col]
+}
+
+func spanAttr(attrs map[string]string, name string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(attrs[name]))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return min(n, 50)
+}
+
+func (r *renderer) newGrid(t *Table) *tableGrid {
+	g := &tableGrid{slots: make([][]*gridCell, len(t.Rows))}
+	cols := 0
+	for y, row := range t.Rows {
+		x := 0
+		for _, cell := range row.Cells {
+			// Skip slots taken by rowspans from above
+			for g.slot(y, x) != nil {
+				x++
+			}
+			format := textFormat(0)
+			if cell.Header {
+				format = formatBold
+			}
+			gc := &gridCell{
+				header:  cell.Header,
+				row:     y,
+				col:     x,
+				rowspan: min(spanAttr(cell.Attrs, "rowspan"), len(t.Rows)-y),
+				colspan: spanAttr(cell.Attrs, "colspan"),
+				segs:    r.inline(flattenBlocks(cell.Children), format),
+			}
+			g.cells = append(g.cells, gc)
+			for dy := 0; dy < gc.rowspan; dy++ {
+				for dx := 0; dx < gc.colspan; dx++ {
+					slots := g.slots[y+dy]
+					for len(slots) <= x+dx {
+						slots = append(slots, nil)
+					}
+					slots[x+dx] = gc
+					g.slots[y+dy] = slots
+				}
+			}
+			x += gc.colspan
+			cols = max(cols, x)
+		}
+	}
+	for y := range g.slots {
+		for len(g.slots[y]) < cols {
+			g.slots[y] = append(g.slots[y], nil)
+		}
+	}
+	g.widths = make([]int, cols)
+	return g
+}
+
+// Width of the text area of a cell, including the
+// borders and padding of the columns it spans
+func (g *tableGrid) cellWidth(gc *gridCell) int {
+	width := 3 * (gc.colspan - 1)
+	for c := gc.col; c < gc.col+gc.colspan && c < g.cols(); c++ {
+		width += g.widths[c]
+	}
+	return width
+}
+
+// Widest line of text when nothing wraps, and widest word
+func measure(segs []segment) (natural int, minimum int) {
+	for _, line := range wrap(segs, 0, "", "") {
+		natural = max(natural, segmentsWidth(line))
+	}
+	for _, w := range splitWords(segs) {
+		minimum = max(minimum, min(w.width, maxMinColumnWidth))
+	}
+	return max(natural, 1), max(minimum, 1)
+}
+
+func segmentsWidth(segs []segment) int {
+	width := 0
+	for _, seg := range segs {
+		width += lipgloss.Width(seg.text)
+	}
+	return width
+}
+
+// Picks column widths that fit width, or reports that none do
+func (g *tableGrid) fit(width int) bool {
+	cols := g.cols()
+	natural := make([]int, cols)
+	minimum := make([]int, cols)
+	for i := range natural {
+		natural[i], minimum[i] = 1, 1
+	}
+	// Single column cells first, then widen the last column
+	// under spanning cells that still don't fit
+	for _, spanning := range []bool{false, true} {
+		for _, gc := range g.cells {
+			if (gc.colspan > 1) != spanning || gc.col >= cols {
+				continue
+			}
+			cellNatural, cellMinimum := measure(gc.segs)
+			last := min(gc.col+gc.colspan, cols) - 1
+			have := func(widths []int) int {
+				total := 3 * (last - gc.col)
+				for c := gc.col; c <= last; c++ {
+					total += widths[c]
+				}
+				return total
+			}
+			natural[last] += max(0, cellNatural-have(natural))
+			minimum[last] += max(0, cellMinimum-have(minimum))
+		}
+	}
+
+	overhead := 3*cols + 1
+	sum := func(widths []int) int {
+		total := 0
+		for _, w := range widths {
+			total += w
+		}
+		return total
+	}
+	switch {
+	case width == 0 || sum(natural)+overhead <= width:
+		copy(g.widths, natural)
+		return true
+	case sum(minimum)+overhead > width:
+		return false
+	}
+
+	// Share out the room beyond the minimums in
+	// proportion to how much more each column wants
+	extra := width - overhead - sum(minimum)
+	flex := sum(natural) - sum(minimum)
+	for c := range g.widths {
+		g.widths[c] = minimum[c] + extra*(natural[c]-minimum[c])/flex
+	}
+	return true
+}
+
+// Wraps each cell and works out row heights and rules
+func (g *tableGrid) layout() {
+	rows := g.rows()
+	g.heights = make([]int, rows)
+	g.ruled = make([]bool, rows)
+	for i := range g.heights {
+		g.heights[i] = 1
+	}
+
+	multiline := false
+	for _, gc := range g.cells {
+		gc.lines = wrap(gc.segs, g.cellWidth(gc), "", "")
+		if gc.rowspan == 1 {
+			g.heights[gc.row] = max(g.heights[gc.row], len(gc.lines))
+		}
+		multiline = multiline || len(gc.lines) > 1 || gc.rowspan > 1
+	}
+
+	// Rules go between all rows once any cell takes up more than
+	// a line, otherwise only below the header rows
+	for y := 0; y < rows-1; y++ {
+		g.ruled[y] = multiline || g.isHeaderRow(y) && !g.isHeaderRow(y+1)
+	}
+
+	// Make room for cells spanning rows
+	for _, gc := range g.cells {
+		if gc.rowspan == 1 {
+			continue
+		}
+		last := gc.row + gc.rowspan - 1
+		if need := len(gc.lines) - g.spanHeight(gc, last+1); need > 0 {
+			g.heights[last] += need
+		}
+	}
+}
+
+func (g *tableGrid) isHeaderRow(y int) bool {
+	for _, gc := range g.slots[y] {
+		if gc != nil && !gc.header {
+			return false
+		}
+	}
+	return true
+}
+
+// Lines a cell covers from its first row up to row y,
+// counting the rules in between
+func (g *tableGrid) spanHeight(gc *gridCell, y int) int {
+	height := 0
+	for row := gc.row; row < y; row++ {
+		height += g.heights[row]
+		if g.ruled[row] && row < gc.row+gc.rowspan-1 {
+			height++
+		}
+	}
+	return height
+}
+
+// The nth line of a cell's text padded to its width
+func (g *tableGrid) cellLine(gc *gridCell, n int) []segment {
+	var line []segment
+	if n < len(gc.lines) {
+		line = gc.lines[n]
+	}
+	pad := g.cellWidth(gc) - segmentsWidth(line)
+	segs := []segment{{text: " "}}
+	segs = append(segs, line...)
+	return append(segs, segment{text: strings.Repeat(" ", pad+1)})
+}
+
+func border(s string) segment {
+	return segment{text: s, format: formatNote}
+}
+
+// Box-drawing junctions indexed by up|down<<1|left<<2|right<<3
+var junctions = [16]string{
+	" ", "│", "│", "│", "─", "┘", "┐", "┤",
+	"─", "└", "┌", "├", "─", "┴", "┬", "┼",
+}
+
+func junction(up, down, left, right bool) string {
+	i := 0
+	for bit, set := range []bool{up, down, left, right} {
+		if set {
+			i |= 1 << bit
+		}
+	}
+	return junctions[i]
+}
+
+// Whether a vertical line runs along the left of column b in row y
+func (g *tableGrid) vertical(y int, b int) bool {
+	if y < 0 || y >= g.rows() {
+		return false
+	}
+	return b == 0 || b == g.cols() || g.slot(y, b-1) != g.slot(y, b)
+}
+
+// Rule below row y, -1 for the top of the table
+func (g *tableGrid) rule(y int) []segment {
+	var segs []segment
+	horizontal := func(c int) bool {
+		return g.slot(y, c) != g.slot(y+1, c)
+	}
+	for c := 0; c <= g.cols(); {
+		left := c > 0 && horizontal(c-1)
+		right := c < g.cols() && horizontal(c)
+		segs = append(segs, border(junction(g.vertical(y, c), g.vertical(y+1, c), left, right)))
+		if c == g.cols() {
+			break
+		}
+		// A cell spanning both rows carries on through the rule
+		if gc := g.slot(y, c); gc != nil && !horizontal(c) {
+			segs = append(segs, g.cellLine(gc, g.spanHeight(gc, y+1))...)
+			c += gc.col + gc.colspan - c
+			continue
+		}
+		segs = append(segs, border(strings.Repeat("─", g.widths[c]+2)))
+		c++
+	}
+	return segs
+}
+
+// The nth line of text in row y
+func (g *tableGrid) textLine(y int, n int) []segment {
+	var segs []segment
+	for c := 0; c < g.cols(); {
+		segs = append(segs, border("│"))
+		gc := g.slot(y, c)
+		if gc == nil {
+			segs = append(segs, segment{text: strings.Repeat(" ", g.widths[c]+2)})
+			c++
+			continue
+		}
+		segs = append(segs, g.cellLine(gc, g.spanHeight(gc, y)+n)...)
+		c += max(1, gc.col+gc.colspan-c)
+	}
+	return append(segs, border("│"))
+}
+
+func (r *renderer) table(t *Table) [][]segment {
+	var lines [][]segment
+	if len(t.Caption) > 0 {
+		lines = wrap(r.inline(t.Caption, formatBold), r.width, "", "")
+	}
+	g := r.newGrid(t)
+	if g.rows() == 0 || g.cols() == 0 {
+		return lines
+	}
+	if !g.fit(r.width) {
+		return append(lines, r.tableRecords(g)...)
+	}
+	g.layout()
+
+	lines = append(lines, g.rule(-1))
+	for y := 0; y < g.rows(); y++ {
+		for n := 0; n < g.heights[y]; n++ {
+			lines = append(lines, g.textLine(y, n))
+		}
+		if g.ruled[y] {
+			lines = append(lines, g.rule(y))
+		}
+	}
+	lines = append(lines, g.rule(g.rows()-1))
+	for i := range lines {
+		lines[i] = mergeSegments(lines[i])
+	}
+	return lines
+}
+
+// Lays a table too wide for the screen out as one record per row,
+// labelling each value with its column header
+func (r *renderer) tableRecords(g *tableGrid) [][]segment {
+	labels := make([]string, g.cols())
+	first := 0
+	for first < g.rows() && g.isHeaderRow(first) {
+		for c, gc := range g.slots[first] {
+			if gc != nil {
+				labels[c] = strings.TrimSpace(plainSegments(gc.segs))
+			}
+		}
+		first++
+	}
+
+	var lines [][]segment
+	for y := first; y < g.rows(); y++ {
+		if y > first {
+			lines = append(lines, []segment{border(strings.Repeat("─", min(max(r.width, 3), 20)))})
+		}
+		var previous *gridCell
+		for c, gc := range g.slots[y] {
+			// Cells spanning rows are shown in the first one's record
+			if gc == nil || gc == previous || gc.row < y {
+				continue
+			}
+			previous = gc
+			var segs []segment
+			if labels[c] != "" {
+				segs = append(segs, segment{text: labels[c] + ": ", format: formatBold})
+			}
+			segs = append(segs, gc.segs...)
+			lines = append(lines, wrap(segs, r.width, "", "  ")...)
+		}
+	}
+	return lines
+}
+
+func plainSegments(segs []segment) string {
+	var b strings.Builder
+	for _, seg := range segs {
+		b.WriteString(seg.text)
+	}
+	return b.String()
+}
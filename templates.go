@@ -0,0 +1,579 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Templates are expanded by handlers looked up by the template's key,
+// each given the parameters the template was called with.
+
+// Parameters a template was called with
+type templateParams struct {
+	// The template's key, e.g. "birth date and age"
+	key string
+	// Unnamed parameters, with ones numbered like |2=value in their place
+	positional [][]Node
+	named      map[string][]Node
+}
+
+func parseTemplateParams(t *Template) templateParams {
+	p := templateParams{key: t.Key(), named: map[string][]Node{}}
+	for _, param := range t.Params {
+		name := strings.TrimSpace(param.Name)
+		if name == "" {
+			p.positional = append(p.positional, param.Value)
+			continue
+		}
+		n, err := strconv.Atoi(name)
+		if err != nil || n < 1 {
+			p.named[name] = param.Value
+			continue
+		}
+		for len(p.positional) < n {
+			p.positional = append(p.positional, nil)
+		}
+		p.positional[n-1] = param.Value
+	}
+	return p
+}
+
+// The nth (1-based) positional parameter
+func (p templateParams) arg(n int) []Node {
+	if n < 1 || n > len(p.positional) {
+		return nil
+	}
+	return p.positional[n-1]
+}
+
+// The nth positional parameter as trimmed plain text
+func (p templateParams) text(n int) string {
+	return strings.TrimSpace(PlainText(p.arg(n)))
+}
+
+// The named parameter as trimmed plain text, "" when it's missing
+func (p templateParams) option(name string) string {
+	return strings.TrimSpace(PlainText(p.named[name]))
+}
+
+type templateHandler func(p templateParams) []Node
+
+// Handlers by template key, redirects included
+var templateHandlers = map[string]templateHandler{
+	// https://en.wikipedia.org/wiki/Template:IPA
+	// Only four exceptions this time, not bad
+	"ipa":      restTemplate,
+	"ipac-en":  restTemplate,
+	"ipac-cmn": restTemplate,
+	"ipac-yue": restTemplate,
+	"ipac-hu":  restTemplate,
+	"ipac-pl":  restTemplate,
+	"linktext": restTemplate,
+	// https://en.wikipedia.org/wiki/Template:Transliteration
+	"transliteration": func(p templateParams) []Node {
+		return p.arg(len(p.positional))
+	},
+	// https://en.wikipedia.org/wiki/Template:Lang
+	// It's much worse than it seems
+	"lang": func(p templateParams) []Node {
+		return p.arg(2)
+	},
+
+	// https://en.wikipedia.org/wiki/Template:Convert
+	"convert": convertTemplate,
+	"cvt":     convertTemplate,
+
+	// https://en.wikipedia.org/wiki/Template:Birth_date_and_age
+	"start date":         dateTemplate,
+	"end date":           dateTemplate,
+	"birth date":         dateTemplate,
+	"death date":         dateTemplate,
+	"start date and age": dateTemplate,
+	"start year and age": dateTemplate,
+	"birth date and age": dateTemplate,
+	"bda":                dateTemplate,
+	"death date and age": dateTemplate,
+	"dda":                dateTemplate,
+	// https://en.wikipedia.org/wiki/Template:Circa
+	"circa": circaTemplate,
+	"c.":    circaTemplate,
+	"ca.":   circaTemplate,
+
+	// https://en.wikipedia.org/wiki/Template:Unbulleted_list
+	"ubl":             listTemplate,
+	"unbulleted list": listTemplate,
+	"plainlist":       listTemplate,
+	"plain list":      listTemplate,
+	"bulleted list":   listTemplate,
+	"bull":            listTemplate,
+	// https://en.wikipedia.org/wiki/Template:Flatlist
+	"flatlist":  listTemplate,
+	"flat list": listTemplate,
+	"hlist":     listTemplate,
+
+	// https://en.wikipedia.org/wiki/Template:Quote
+	"quote":      quoteTemplate,
+	"blockquote": quoteTemplate,
+	"cquote":     quoteTemplate,
+	"quotation":  quoteTemplate,
+	"nowrap":     nowrapTemplate,
+	"nobr":       nowrapTemplate,
+	// https://en.wikipedia.org/wiki/Template:Abbr
+	"abbr": func(p templateParams) []Node {
+		return p.arg(1)
+	},
+	// https://en.wikipedia.org/wiki/Template:Sfn
+	"sfn":    sfnTemplate,
+	"sfnp":   sfnTemplate,
+	"harvnb": sfnTemplate,
+	"efn": func(p templateParams) []Node {
+		return []Node{&Tag{Name: "ref", Attrs: map[string]string{"name": p.option("name")}, Children: p.arg(1)}}
+	},
+	"citation needed": func(p templateParams) []Node {
+		return []Node{&Text{Value: "[citation needed]"}}
+	},
+	"cn": func(p templateParams) []Node {
+		return []Node{&Text{Value: "[citation needed]"}}
+	},
+
+	// https://en.wikipedia.org/wiki/Template:Main
+	"main":     hatnoteTemplate,
+	"see also": hatnoteTemplate,
+	"further":  hatnoteTemplate,
+
+	// https://en.wikipedia.org/wiki/Template:Flag
+	"flag":        flagTemplate,
+	"flagcountry": flagTemplate,
+	"flagu":       flagTemplate,
+	"flagicon":    flagTemplate,
+	"flagdeco":    flagTemplate,
+	// https://en.wikipedia.org/wiki/Template:Coord
+	"coord": coordTemplate,
+
+	// Bookkeeping that isn't part of the text
+	"use dmy dates":        nil,
+	"use mdy dates":        nil,
+	"use british english":  nil,
+	"use american english": nil,
+	"good article":         nil,
+	"featured article":     nil,
+	"pp":                   nil,
+	"pp-protected":         nil,
+	"pp-semi-indef":        nil,
+	"pp-move-indef":        nil,
+	"authority control":    nil,
+	"taxonbar":             nil,
+	"portal":               nil,
+	"commons category":     nil,
+	"defaultsort":          nil,
+	"italic title":         nil,
+	"anchor":               nil,
+	"clear":                nil,
+}
+
+// Expands a template into the nodes that stand in for it, and
+// whether it's one we know. Unknown templates expand to nothing.
+func expandTemplate(t *Template) ([]Node, bool) {
+	key := t.Key()
+	switch {
+	case isInfobox(t):
+		return nil, true
+	case isShortDescription(t):
+		return t.Arg(1), true
+	}
+	if handler, ok := templateHandlers[key]; ok {
+		if handler == nil {
+			return nil, true
+		}
+		return handler(parseTemplateParams(t)), true
+	}
+
+	// https://new.wikipedia.org/wiki/Template:Zh
+	// Generalized just in case.
+	if _, ok := WikipediaLangs[key]; ok {
+		return restTemplate(parseTemplateParams(t)), true
+	}
+	if strings.HasPrefix(key, "lang-") {
+		return t.Arg(1), true
+	}
+	return nil, false
+}
+
+// Every positional parameter, pipes included
+func restTemplate(p templateParams) []Node {
+	var nodes []Node
+	for i, arg := range p.positional {
+		if i > 0 {
+			nodes = append(nodes, &Text{Value: "|"})
+		}
+		nodes = append(nodes, arg...)
+	}
+	return nodes
+}
+
+// Lists in infobox values, one item a line or all on one line
+// joined by dots. Plainlist and flatlist wrap a bulleted list.
+func listTemplate(p templateParams) []Node {
+	inline := p.key == "flatlist" || p.key == "flat list" || p.key == "hlist"
+	var items [][]Node
+	for _, arg := range p.positional {
+		items = append(items, bulletedItems(arg)...)
+	}
+	var nodes []Node
+	for _, item := range items {
+		if len(nodes) > 0 && inline {
+			nodes = append(nodes, &Text{Value: " · "})
+		} else if len(nodes) > 0 {
+			nodes = append(nodes, &Tag{Name: "br"})
+		}
+		nodes = append(nodes, item...)
+	}
+	return nodes
+}
+
+// Splits nodes at "*" bullets starting lines, leaving
+// nodes without bullets as a single item
+func bulletedItems(nodes []Node) [][]Node {
+	var items [][]Node
+	var item []Node
+	flush := func() {
+		if item = trimNodes(item); len(item) > 0 {
+			items = append(items, item)
+		}
+		item = nil
+	}
+	for _, node := range nodes {
+		text, ok := node.(*Text)
+		if !ok {
+			item = append(item, node)
+			continue
+		}
+		for i, line := range strings.Split(text.Value, "\n") {
+			if i > 0 && strings.HasPrefix(line, "*") {
+				flush()
+				line = strings.TrimLeft(line, "*")
+			} else if i > 0 {
+				line = " " + line
+			}
+			if line != "" {
+				item = append(item, &Text{Value: line})
+			}
+		}
+	}
+	flush()
+	return items
+}
+
+// A date given as year, month and day parameters
+type templateDate struct {
+	year, month, day int
+}
+
+// The date in the parameters from the nth on
+func templateDateAt(p templateParams, n int) (templateDate, bool) {
+	var date templateDate
+	for i, field := range []*int{&date.year, &date.month, &date.day} {
+		value := p.text(n + i)
+		if value == "" {
+			break
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return date, i > 0
+		}
+		*field = parsed
+	}
+	return date, date.year != 0 && date.month <= 12
+}
+
+func (d templateDate) String() string {
+	year := strconv.Itoa(d.year)
+	if d.year < 0 {
+		year = strconv.Itoa(-d.year) + " BCE"
+	}
+	switch {
+	case d.month == 0:
+		return year
+	case d.day == 0:
+		return monthName(d.month) + " " + year
+	}
+	return strconv.Itoa(d.day) + " " + monthName(d.month) + " " + year
+}
+
+// Whole years from d to then, counting only years when
+// either leaves out the month
+func (d templateDate) yearsTo(then templateDate) int {
+	years := then.year - d.year
+	if d.month == 0 || then.month == 0 {
+		return years
+	}
+	if then.month < d.month || then.month == d.month && then.day != 0 && then.day < d.day {
+		years--
+	}
+	return years
+}
+
+// Dates like {{birth date and age|1952|3|11}}, with ages counted to today
+func dateTemplate(p templateParams) []Node {
+	return datedTemplate(p, time.Now())
+}
+
+func datedTemplate(p templateParams, today time.Time) []Node {
+	date, ok := templateDateAt(p, 1)
+	if !ok {
+		return restTemplate(p)
+	}
+	now := templateDate{today.Year(), int(today.Month()), today.Day()}
+	text := date.String()
+	switch p.key {
+	case "birth date and age", "bda":
+		text += fmt.Sprintf(" (age %d)", date.yearsTo(now))
+	case "death date and age", "dda":
+		if born, ok := templateDateAt(p, 4); ok {
+			text += fmt.Sprintf(" (aged %d)", born.yearsTo(date))
+		}
+	case "start date and age", "start year and age":
+		switch years := date.yearsTo(now); years {
+		case 0:
+			text += " (this year)"
+		case 1:
+			text += " (1 year ago)"
+		default:
+			text += fmt.Sprintf(" (%d years ago)", years)
+		}
+	}
+	return []Node{&Text{Value: text}}
+}
+
+// {{circa|1969}} is c. 1969, {{circa|1890|1900}} c. 1890 – c. 1900
+func circaTemplate(p templateParams) []Node {
+	nodes := []Node{&Text{Value: "c. "}}
+	nodes = append(nodes, p.arg(1)...)
+	if len(trimNodes(p.arg(2))) > 0 {
+		nodes = append(nodes, &Text{Value: " – c. "})
+		nodes = append(nodes, p.arg(2)...)
+	}
+	return nodes
+}
+
+// Quotations on a line of their own, attributed on the next
+func quoteTemplate(p templateParams) []Node {
+	text, ok := p.named["text"]
+	if !ok {
+		text, ok = p.named["quote"]
+	}
+	if !ok {
+		text = p.arg(1)
+	}
+	text = trimNodes(text)
+	if len(text) == 0 {
+		return nil
+	}
+	nodes := []Node{&Tag{Name: "br"}, &Text{Value: "“"}}
+	nodes = append(nodes, text...)
+	nodes = append(nodes, &Text{Value: "”"})
+
+	// The first of the parameters given
+	first := func(names ...string) []Node {
+		for _, name := range names {
+			value := p.named[name]
+			if n, err := strconv.Atoi(name); err == nil {
+				value = p.arg(n)
+			}
+			if value = trimNodes(value); len(value) > 0 {
+				return value
+			}
+		}
+		return nil
+	}
+	by := first("author", "sign", "2")
+	if source := first("source", "title", "3"); len(source) > 0 {
+		if len(by) > 0 {
+			by = append(by, &Text{Value: ", "})
+		}
+		by = append(by, source...)
+	}
+	if len(by) > 0 {
+		nodes = append(nodes, &Tag{Name: "br"}, &Text{Value: "— "})
+		nodes = append(nodes, by...)
+	}
+	return append(nodes, &Tag{Name: "br"})
+}
+
+// Text kept on one line, its spaces made non-breaking
+func nowrapTemplate(p templateParams) []Node {
+	var nodes []Node
+	for _, node := range p.arg(1) {
+		if text, ok := node.(*Text); ok {
+			node = &Text{Value: strings.ReplaceAll(text.Value, " ", "\u00a0")}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// Short citations like {{sfn|Smith|2005|p=25}}, as footnotes
+// numbered with the article's other references
+func sfnTemplate(p templateParams) []Node {
+	var authors []string
+	year := ""
+	for i := 1; i <= len(p.positional); i++ {
+		arg := p.text(i)
+		if _, err := strconv.Atoi(strings.TrimRight(arg, "abcdefghij")); err == nil && i > 1 {
+			year = arg
+			break
+		}
+		if arg != "" {
+			authors = append(authors, arg)
+		}
+	}
+	var text string
+	switch len(authors) {
+	case 0:
+	case 1, 2:
+		text = strings.Join(authors, " & ")
+	default:
+		text = authors[0] + " et al."
+	}
+	if year != "" {
+		text += " " + year
+	}
+	for _, name := range []string{"p", "page", "pp", "pages", "loc"} {
+		if page := p.option(name); page != "" {
+			prefix := map[string]string{"p": "p. ", "page": "p. ", "pp": "pp. ", "pages": "pp. "}[name]
+			text += ", " + prefix + page
+			break
+		}
+	}
+	text = strings.TrimSpace(text)
+	if p.key == "harvnb" {
+		return []Node{&Text{Value: text}}
+	}
+	// Citing the same page twice shares a number
+	return []Node{&Tag{Name: "ref", Attrs: map[string]string{"name": "sfn " + text}, Children: []Node{&Text{Value: text + "."}}}}
+}
+
+// Hatnotes like {{main|Giraffe}}, pointing to other articles
+func hatnoteTemplate(p templateParams) []Node {
+	var targets []string
+	for i := range p.positional {
+		if target := p.text(i + 1); target != "" {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	var label string
+	switch p.key {
+	case "main":
+		label = "Main article: "
+		if len(targets) > 1 {
+			label = "Main articles: "
+		}
+	case "see also":
+		label = "See also: "
+	case "further":
+		label = "Further information: "
+	}
+	nodes := []Node{&Text{Value: label}}
+	for i, target := range targets {
+		switch {
+		case i == 0:
+		case i == len(targets)-1:
+			nodes = append(nodes, &Text{Value: " and "})
+		default:
+			nodes = append(nodes, &Text{Value: ", "})
+		}
+		shown := []Node{&Text{Value: strings.Replace(target, "#", " § ", 1)}}
+		if custom := trimNodes(p.named["l"+strconv.Itoa(i+1)]); len(custom) > 0 {
+			shown = custom
+		}
+		nodes = append(nodes, &Link{Target: target, Children: shown})
+	}
+	return []Node{&Tag{Name: "br"}, &Italic{Children: nodes}, &Tag{Name: "br"}}
+}
+
+// Country names for the codes flag templates are often called with
+var flagCountries = map[string]string{
+	"USA": "United States", "US": "United States", "UK": "United Kingdom",
+	"GBR": "United Kingdom", "GER": "Germany", "DEU": "Germany", "FRA": "France",
+	"ITA": "Italy", "ESP": "Spain", "NED": "Netherlands", "NLD": "Netherlands",
+	"SUI": "Switzerland", "CHE": "Switzerland", "JPN": "Japan", "CHN": "China",
+	"RUS": "Russia", "CAN": "Canada", "AUS": "Australia", "BRA": "Brazil",
+	"IND": "India", "MEX": "Mexico", "ARG": "Argentina", "SWE": "Sweden",
+	"NOR": "Norway", "DEN": "Denmark", "DNK": "Denmark", "FIN": "Finland",
+	"POL": "Poland", "AUT": "Austria", "BEL": "Belgium", "POR": "Portugal",
+	"PRT": "Portugal", "IRL": "Ireland", "KOR": "South Korea", "RSA": "South Africa",
+	"ZAF": "South Africa", "NZL": "New Zealand", "UKR": "Ukraine", "TUR": "Turkey",
+}
+
+// Flags are pictures; the country's name is what's left of them
+func flagTemplate(p templateParams) []Node {
+	if p.key == "flagicon" || p.key == "flagdeco" {
+		return nil
+	}
+	country := p.text(1)
+	if name, ok := flagCountries[strings.ToUpper(country)]; ok {
+		country = name
+	}
+	if country == "" {
+		return nil
+	}
+	shown := []Node{&Text{Value: country}}
+	if name := trimNodes(p.named["name"]); len(name) > 0 {
+		shown = name
+	}
+	if p.key == "flagu" {
+		return shown
+	}
+	return []Node{&Link{Target: country, Children: shown}}
+}
+
+// Coordinates in degrees, minutes and seconds or decimal degrees,
+// e.g. {{coord|40|42|46|N|74|00|22|W}} or {{coord|40.71|-74.00}}
+func coordTemplate(p templateParams) []Node {
+	// Shown only as the article's title
+	if display := p.option("display"); display == "title" || display == "t" {
+		return nil
+	}
+	var args []string
+	for i := range p.positional {
+		args = append(args, p.text(i+1))
+	}
+	hemisphere := -1
+	for i, arg := range args {
+		if arg == "N" || arg == "S" {
+			hemisphere = i
+			break
+		}
+	}
+	if hemisphere < 0 {
+		if len(args) < 2 {
+			return nil
+		}
+		latitude, err1 := strconv.ParseFloat(args[0], 64)
+		longitude, err2 := strconv.ParseFloat(args[1], 64)
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		return []Node{&Text{Value: formatCoordinate(wikidataCoordinate{Latitude: latitude, Longitude: longitude})}}
+	}
+	// The longitude takes as many parts as the latitude
+	end := 2*hemisphere + 2
+	if end > len(args) || args[end-1] != "E" && args[end-1] != "W" {
+		return nil
+	}
+	part := func(values []string, direction string) string {
+		var b strings.Builder
+		for i, value := range values {
+			b.WriteString(value + []string{"°", "′", "″"}[min(i, 2)])
+		}
+		return b.String() + direction
+	}
+	latitude := part(args[:hemisphere], args[hemisphere])
+	longitude := part(args[hemisphere+1:end-1], args[end-1])
+	return []Node{&Text{Value: latitude + " " + longitude}}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	tests := map[string]struct {
+		wikitext string
+		expected string
+	}{
+		"convert":             {wikitext: "{{convert|5|km}}", expected: "5 kilometres (3.1 mi)"},
+		"convert thousands":   {wikitext: "{{convert|1500|m}}", expected: "1,500 metres (4,900 ft)"},
+		"convert abbreviated": {wikitext: "{{cvt|100|km}}", expected: "100 km (62 mi)"},
+		"convert temperature": {wikitext: "{{convert|100|C}}", expected: "100 °C (212 °F)"},
+		"convert range":       {wikitext: "{{convert|5|-|10|km|abbr=on}}", expected: "5–10 km (3.1–6.2 mi)"},
+		"convert to unit":     {wikitext: "{{convert|10|kg|lb oz}}", expected: "10 kilograms (22 lb; 350 oz)"},
+		"convert precision":   {wikitext: "{{convert|5|km|mi|2}}", expected: "5 kilometres (3.11 mi)"},
+		"convert adjective":   {wikitext: "a {{convert|5|km|adj=on}} run", expected: "a 5-kilometre (3.1 mi) run"},
+		"convert flipped":     {wikitext: "{{convert|1|mi|disp=flip}}", expected: "1.6 km (1 mile)"},
+		"convert feet":        {wikitext: "{{convert|6|ft|2|in}}", expected: "6 feet 2 inches (1.88 m)"},
+		"convert to feet":     {wikitext: "{{convert|1.88|m|ftin}}", expected: "1.88 metres (6 ft 2 in)"},
+		"convert unknown":     {wikitext: "{{convert|5|furlong}}", expected: "5 furlong"},
+		"circa":               {wikitext: "{{circa|1969}}", expected: "c. 1969"},
+		"circa range":         {wikitext: "{{c.|1890|1900}}", expected: "c. 1890 – c. 1900"},
+		"nowrap":              {wikitext: "a {{nowrap|5 km}} walk", expected: "a 5\u00a0km walk"},
+		"abbr":                {wikitext: "{{abbr|NASA|National Aeronautics and Space Administration}}", expected: "NASA"},
+		"sfn": {
+			wikitext: "Tall.{{sfn|Smith|2005|p=25}} Long.{{sfn|Smith|2005|p=25}} Spotted.{{sfnp|Smith|Jones|Brown|2010|pp=1–3}}",
+			expected: "Tall.[1] Long.[1] Spotted.[2]\n\nReferences\n\n1. Smith 2005, p. 25.\n2. Smith et al. 2010, pp. 1–3.",
+		},
+		"main":                {wikitext: "{{main|Giraffe|Okapi#Taxonomy}}", expected: "Main articles: Giraffe and Okapi § Taxonomy"},
+		"further":             {wikitext: "{{further|Okapi|l1=the okapi}}", expected: "Further information: the okapi"},
+		"flags":               {wikitext: "{{flag|USA}} and {{flagicon|GER}}{{flagu|France}}", expected: "United States and France"},
+		"coordinates":         {wikitext: "{{coord|40|42|46|N|74|00|22|W|display=inline,title}}", expected: "40°42′46″N 74°00′22″W"},
+		"decimal coordinates": {wikitext: "{{coord|40.7128|-74.006}}", expected: "40.7128°N, 74.006°W"},
+		"title coordinates":   {wikitext: "{{coord|1|N|2|E|display=title}}", expected: ""},
+		"quote": {
+			wikitext: "He said {{quote|text=To be or not to be.|author=Shakespeare|source=Hamlet}} and left.",
+			expected: "He said\n“To be or not to be.”\n— Shakespeare, Hamlet\nand left.",
+		},
+		"unknown": {wikitext: "{{mystery|x}}Text", expected: "Text"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Render(ParseWikitext(test.wikitext), 0).String(); got != test.expected {
+				t.Fatalf("Render() = %q, expected %q", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestTemplatePlaceholders(t *testing.T) {
+	doc := ParseWikitext("{{Use dmy dates}}{{mystery|x}} The giraffe.{{cn}}")
+	got := render(doc, 0, renderOptions{placeholders: true}).String()
+	if expected := "{{mystery}} The giraffe.[citation needed]"; got != expected {
+		t.Fatalf("render() = %q, expected %q", got, expected)
+	}
+}
+
+func TestDateTemplate(t *testing.T) {
+	today := time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		wikitext string
+		expected string
+	}{
+		"date":       {wikitext: "{{Start date|2015|12|11}}", expected: "11 December 2015"},
+		"month":      {wikitext: "{{start date|2015|12}}", expected: "December 2015"},
+		"age":        {wikitext: "{{Birth date and age|1952|3|11|df=yes}}", expected: "11 March 1952 (age 73)"},
+		"birthday":   {wikitext: "{{Birth date and age|1952|3|10}}", expected: "10 March 1952 (age 74)"},
+		"aged":       {wikitext: "{{Death date and age|1852|11|27|1815|12|10}}", expected: "27 November 1852 (aged 36)"},
+		"years ago":  {wikitext: "{{Start date and age|2015|12|11}}", expected: "11 December 2015 (10 years ago)"},
+		"not a date": {wikitext: "{{Start date|soon}}", expected: "soon"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			template := ParseWikitext(test.wikitext).Children[0].(*Paragraph).Children[0].(*Template)
+			got := PlainText(datedTemplate(parseTemplateParams(template), today))
+			if strings.TrimSpace(got) != test.expected {
+				t.Fatalf("dateTemplate() = %q, expected %q", got, test.expected)
+			}
+		})
+	}
+}
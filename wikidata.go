@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Articles on Wikimedia wikis are about Wikidata items, whose
+// statements give quick facts like a birth date or population.
+// https://www.wikidata.org/wiki/Wikidata:Data_access
+
+const DefaultWikidataUrl = "https://www.wikidata.org/w/api.php?"
+
+// Fact is a labelled statement about an article's subject,
+// e.g. Population: 8,804,190 (2020)
+type Fact struct {
+	Label  string   `json:"label"`
+	Values []string `json:"values"`
+}
+
+// Properties shown as facts, in the order they're shown
+var factProperties = []string{
+	"P31",   // instance of
+	"P225",  // taxon name
+	"P171",  // parent taxon
+	"P141",  // IUCN conservation status
+	"P569",  // date of birth
+	"P19",   // place of birth
+	"P570",  // date of death
+	"P20",   // place of death
+	"P27",   // country of citizenship
+	"P106",  // occupation
+	"P26",   // spouse
+	"P17",   // country
+	"P131",  // located in the administrative territorial entity
+	"P36",   // capital
+	"P35",   // head of state
+	"P6",    // head of government
+	"P1082", // population
+	"P2046", // area
+	"P2044", // elevation above sea level
+	"P625",  // coordinate location
+	"P37",   // official language
+	"P38",   // currency
+	"P571",  // inception
+	"P112",  // founded by
+	"P159",  // headquarters location
+	"P452",  // industry
+	"P50",   // author
+	"P57",   // director
+	"P175",  // performer
+	"P136",  // genre
+	"P577",  // publication date
+	"P2048", // height
+	"P2067", // mass
+	"P856",  // official website
+}
+
+// Most values shown for a property
+const maxFactValues = 5
+
+// Symbols of common units, which Wikidata only labels in words
+var unitSymbols = map[string]string{
+	"Q11573":  "m",
+	"Q828224": "km",
+	"Q174728": "cm",
+	"Q712226": "km²",
+	"Q25343":  "m²",
+	"Q11570":  "kg",
+	"Q41803":  "g",
+	"Q11229":  "%",
+	"Q3710":   "ft",
+	"Q232291": "sq mi",
+}
+
+type WikidataEntitiesJSON struct {
+	Entities map[string]struct {
+		Labels map[string]struct {
+			Value string `json:"value"`
+		} `json:"labels"`
+		Claims map[string][]wikidataClaim `json:"claims"`
+	} `json:"entities"`
+}
+
+type wikidataClaim struct {
+	// preferred, normal or deprecated
+	Rank       string                    `json:"rank"`
+	MainSnak   wikidataSnak              `json:"mainsnak"`
+	Qualifiers map[string][]wikidataSnak `json:"qualifiers"`
+}
+
+type wikidataSnak struct {
+	// Only value snaks have a value, not somevalue and novalue
+	SnakType  string `json:"snaktype"`
+	DataValue struct {
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	} `json:"datavalue"`
+}
+
+type wikidataTime struct {
+	Time string `json:"time"`
+	// 11 for a day, 10 a month, 9 a year, 8 a decade, 7 a century
+	Precision int `json:"precision"`
+}
+
+type wikidataQuantity struct {
+	Amount string `json:"amount"`
+	// Entity URL of the unit, or 1 for none
+	Unit string `json:"unit"`
+}
+
+type wikidataCoordinate struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+func (c *Client) wikidataUrl() string {
+	if c.WikidataUrl == "" {
+		return DefaultWikidataUrl
+	}
+	return c.WikidataUrl
+}
+
+// Key facts about the subject of the article titled title from its
+// Wikidata item, with items and properties labelled in the client's
+// language. Articles without an item have none.
+func (c *Client) Facts(ctx context.Context, title string) ([]Fact, error) {
+	key := cacheKey("facts", c.ApiUrl, title)
+	var facts []Fact
+	if found, fresh := c.cache.get(key, &facts); found && fresh {
+		return facts, nil
+	}
+
+	item, err := c.wikidataItem(ctx, title)
+	if err != nil || item == "" {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Add("action", "wbgetentities")
+	params.Add("ids", item)
+	params.Add("props", "claims")
+	params.Add("format", "json")
+	var result WikidataEntitiesJSON
+	if err := c.fetch(ctx, &result, c.wikidataUrl()+params.Encode()); err != nil {
+		return nil, err
+	}
+	claims := result.Entities[item].Claims
+
+	// Items, units and properties are all labelled in one go
+	var ids []string
+	for _, property := range factProperties {
+		shown := factClaims(claims[property])
+		if len(shown) == 0 {
+			continue
+		}
+		ids = append(ids, property)
+		for _, claim := range shown {
+			ids = append(ids, snakEntities(claim.MainSnak)...)
+		}
+	}
+	labels, err := c.wikidataLabels(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, property := range factProperties {
+		fact := Fact{Label: capitalize(labels[property])}
+		if fact.Label == "" {
+			fact.Label = property
+		}
+		for _, claim := range factClaims(claims[property]) {
+			if value := formatClaim(claim, labels); value != "" {
+				fact.Values = append(fact.Values, value)
+			}
+		}
+		if len(fact.Values) > 0 {
+			facts = append(facts, fact)
+		}
+	}
+	c.cache.put(key, facts)
+	return facts, nil
+}
+
+// ID of the Wikidata item the article titled title is about, "" for none
+func (c *Client) wikidataItem(ctx context.Context, title string) (string, error) {
+	params := url.Values{}
+	params.Add("action", "query")
+	params.Add("formatversion", "2")
+	params.Add("prop", "pageprops")
+	params.Add("ppprop", "wikibase_item")
+	params.Add("titles", title)
+	params.Add("redirects", "1")
+	params.Add("format", "json")
+	var result WikipediaPageJSON
+	if err := c.fetch(ctx, &result, c.ApiUrl+params.Encode()); err != nil {
+		return "", err
+	}
+	if len(result.Query.Pages) == 0 || result.Query.Pages[0].Missing {
+		return "", fmt.Errorf("%w: %q", ErrNotFound, title)
+	}
+	return result.Query.Pages[0].PageProps.WikibaseItem, nil
+}
+
+// Labels of Wikidata entities by ID, in the client's language or
+// else English. The API takes 50 IDs at a time.
+func (c *Client) wikidataLabels(ctx context.Context, ids []string) (map[string]string, error) {
+	labels := map[string]string{}
+	var unique []string
+	for _, id := range ids {
+		if _, ok := labels[id]; !ok {
+			labels[id] = ""
+			unique = append(unique, id)
+		}
+	}
+	for start := 0; start < len(unique); start += 50 {
+		params := url.Values{}
+		params.Add("action", "wbgetentities")
+		params.Add("ids", strings.Join(unique[start:min(start+50, len(unique))], "|"))
+		params.Add("props", "labels")
+		params.Add("languages", c.Lang+"|en")
+		params.Add("format", "json")
+		var result WikidataEntitiesJSON
+		if err := c.fetch(ctx, &result, c.wikidataUrl()+params.Encode()); err != nil {
+			return nil, err
+		}
+		for id, entity := range result.Entities {
+			if label, ok := entity.Labels[c.Lang]; ok {
+				labels[id] = label.Value
+			} else {
+				labels[id] = entity.Labels["en"].Value
+			}
+		}
+	}
+	return labels, nil
+}
+
+// The claims worth showing: the preferred ones if any are,
+// otherwise all but the deprecated, and only so many
+func factClaims(claims []wikidataClaim) []wikidataClaim {
+	var preferred, normal []wikidataClaim
+	for _, claim := range claims {
+		if claim.MainSnak.SnakType != "value" {
+			continue
+		}
+		switch claim.Rank {
+		case "preferred":
+			preferred = append(preferred, claim)
+		case "normal":
+			normal = append(normal, claim)
+		}
+	}
+	if len(preferred) > 0 {
+		normal = preferred
+	}
+	return normal[:min(len(normal), maxFactValues)]
+}
+
+// IDs of the items a value refers to, including its unit
+func snakEntities(snak wikidataSnak) []string {
+	switch snak.DataValue.Type {
+	case "wikibase-entityid":
+		var entity struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(snak.DataValue.Value, &entity) == nil && entity.ID != "" {
+			return []string{entity.ID}
+		}
+	case "quantity":
+		var q wikidataQuantity
+		if json.Unmarshal(snak.DataValue.Value, &q) == nil {
+			if id := unitID(q.Unit); id != "" && unitSymbols[id] == "" {
+				return []string{id}
+			}
+		}
+	}
+	return nil
+}
+
+// http://www.wikidata.org/entity/Q11573 is Q11573
+func unitID(unit string) string {
+	if unit == "1" {
+		return ""
+	}
+	return unit[strings.LastIndex(unit, "/")+1:]
+}
+
+// A claim's value, followed by when it held, e.g. 8,804,190 (2020)
+func formatClaim(claim wikidataClaim, labels map[string]string) string {
+	value := formatSnak(claim.MainSnak, labels)
+	if value == "" {
+		return ""
+	}
+	qualifier := func(property string) string {
+		for _, snak := range claim.Qualifiers[property] {
+			if s := formatSnak(snak, labels); s != "" {
+				return s
+			}
+		}
+		return ""
+	}
+	// point in time, start time and end time
+	at, start, end := qualifier("P585"), qualifier("P580"), qualifier("P582")
+	switch {
+	case at != "":
+		value += " (" + at + ")"
+	case start != "" && end != "":
+		value += " (" + start + "–" + end + ")"
+	case start != "":
+		value += " (since " + start + ")"
+	case end != "":
+		value += " (until " + end + ")"
+	}
+	return value
+}
+
+func formatSnak(snak wikidataSnak, labels map[string]string) string {
+	if snak.SnakType != "value" {
+		return ""
+	}
+	raw := snak.DataValue.Value
+	switch snak.DataValue.Type {
+	case "wikibase-entityid":
+		var entity struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(raw, &entity) != nil {
+			return ""
+		}
+		if label := labels[entity.ID]; label != "" {
+			return label
+		}
+		return entity.ID
+	case "time":
+		var t wikidataTime
+		if json.Unmarshal(raw, &t) != nil {
+			return ""
+		}
+		return formatWikidataTime(t)
+	case "quantity":
+		var q wikidataQuantity
+		if json.Unmarshal(raw, &q) != nil {
+			return ""
+		}
+		amount := formatAmount(q.Amount)
+		id := unitID(q.Unit)
+		if symbol := unitSymbols[id]; symbol != "" {
+			return amount + " " + symbol
+		}
+		if label := labels[id]; label != "" {
+			return amount + " " + label
+		}
+		return amount
+	case "globecoordinate":
+		var c wikidataCoordinate
+		if json.Unmarshal(raw, &c) != nil {
+			return ""
+		}
+		return formatCoordinate(c)
+	case "monolingualtext":
+		var text struct {
+			Text string `json:"text"`
+		}
+		if json.Unmarshal(raw, &text) != nil {
+			return ""
+		}
+		return text.Text
+	case "string":
+		var s string
+		if json.Unmarshal(raw, &s) != nil {
+			return ""
+		}
+		return s
+	}
+	return ""
+}
+
+// Writes a Wikidata time like +1952-03-11T00:00:00Z
+// to the precision it's known to, e.g. 11 March 1952
+func formatWikidataTime(t wikidataTime) string {
+	bce := strings.HasPrefix(t.Time, "-")
+	date, _, _ := strings.Cut(strings.TrimLeft(t.Time, "+-"), "T")
+	parts := strings.Split(date, "-")
+	if len(parts) != 3 {
+		return ""
+	}
+	year, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return ""
+	}
+	month, _ := strconv.Atoi(parts[1])
+	day, _ := strconv.Atoi(parts[2])
+
+	era := ""
+	if bce {
+		era = " BCE"
+	}
+	switch {
+	case t.Precision >= 11 && month > 0 && day > 0:
+		return fmt.Sprintf("%d %s %d%s", day, monthName(month), year, era)
+	case t.Precision == 10 && month > 0:
+		return fmt.Sprintf("%s %d%s", monthName(month), year, era)
+	case t.Precision == 8:
+		return fmt.Sprintf("%ds%s", year/10*10, era)
+	case t.Precision == 7:
+		return fmt.Sprintf("%s century%s", ordinal((year-1)/100+1), era)
+	}
+	return fmt.Sprintf("%d%s", year, era)
+}
+
+func monthName(month int) string {
+	return [...]string{"January", "February", "March", "April", "May", "June", "July",
+		"August", "September", "October", "November", "December"}[(month-1)%12]
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// Groups the digits of an amount like +8804190.5 in thousands
+func formatAmount(amount string) string {
+	sign := ""
+	if strings.HasPrefix(amount, "-") {
+		sign = "-"
+	}
+	whole, fraction, hasFraction := strings.Cut(strings.TrimLeft(amount, "+-"), ".")
+	var b strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	if hasFraction {
+		return sign + b.String() + "." + fraction
+	}
+	return sign + b.String()
+}
+
+// e.g. 40.7128°N, 74.006°W
+func formatCoordinate(c wikidataCoordinate) string {
+	hemisphere := func(value float64, positive string, negative string) string {
+		s := strconv.FormatFloat(math.Abs(value), 'f', -1, 64)
+		if whole, fraction, ok := strings.Cut(s, "."); ok && len(fraction) > 4 {
+			s = whole + "." + fraction[:4]
+		}
+		if value < 0 {
+			return s + "°" + negative
+		}
+		return s + "°" + positive
+	}
+	return hemisphere(c.Latitude, "N", "S") + ", " + hemisphere(c.Longitude, "E", "W")
+}
+
+func capitalize(s string) string {
+	for i := range s {
+		if i > 0 {
+			return strings.ToUpper(s[:i]) + s[i:]
+		}
+	}
+	return strings.ToUpper(s)
+}
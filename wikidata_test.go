@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testClaims = `{"entities": {"Q60": {"claims": {
+	"P31": [
+		{"rank": "normal", "mainsnak": {"snaktype": "value", "datavalue": {"type": "wikibase-entityid", "value": {"id": "Q515"}}}},
+		{"rank": "deprecated", "mainsnak": {"snaktype": "value", "datavalue": {"type": "wikibase-entityid", "value": {"id": "Q5"}}}}
+	],
+	"P1082": [
+		{"rank": "normal", "mainsnak": {"snaktype": "value", "datavalue": {"type": "quantity", "value": {"amount": "+8175133", "unit": "1"}}},
+			"qualifiers": {"P585": [{"snaktype": "value", "datavalue": {"type": "time", "value": {"time": "+2010-00-00T00:00:00Z", "precision": 9}}}]}},
+		{"rank": "preferred", "mainsnak": {"snaktype": "value", "datavalue": {"type": "quantity", "value": {"amount": "+8804190", "unit": "1"}}},
+			"qualifiers": {"P585": [{"snaktype": "value", "datavalue": {"type": "time", "value": {"time": "+2020-04-01T00:00:00Z", "precision": 11}}}]}}
+	],
+	"P2046": [
+		{"rank": "normal", "mainsnak": {"snaktype": "value", "datavalue": {"type": "quantity", "value": {"amount": "+783.8", "unit": "http://www.wikidata.org/entity/Q712226"}}}}
+	],
+	"P2044": [
+		{"rank": "normal", "mainsnak": {"snaktype": "value", "datavalue": {"type": "quantity", "value": {"amount": "+10", "unit": "http://www.wikidata.org/entity/Q123"}}}}
+	],
+	"P625": [
+		{"rank": "normal", "mainsnak": {"snaktype": "value", "datavalue": {"type": "globecoordinate", "value": {"latitude": 40.712777777778, "longitude": -74.006111111111}}}}
+	],
+	"P571": [
+		{"rank": "normal", "mainsnak": {"snaktype": "somevalue"}}
+	],
+	"P6": [
+		{"rank": "normal", "mainsnak": {"snaktype": "value", "datavalue": {"type": "wikibase-entityid", "value": {"id": "Q999"}}},
+			"qualifiers": {"P580": [{"snaktype": "value", "datavalue": {"type": "time", "value": {"time": "+2022-01-01T00:00:00Z", "precision": 11}}}]}}
+	]
+}}}}`
+
+func TestFacts(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		requests = append(requests, query.Get("action")+" "+query.Get("props"))
+		switch {
+		case r.URL.Path == "/w/api.php" && query.Get("titles") == "New York City":
+			w.Write([]byte(`{"query": {"pages": [{"title": "New York City", "pageprops": {"wikibase_item": "Q60"}}]}}`))
+		case r.URL.Path == "/w/api.php":
+			w.Write([]byte(`{"query": {"pages": [{"title": "Sandbox"}]}}`))
+		case query.Get("props") == "claims":
+			w.Write([]byte(testClaims))
+		case query.Get("props") == "labels":
+			// German labels, falling back to English
+			labels := map[string]string{"P31": "ist ein(e)", "Q515": "Stadt", "P1082": "Einwohnerzahl", "P2046": "Fläche", "P625": "Koordinaten", "P6": "Regierungschef"}
+			entities := map[string]any{}
+			for _, id := range strings.Split(query.Get("ids"), "|") {
+				if label, ok := labels[id]; ok {
+					entities[id] = map[string]any{"labels": map[string]any{"de": map[string]string{"value": label}}}
+				} else if id == "Q123" {
+					entities[id] = map[string]any{"labels": map[string]any{"en": map[string]string{"value": "foot"}}}
+				} else {
+					entities[id] = map[string]any{"labels": map[string]any{}}
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"entities": entities})
+		}
+	}))
+	defer ts.Close()
+
+	client := &Client{Lang: "de", ApiUrl: ts.URL + "/w/api.php?", WikidataUrl: ts.URL + "/wikidata?"}
+	facts, err := client.Facts(context.Background(), "New York City")
+	if err != nil {
+		t.Fatalf("Facts() error = %v", err)
+	}
+	expected := []Fact{
+		{Label: "Ist ein(e)", Values: []string{"Stadt"}},
+		{Label: "Regierungschef", Values: []string{"Q999 (since 1 January 2022)"}},
+		{Label: "Einwohnerzahl", Values: []string{"8,804,190 (1 April 2020)"}},
+		{Label: "Fläche", Values: []string{"783.8 km²"}},
+		{Label: "P2044", Values: []string{"10 foot"}},
+		{Label: "Koordinaten", Values: []string{"40.7127°N, 74.0061°W"}},
+	}
+	if !reflect.DeepEqual(facts, expected) {
+		t.Fatalf("Facts() = %+v, expected %+v", facts, expected)
+	}
+	if len(requests) != 3 {
+		t.Fatalf("Facts() made requests %q, expected pageprops, claims and labels", requests)
+	}
+
+	// Articles without an item have no facts
+	facts, err = client.Facts(context.Background(), "Sandbox")
+	if err != nil || facts != nil {
+		t.Fatalf("Facts() = %+v, %v, expected none", facts, err)
+	}
+}
+
+func TestFormatWikidataTime(t *testing.T) {
+	tests := map[string]struct {
+		time     wikidataTime
+		expected string
+	}{
+		"day":     {time: wikidataTime{Time: "+1952-03-11T00:00:00Z", Precision: 11}, expected: "11 March 1952"},
+		"month":   {time: wikidataTime{Time: "+1952-03-00T00:00:00Z", Precision: 10}, expected: "March 1952"},
+		"year":    {time: wikidataTime{Time: "+1952-00-00T00:00:00Z", Precision: 9}, expected: "1952"},
+		"decade":  {time: wikidataTime{Time: "+1957-00-00T00:00:00Z", Precision: 8}, expected: "1950s"},
+		"century": {time: wikidataTime{Time: "+1901-00-00T00:00:00Z", Precision: 7}, expected: "20th century"},
+		"BCE":     {time: wikidataTime{Time: "-0044-03-15T00:00:00Z", Precision: 11}, expected: "15 March 44 BCE"},
+		"broken":  {time: wikidataTime{Time: "soon", Precision: 11}, expected: ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := formatWikidataTime(test.time); got != test.expected {
+				t.Fatalf("formatWikidataTime() = %q, expected %q", got, test.expected)
+			}
+		})
+	}
+}
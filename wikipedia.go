@@ -2,9 +2,7 @@ package main
 
 import (
 	"regexp"
-	"strings"
-
-	strip "github.com/grokify/html-strip-tags-go"
+	"time"
 )
 
 const DefaultWikiUrl = "wikipedia.org/wiki"
@@ -14,14 +12,39 @@ type WikipediaJSON interface {
 }
 
 type WikipediaPageQueryJSON struct {
+	// Missing on the last page of results
+	Continue *struct {
+		Sroffset int `json:"sroffset"`
+	} `json:"continue"`
 	Query struct {
 		Search []struct {
-			Title   string `json:"title"`
-			Snippet string `json:"snippet"`
+			Title     string    `json:"title"`
+			Snippet   string    `json:"snippet"`
+			WordCount int       `json:"wordcount"`
+			Timestamp time.Time `json:"timestamp"`
 		} `json:"search"`
 	} `json:"query"`
 }
 
+type WikipediaRandomJSON struct {
+	Query struct {
+		Random []struct {
+			Title string `json:"title"`
+		} `json:"random"`
+	} `json:"query"`
+}
+
+type WikiSiteInfoJSON struct {
+	Query struct {
+		General struct {
+			SiteName    string `json:"sitename"`
+			Lang        string `json:"lang"`
+			Server      string `json:"server"`
+			ArticlePath string `json:"articlepath"`
+		} `json:"general"`
+	} `json:"query"`
+}
+
 type WikipediaExtractPageJSON struct {
 	Query struct {
 		Pages map[string]struct {
@@ -31,195 +54,63 @@ type WikipediaExtractPageJSON struct {
 	} `json:"query"`
 }
 
+// https://www.mediawiki.org/wiki/API:Parsing_wikitext
+type WikipediaParseJSON struct {
+	Parse struct {
+		Title     string              `json:"title"`
+		RevID     int                 `json:"revid"`
+		Text      string              `json:"text"`
+		LangLinks []wikipediaLangLink `json:"langlinks"`
+	} `json:"parse"`
+}
+
+type wikipediaLangLink struct {
+	Lang  string `json:"lang"`
+	Title string `json:"title"`
+}
+
 type WikipediaPageJSON struct {
 	Query struct {
-		Pages []struct {
-			Title     string `json:"title"`
-			Revisions []struct {
-				Slots struct {
-					Main struct {
-						Content string `json:"content"`
-					} `json:"main"`
-				} `json:"slots"`
-			} `json:"revisions"`
-		} `json:"pages"`
+		Pages []wikipediaPage `json:"pages"`
 	} `json:"query"`
 }
 
-// Removes infoboxes of any type from a Wikitext string
-func removeInfobox(input string) string {
-	// This regex takes into account cases like
-	// {{Infobox ...
-	// {{Taxobox ...
-	// {{Automatic taxobox ...
-	// https://en.wikipedia.org/wiki/Wikipedia:List_of_infoboxes
-	re := regexp.MustCompile(`{{[a-zA-Z0-9-_]+(?:\s[a-zA-Z0-9-_]+)?box`)
-	startSlice := re.FindStringIndex(input)
-	if startSlice == nil {
-		return input // No Infobox found
-	}
-	start := startSlice[0]
-
-	bracketCount := 0
-	end := start
+type wikipediaPage struct {
+	Title         string              `json:"title"`
+	Missing       bool                `json:"missing"`
+	Invalid       bool                `json:"invalid"`
+	InvalidReason string              `json:"invalidreason"`
+	LangLinks     []wikipediaLangLink `json:"langlinks"`
+	PageProps     struct {
+		WikibaseItem string `json:"wikibase_item"`
+	} `json:"pageprops"`
+	Revisions []struct {
+		RevID int `json:"revid"`
+		Slots struct {
+			Main struct {
+				Content string `json:"content"`
+			} `json:"main"`
+		} `json:"slots"`
+	} `json:"revisions"`
+}
 
-	for i := start; i < len(input); i++ {
-		if input[i] == '{' {
-			bracketCount++
-		} else if input[i] == '}' {
-			bracketCount--
-			if bracketCount == 0 {
-				end = i + 1
-				break
-			}
-		}
-	}
+// Matches infoboxes of any type, taking into account cases like
+// {{Infobox ...
+// {{Taxobox ...
+// {{Automatic taxobox ...
+// https://en.wikipedia.org/wiki/Wikipedia:List_of_infoboxes
+var infoboxPattern = regexp.MustCompile(`^[a-zA-Z0-9-_]+(?:\s[a-zA-Z0-9-_]+)?box`)
 
-	return input[:start] + input[end:]
+func isInfobox(t *Template) bool {
+	return infoboxPattern.MatchString(t.Name)
 }
 
-// Character entities that wikitext doesn't include.
-// Non-examples: @ and © are allowed by wikitext.
-var WikiHTMLCharacterEntities = map[string]string{
-	"&nbsp;": " ",
-	"&quot;": `"`,
+// On the "Fork" article: {{Short description|Eating utensil}}
+func isShortDescription(t *Template) bool {
+	return t.Key() == "short description"
 }
 
-// Converts Wikitext into a TUI-friendly string
+// Converts Wikitext into a TUI-friendly string, leaving out infoboxes
 func CleanWikimediaHTML(dirty string) string {
-	m := regexp.MustCompile(`<ref[^>]*>.*?</ref>`)
-	clean := m.ReplaceAllString(dirty, "")
-
-	clean = removeInfobox(clean)
-
-	m = regexp.MustCompile(`(?s)\{\{(.*?)\}\}`)
-	replace := func(match string) string {
-		// Format based on content what's inside the {{brackets}}
-		bracketContent := match[2 : len(match)-2]
-		startWord, rest, _ := strings.Cut(bracketContent, " ")
-		switch startWord {
-
-		// Short description
-		// On the "Fork" article: {{Short description|Eating utensil}}
-		case "Short", "short":
-			_, description, _ := strings.Cut(rest, "|")
-			return articleDescriptionStyle(description)
-		}
-
-		// Stuff like {{... | ...}}
-		startWord, rest, found := strings.Cut(bracketContent, "|")
-		if !found {
-			return ""
-		}
-
-		// https://new.wikipedia.org/wiki/Template:Zh
-		// Generalized just in case.
-		if _, ok := WikipediaLangs[startWord]; ok {
-			return rest
-		}
-
-		switch startWord {
-		// https://en.wikipedia.org/wiki/Template:IPA
-		// Only four exceptions this time, not bad
-		case "IPA", "IPAc-en", "IPAc-cmn", "IPAc-yue", "IPAc-hu", "IPAc-pl":
-			return rest
-		// https://en.wikipedia.org/wiki/Template:Convert
-		case "convert":
-			return rest
-		// https://en.wikipedia.org/wiki/Template:Transliteration
-		case "transliteration":
-			parts := strings.Split(bracketContent, "|")
-			if len(parts) == 0 {
-				return ""
-			}
-			return parts[len(parts)-1]
-		}
-		if len(startWord) < 4 {
-			return ""
-		}
-
-		// https://en.wikipedia.org/wiki/Template:Lang
-		// It's much worse than it seems
-		startWord = strings.ToLower(startWord)[:4]
-		switch startWord {
-		case "lang":
-			_, phrase, found := strings.Cut(rest, "|")
-			if found {
-				return phrase
-			}
-			parts := strings.Split(bracketContent, "|")
-
-			// Check if there are at least two parts
-			if len(parts) >= 2 {
-				return parts[1]
-			}
-		}
-		return ""
-	}
-	clean = m.ReplaceAllStringFunc(clean, replace)
-
-	// Files and images
-	lines := strings.Split(clean, "\n")
-	var cleanedLines []string
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		if !strings.HasPrefix(trimmedLine, "[[File:") {
-			cleanedLines = append(cleanedLines, line)
-		}
-	}
-	clean = strings.Join(cleanedLines, "\n")
-
-	// Hyperlinks
-	m = regexp.MustCompile(`(?s)\[\[(.*?)\]\]`)
-	replace = func(match string) string {
-		bracketContent := match[2 : len(match)-2]
-		parts := strings.Split(bracketContent, "|")
-		if len(parts) != 2 {
-			return parts[0]
-		}
-		return linkStyle(parts[1])
-	}
-	clean = m.ReplaceAllStringFunc(clean, replace)
-
-	// Other brackets
-	replace = func(match string) string {
-		return linkStyle(match[2 : len(match)-2])
-	}
-	clean = m.ReplaceAllStringFunc(clean, replace)
-
-	// Strip HTML tags only after removing
-	// Wikimedia/XML tags
-	clean = strip.StripTags(clean)
-
-	// Replace HTML character entities
-	for entity, entityStr := range WikiHTMLCharacterEntities {
-		m = regexp.MustCompile(entity)
-		clean = m.ReplaceAllString(clean, entityStr)
-	}
-
-	// Bold italic
-	m = regexp.MustCompile(`'''''(.*?)'''''`)
-	replace = func(match string) string {
-		return articleBoldedItalicStyle(match[5 : len(match)-5])
-	}
-	clean = m.ReplaceAllStringFunc(clean, replace)
-
-	// Bold
-	m = regexp.MustCompile(`'''(.*?)'''`)
-	replace = func(match string) string {
-		return articleBoldedStyle(match[3 : len(match)-3])
-	}
-	clean = m.ReplaceAllStringFunc(clean, replace)
-
-	// Italic
-	m = regexp.MustCompile(`''(.*?)''`)
-	replace = func(match string) string {
-		return articleItalicStyle(match[2 : len(match)-2])
-	}
-	clean = m.ReplaceAllStringFunc(clean, replace)
-
-	// Anything more than three consecutive newlines is excessive
-	m = regexp.MustCompile(`\n{4,}`)
-	clean = m.ReplaceAllString(clean, "\n\n\n")
-	return clean
+	return render(ParseWikitext(dirty), 0, renderOptions{infobox: infoboxHidden}).String()
 }
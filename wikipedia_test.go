@@ -22,8 +22,12 @@ var tests = map[string]struct {
 		result: "link",
 	},
 	"IBM wiki refs": {
-		input:  "and present in over 175 countries.<ref>{{Cite web |date=June 27, 2019 |title=Trust and responsibility. Earned and practiced daily. |url=https://www.ibm.com/blogs/corporate-social-responsibility/2019/06/trust-and-responsibility-earned-and-practiced-daily/ |access-date=December 30, 2022 |website=IBM Impact |language=en-US}}</ref><ref name=\"auto\">{{cite web|website=10-K|url=https://www.sec.gov/Archives/edgar/data/51143/104746919000712/0001047469-19-000712-index.htm|title=10-K|access-date=June 1, 2019|ref={{harvid|10-K|2018}}|archive-date=December 5, 2019|archive-url=https://web.archive.org/web/20191205181213/https://www.sec.gov/Archives/edgar/data/51143/104746919000712/0001047469-19-000712-index.htm|url-status=live}}</ref> IBM is the largest industrial research",
-		result: "and present in over 175 countries. IBM is the largest industrial research",
+		input: "and present in over 175 countries.<ref>{{Cite web |date=June 27, 2019 |title=Trust and responsibility. Earned and practiced daily. |url=https://www.ibm.com/blogs/corporate-social-responsibility/2019/06/trust-and-responsibility-earned-and-practiced-daily/ |access-date=December 30, 2022 |website=IBM Impact |language=en-US}}</ref><ref name=\"auto\">{{cite web|website=10-K|url=https://www.sec.gov/Archives/edgar/data/51143/104746919000712/0001047469-19-000712-index.htm|title=10-K|access-date=June 1, 2019|ref={{harvid|10-K|2018}}|archive-date=December 5, 2019|archive-url=https://web.archive.org/web/20191205181213/https://www.sec.gov/Archives/edgar/data/51143/104746919000712/0001047469-19-000712-index.htm|url-status=live}}</ref> IBM is the largest industrial research",
+		result: "and present in over 175 countries." + noteStyle("[1][2]") + " IBM is the largest industrial research\n\n" +
+			articleHeadingStyle("References") + "\n\n" +
+			"1. (June 27, 2019). " + articleItalicStyle("Trust and responsibility. Earned and practiced daily") + ". IBM Impact. " +
+			linkStyle("https://www.ibm.com/blogs/corporate-social-responsibility/2019/06/trust-and-responsibility-earned-and-practiced-daily/") + "\n" +
+			"2. " + articleItalicStyle("10-K") + ". " + linkStyle("https://www.sec.gov/Archives/edgar/data/51143/104746919000712/0001047469-19-000712-index.htm"),
 	},
 	"File": {
 		input: `
@@ -31,7 +35,7 @@ var tests = map[string]struct {
 [[File:IBM360-67AtUmichWithMikeAlexander.jpg|thumb|right|An [[IBM System/360]] in use at the [[University of Michigan]] {{Circa|1969}}]]
 [[File:Saturn_IB_and_V_Instrument_Unit.jpg|thumb|IBM guidance computer hardware for the [[Saturn V Instrument Unit]]]]
 `,
-		result: "\n\n",
+		result: "",
 	},
 	"Brackets": {
 		input:  "here are [[brackets]] wow",
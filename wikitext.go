@@ -0,0 +1,965 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ------------------------------------------
+// Wikitext document tree
+// ------------------------------------------
+
+// Node is an element of a parsed wikitext document.
+// Block nodes (Section, Paragraph, List, Table, HorizontalRule)
+// hold inline nodes (Text, Bold, Italic, Link, ExternalLink,
+// Template, Tag).
+type Node interface {
+	wikiNode()
+}
+
+// Document is the root of a parsed article. Content before
+// the first heading comes ahead of the top level sections.
+type Document struct {
+	Children []Node
+}
+
+// Section is a heading along with everything up to the next
+// heading of the same or a higher level, subsections included.
+type Section struct {
+	Level    int
+	Title    []Node
+	Children []Node
+}
+
+type Paragraph struct {
+	Children []Node
+}
+
+// List holds the items of a bulleted (*), numbered (#) or
+// definition (; and :) list. Deeper items hang off the
+// item before them as a Sublist.
+type List struct {
+	Items []*ListItem
+}
+
+type ListItem struct {
+	Marker   byte
+	Children []Node
+	Sublist  *List
+}
+
+type Table struct {
+	Attrs   map[string]string
+	Caption []Node
+	Rows    []*TableRow
+}
+
+type TableRow struct {
+	Attrs map[string]string
+	Cells []*TableCell
+}
+
+// TableCell children are inline nodes for one-line cells and
+// block nodes when the cell spans several lines.
+type TableCell struct {
+	Header   bool
+	Attrs    map[string]string
+	Children []Node
+}
+
+type HorizontalRule struct{}
+
+type Text struct {
+	Value string
+}
+
+type Bold struct {
+	Children []Node
+}
+
+type Italic struct {
+	Children []Node
+}
+
+// Link is an internal [[Target|label]] link. Children is
+// empty when the target doubles as the label.
+type Link struct {
+	Target   string
+	Children []Node
+}
+
+type ExternalLink struct {
+	URL      string
+	Children []Node
+}
+
+// Template is a {{Name|positional|name=value}} transclusion.
+type Template struct {
+	Name   string
+	Params []*Param
+}
+
+// Param is a template parameter. Name is empty for
+// positional parameters.
+type Param struct {
+	Name  string
+	Value []Node
+}
+
+// Tag is an HTML or extension tag such as <ref> or <small>.
+type Tag struct {
+	Name     string
+	Attrs    map[string]string
+	Children []Node
+}
+
+func (*Document) wikiNode()       {}
+func (*Section) wikiNode()        {}
+func (*Paragraph) wikiNode()      {}
+func (*List) wikiNode()           {}
+func (*ListItem) wikiNode()       {}
+func (*Table) wikiNode()          {}
+func (*TableRow) wikiNode()       {}
+func (*TableCell) wikiNode()      {}
+func (*HorizontalRule) wikiNode() {}
+func (*Text) wikiNode()           {}
+func (*Bold) wikiNode()           {}
+func (*Italic) wikiNode()         {}
+func (*Link) wikiNode()           {}
+func (*ExternalLink) wikiNode()   {}
+func (*Template) wikiNode()       {}
+func (*Tag) wikiNode()            {}
+
+// Key is the template name normalized for lookups:
+// lower case with underscores as spaces.
+func (t *Template) Key() string {
+	name := strings.ToLower(strings.ReplaceAll(t.Name, "_", " "))
+	name = strings.TrimPrefix(name, "template:")
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Positional returns the unnamed parameters in order.
+func (t *Template) Positional() [][]Node {
+	var params [][]Node
+	for _, param := range t.Params {
+		if param.Name == "" {
+			params = append(params, param.Value)
+		}
+	}
+	return params
+}
+
+// Arg returns the nth (1-based) positional parameter,
+// honoring explicit numbering like |2=value.
+func (t *Template) Arg(n int) []Node {
+	if value, ok := t.Named(strconv.Itoa(n)); ok {
+		return value
+	}
+	positional := t.Positional()
+	if n < 1 || n > len(positional) {
+		return nil
+	}
+	return positional[n-1]
+}
+
+// Named returns the value of the last parameter called name.
+func (t *Template) Named(name string) ([]Node, bool) {
+	for i := len(t.Params) - 1; i >= 0; i-- {
+		if t.Params[i].Name == name {
+			return t.Params[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// PlainText flattens inline nodes into unstyled text,
+// leaving out templates and references.
+func PlainText(nodes []Node) string {
+	var b strings.Builder
+	var walk func([]Node)
+	walk = func(nodes []Node) {
+		for _, node := range nodes {
+			switch n := node.(type) {
+			case *Text:
+				b.WriteString(n.Value)
+			case *Bold:
+				walk(n.Children)
+			case *Italic:
+				walk(n.Children)
+			case *Paragraph:
+				walk(n.Children)
+			case *Link:
+				if len(n.Children) == 0 {
+					b.WriteString(n.Target)
+				}
+				walk(n.Children)
+			case *ExternalLink:
+				walk(n.Children)
+			case *Tag:
+				if n.Name != "ref" {
+					walk(n.Children)
+				}
+			}
+		}
+	}
+	walk(nodes)
+	return b.String()
+}
+
+// ------------------------------------------
+// Parser
+// ------------------------------------------
+
+// stop marks the tokens that end a run of inline content
+type stop uint16
+
+const (
+	stopNewline     stop = 1 << iota // \n
+	stopPipe                         // |
+	stopTemplateEnd                  // }}
+	stopLinkEnd                      // ]]
+	stopExtLinkEnd                   // ]
+	stopCell                         // ||
+	stopHeaderCell                   // !!
+	stopColon                        // : after a definition term
+)
+
+// parseContext carries what the enclosing construct is waiting for
+type parseContext struct {
+	stops stop
+	// Closing tag that ends the current run, e.g. "ref"
+	tag string
+	// 'b' or 'i' while inside bold or italic text
+	format byte
+	// Block parsing inside a table cell stops at | and ! lines
+	table bool
+}
+
+type parser struct {
+	src string
+	pos int
+	// Starts of templates, links and tags already found unclosed.
+	// Their content parses the same wherever they're nested, so
+	// they're given up on without parsing to the end again.
+	unclosed map[int]bool
+}
+
+const listMarkers = "*#:;"
+
+var (
+	tagPattern       = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)([^<>]*?)(/?)>`)
+	attrPattern      = regexp.MustCompile(`([a-zA-Z][\w:-]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	magicWordPattern = regexp.MustCompile(`^__[A-Z]+__`)
+	commentPattern   = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// Tags without content
+var voidTags = map[string]bool{
+	"br": true, "hr": true, "wbr": true, "img": true,
+}
+
+// Extension tags whose content isn't wikitext
+var rawTags = map[string]bool{
+	"nowiki": true, "pre": true, "math": true, "chem": true, "ce": true,
+	"syntaxhighlight": true, "source": true, "score": true, "timeline": true,
+	"gallery": true, "graph": true, "mapframe": true, "maplink": true,
+	"templatedata": true, "includeonly": true, "imagemap": true,
+	"inputbox": true, "hiero": true,
+}
+
+// Layout wrappers whose content is kept as if the tag
+// wasn't there, so headings and lists inside still parse
+var transparentTags = map[string]bool{
+	"div": true, "center": true, "p": true, "section": true,
+	"noinclude": true, "onlyinclude": true,
+}
+
+// Parses wikitext into a Document
+func ParseWikitext(src string) *Document {
+	p := &parser{src: strings.ReplaceAll(src, "\r\n", "\n")}
+	return &Document{Children: nestSections(p.parseBlocks(parseContext{}))}
+}
+
+// Parses wikitext that has no block structure, e.g. a heading
+func parseInlineString(src string) []Node {
+	p := &parser{src: src}
+	return trimNodes(p.parseInline(parseContext{}))
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) rest() string {
+	return p.src[p.pos:]
+}
+
+// Remainder of the current line, without the newline
+func (p *parser) line() string {
+	rest := p.rest()
+	if i := strings.IndexByte(rest, '\n'); i >= 0 {
+		return rest[:i]
+	}
+	return rest
+}
+
+func (p *parser) skipLine() {
+	p.pos += len(p.line())
+	p.skipNewline()
+}
+
+func (p *parser) skipNewline() {
+	if !p.eof() && p.src[p.pos] == '\n' {
+		p.pos++
+	}
+}
+
+func (p *parser) quoteRun() int {
+	n := 0
+	for p.pos+n < len(p.src) && p.src[p.pos+n] == '\'' {
+		n++
+	}
+	return n
+}
+
+// ---------------
+// Block structure
+// ---------------
+
+func (p *parser) parseBlocks(ctx parseContext) []Node {
+	var blocks []Node
+	for !p.eof() {
+		if ctx.table && p.atTableMarker() {
+			break
+		}
+		line := p.line()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			p.skipLine()
+		case headingLevel(line) > 0:
+			blocks = append(blocks, p.parseHeading())
+		case strings.IndexByte(listMarkers, line[0]) >= 0:
+			blocks = append(blocks, p.parseList())
+		case strings.HasPrefix(trimmed, "{|"):
+			blocks = append(blocks, p.parseTable())
+		case strings.HasPrefix(line, "----"):
+			blocks = append(blocks, &HorizontalRule{})
+			p.skipLine()
+		default:
+			if paragraph := p.parseParagraph(ctx); len(paragraph.Children) > 0 {
+				blocks = append(blocks, paragraph)
+			}
+		}
+	}
+	return blocks
+}
+
+// Whether the current line starts a block other than a paragraph
+func (p *parser) atBlockStart(ctx parseContext) bool {
+	if ctx.table && p.atTableMarker() {
+		return true
+	}
+	line := p.line()
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" ||
+		headingLevel(line) > 0 ||
+		strings.IndexByte(listMarkers, line[0]) >= 0 ||
+		strings.HasPrefix(trimmed, "{|") ||
+		strings.HasPrefix(line, "----")
+}
+
+func (p *parser) atTableMarker() bool {
+	line := strings.TrimLeft(p.line(), " \t")
+	return strings.HasPrefix(line, "|") || strings.HasPrefix(line, "!")
+}
+
+// Consecutive lines of text, ended by a blank line or another block
+func (p *parser) parseParagraph(ctx parseContext) *Paragraph {
+	var children []Node
+	for {
+		children = append(children, p.parseInline(parseContext{stops: stopNewline})...)
+		p.skipNewline()
+		if p.eof() || p.atBlockStart(ctx) {
+			break
+		}
+		// Single newlines inside a paragraph are soft breaks
+		children = append(children, &Text{Value: "\n"})
+	}
+	return &Paragraph{Children: trimNodes(children)}
+}
+
+// Level of a heading line like "== History ==", or 0
+func headingLevel(line string) int {
+	line = strings.TrimSpace(commentPattern.ReplaceAllString(line, ""))
+	lead := len(line) - len(strings.TrimLeft(line, "="))
+	trail := len(line) - len(strings.TrimRight(line, "="))
+	level := min(lead, trail, 6)
+	if level == 0 || len(line) <= 2*level {
+		return 0
+	}
+	return level
+}
+
+// Headings are emitted as empty sections, see nestSections
+func (p *parser) parseHeading() *Section {
+	line := p.line()
+	p.skipLine()
+	level := headingLevel(line)
+	line = strings.TrimSpace(commentPattern.ReplaceAllString(line, ""))
+	return &Section{
+		Level: level,
+		Title: parseInlineString(line[level : len(line)-level]),
+	}
+}
+
+// Moves blocks following a heading into its section
+func nestSections(blocks []Node) []Node {
+	var root []Node
+	var stack []*Section
+	for _, block := range blocks {
+		section, isSection := block.(*Section)
+		if isSection {
+			for len(stack) > 0 && stack[len(stack)-1].Level >= section.Level {
+				stack = stack[:len(stack)-1]
+			}
+		}
+		if len(stack) == 0 {
+			root = append(root, block)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, block)
+		}
+		if isSection {
+			stack = append(stack, section)
+		}
+	}
+	return root
+}
+
+func (p *parser) parseList() *List {
+	list := &List{}
+	for !p.eof() && strings.IndexByte(listMarkers, p.src[p.pos]) >= 0 {
+		start := p.pos
+		for !p.eof() && strings.IndexByte(listMarkers, p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		prefix := p.src[start:p.pos]
+		marker := prefix[len(prefix)-1]
+
+		// ;term : definition
+		ctx := parseContext{stops: stopNewline}
+		if marker == ';' {
+			ctx.stops |= stopColon
+		}
+		list.insert(prefix, &ListItem{Marker: marker, Children: trimNodes(p.parseInline(ctx))})
+		if marker == ';' && !p.eof() && p.src[p.pos] == ':' {
+			p.pos++
+			definition := trimNodes(p.parseInline(parseContext{stops: stopNewline}))
+			list.insert(prefix, &ListItem{Marker: ':', Children: definition})
+		}
+		p.skipNewline()
+	}
+	return list
+}
+
+// Adds an item at the depth given by its marker prefix,
+// e.g. "#*" is a bullet nested under a numbered item
+func (l *List) insert(prefix string, item *ListItem) {
+	for depth := 1; depth < len(prefix); depth++ {
+		if len(l.Items) == 0 {
+			l.Items = append(l.Items, &ListItem{Marker: prefix[depth-1]})
+		}
+		last := l.Items[len(l.Items)-1]
+		if last.Sublist == nil {
+			last.Sublist = &List{}
+		}
+		l = last.Sublist
+	}
+	l.Items = append(l.Items, item)
+}
+
+func (p *parser) parseTable() *Table {
+	line := strings.TrimSpace(p.line())
+	table := &Table{Attrs: parseAttrs(line[2:])}
+	p.skipLine()
+
+	var row *TableRow
+	for !p.eof() {
+		// Table markers may be indented
+		for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+			p.pos++
+		}
+		rest := p.rest()
+		switch {
+		case strings.HasPrefix(rest, "|}"):
+			p.skipLine()
+			return table.prune()
+		case strings.HasPrefix(rest, "|+"):
+			p.pos += 2
+			p.cellAttrs(false)
+			table.Caption = trimNodes(p.parseInline(parseContext{stops: stopNewline}))
+			p.skipNewline()
+		case strings.HasPrefix(rest, "|-"):
+			row = &TableRow{Attrs: parseAttrs(strings.TrimLeft(p.line(), "|-"))}
+			table.Rows = append(table.Rows, row)
+			p.skipLine()
+		case strings.HasPrefix(rest, "|"), strings.HasPrefix(rest, "!"):
+			header := rest[0] == '!'
+			p.pos++
+			if row == nil {
+				row = &TableRow{}
+				table.Rows = append(table.Rows, row)
+			}
+			for {
+				row.Cells = append(row.Cells, p.parseCell(header))
+				if strings.HasPrefix(p.rest(), "||") || header && strings.HasPrefix(p.rest(), "!!") {
+					p.pos += 2
+					continue
+				}
+				break
+			}
+		default:
+			p.skipLine()
+		}
+	}
+	return table.prune()
+}
+
+// Drops rows without cells, e.g. a |- right before |}
+func (t *Table) prune() *Table {
+	rows := t.Rows[:0]
+	for _, row := range t.Rows {
+		if len(row.Cells) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	t.Rows = rows
+	return t
+}
+
+func (p *parser) parseCell(header bool) *TableCell {
+	cell := &TableCell{Header: header, Attrs: p.cellAttrs(header)}
+	ctx := parseContext{stops: stopNewline | stopCell}
+	if header {
+		ctx.stops |= stopHeaderCell
+	}
+	children := trimNodes(p.parseInline(ctx))
+	if p.eof() || p.src[p.pos] != '\n' {
+		cell.Children = children
+		return cell
+	}
+
+	// Content on the lines that follow belongs to the cell
+	// until the next row, cell or end of the table
+	p.pos++
+	blocks := p.parseBlocks(parseContext{table: true})
+	if len(blocks) == 0 {
+		cell.Children = children
+		return cell
+	}
+	if len(children) > 0 {
+		blocks = append([]Node{&Paragraph{Children: children}}, blocks...)
+	}
+	cell.Children = blocks
+	return cell
+}
+
+// Consumes the `style="..." |` attribute part of a cell if present
+func (p *parser) cellAttrs(header bool) map[string]string {
+	segment := p.line()
+	if i := strings.Index(segment, "||"); i >= 0 {
+		segment = segment[:i]
+	}
+	if i := strings.Index(segment, "!!"); header && i >= 0 {
+		segment = segment[:i]
+	}
+	i := strings.IndexByte(segment, '|')
+	if i < 0 || strings.ContainsAny(segment[:i], "[{<") {
+		return nil
+	}
+	p.pos += i + 1
+	return parseAttrs(segment[:i])
+}
+
+func parseAttrs(s string) map[string]string {
+	matches := attrPattern.FindAllStringSubmatch(s, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	attrs := make(map[string]string, len(matches))
+	for _, m := range matches {
+		attrs[strings.ToLower(m[1])] = m[2] + m[3] + m[4]
+	}
+	return attrs
+}
+
+// -------------
+// Inline markup
+// -------------
+
+func (p *parser) atStop(ctx parseContext) bool {
+	rest := p.rest()
+	c := rest[0]
+	switch {
+	case ctx.stops&stopNewline != 0 && c == '\n':
+	case ctx.stops&stopPipe != 0 && c == '|':
+	case ctx.stops&stopTemplateEnd != 0 && strings.HasPrefix(rest, "}}"):
+	case ctx.stops&stopLinkEnd != 0 && strings.HasPrefix(rest, "]]"):
+	case ctx.stops&stopExtLinkEnd != 0 && c == ']':
+	case ctx.stops&stopCell != 0 && strings.HasPrefix(rest, "||"):
+	case ctx.stops&stopHeaderCell != 0 && strings.HasPrefix(rest, "!!"):
+	case ctx.stops&stopColon != 0 && c == ':':
+	case ctx.tag != "" && c == '<' && closingTagLength(rest, ctx.tag) > 0:
+	case ctx.format != 0 && c == '\'':
+		n := p.quoteRun()
+		return ctx.format == 'i' && (n == 2 || n >= 5) ||
+			ctx.format == 'b' && (n == 3 || n >= 5)
+	default:
+		return false
+	}
+	return true
+}
+
+func (p *parser) parseInline(ctx parseContext) []Node {
+	var nodes []Node
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, &Text{Value: html.UnescapeString(text.String())})
+			text.Reset()
+		}
+	}
+
+	for !p.eof() && !p.atStop(ctx) {
+		rest := p.rest()
+		var node Node
+		ok := false
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest, "-->")
+			if end < 0 {
+				end = len(rest) - 3
+			}
+			p.pos += end + 3
+			continue
+		case strings.HasPrefix(rest, "{{"):
+			node, ok = p.parseTemplate()
+		case strings.HasPrefix(rest, "[["):
+			node, ok = p.parseLink()
+		case rest[0] == '[':
+			node, ok = p.parseExternalLink()
+		case rest[0] == '<':
+			node, ok = p.parseTag()
+		case strings.HasPrefix(rest, "''"):
+			// Four apostrophes are one literal and bold,
+			// more than five are literals and bold italic
+			switch n := p.quoteRun(); {
+			case n == 4:
+				text.WriteByte('\'')
+				p.pos++
+				continue
+			case n > 5:
+				text.WriteString(rest[:n-5])
+				p.pos += n - 5
+				continue
+			}
+			node, ok = p.parseFormatting(ctx)
+		case strings.HasPrefix(rest, "__"):
+			if m := magicWordPattern.FindString(rest); m != "" {
+				p.pos += len(m)
+				continue
+			}
+		}
+		if ok {
+			if node != nil {
+				flush()
+				nodes = append(nodes, node)
+			}
+			continue
+		}
+		text.WriteByte(rest[0])
+		p.pos++
+	}
+	flush()
+	return nodes
+}
+
+func (p *parser) parseFormatting(ctx parseContext) (Node, bool) {
+	inner := ctx
+	inner.stops |= stopNewline
+	switch p.quoteRun() {
+	case 2:
+		p.pos += 2
+		inner.format = 'i'
+		italic := &Italic{Children: p.parseInline(inner)}
+		p.closeFormatting(2)
+		return italic, true
+	case 3:
+		p.pos += 3
+		inner.format = 'b'
+		bold := &Bold{Children: p.parseInline(inner)}
+		p.closeFormatting(3)
+		return bold, true
+	}
+
+	// '''''bold italic''''' or '''''bold italic'' bold'''
+	p.pos += 5
+	inner.format = 'i'
+	italic := &Italic{Children: p.parseInline(inner)}
+	bold := &Bold{Children: []Node{italic}}
+	if p.closeFormatting(2) {
+		inner.format = 'b'
+		bold.Children = append(bold.Children, p.parseInline(inner)...)
+		p.closeFormatting(3)
+	}
+	return bold, true
+}
+
+// Consumes n closing apostrophes if they're there.
+// Unclosed formatting ends with the line.
+func (p *parser) closeFormatting(n int) bool {
+	if p.quoteRun() >= n {
+		p.pos += n
+		return true
+	}
+	return false
+}
+
+// Gives up on the construct at start, remembering it's unclosed
+func (p *parser) fail(start int) {
+	if p.unclosed == nil {
+		p.unclosed = map[int]bool{}
+	}
+	p.unclosed[start] = true
+	p.pos = start
+}
+
+// {{Name|positional|name=value}}
+func (p *parser) parseTemplate() (Node, bool) {
+	start := p.pos
+	if p.unclosed[start] {
+		return nil, false
+	}
+	p.pos += 2
+	name := p.parseInline(parseContext{stops: stopPipe | stopTemplateEnd})
+	template := &Template{Name: strings.TrimSpace(PlainText(name))}
+	for {
+		if p.eof() {
+			p.fail(start)
+			return nil, false
+		}
+		if strings.HasPrefix(p.rest(), "}}") {
+			p.pos += 2
+			return template, true
+		}
+		p.pos++ // |
+		value := p.parseInline(parseContext{stops: stopPipe | stopTemplateEnd})
+		template.Params = append(template.Params, newParam(value))
+	}
+}
+
+// Splits name=value parameters. Only an equals sign
+// before any nested markup names the parameter.
+func newParam(value []Node) *Param {
+	if len(value) == 0 {
+		return &Param{}
+	}
+	first, ok := value[0].(*Text)
+	if !ok {
+		return &Param{Value: value}
+	}
+	name, rest, found := strings.Cut(first.Value, "=")
+	if !found {
+		return &Param{Value: value}
+	}
+	named := value[1:]
+	if rest != "" {
+		named = append([]Node{&Text{Value: rest}}, named...)
+	}
+	return &Param{Name: strings.TrimSpace(name), Value: trimNodes(named)}
+}
+
+// [[Target]], [[Target|label]] and [[Target]]trail
+func (p *parser) parseLink() (Node, bool) {
+	start := p.pos
+	if p.unclosed[start] {
+		return nil, false
+	}
+	p.pos += 2
+	end := strings.IndexAny(p.rest(), "|]\n")
+	if end < 0 || p.rest()[end] == '\n' {
+		p.pos = start
+		return nil, false
+	}
+	link := &Link{Target: strings.TrimSpace(html.UnescapeString(p.rest()[:end]))}
+	p.pos += end
+	if p.src[p.pos] == '|' {
+		p.pos++
+		link.Children = trimNodes(p.parseInline(parseContext{stops: stopLinkEnd}))
+	}
+	if !strings.HasPrefix(p.rest(), "]]") {
+		p.fail(start)
+		return nil, false
+	}
+	p.pos += 2
+
+	// Letters right after the link are part of its label
+	trail := 0
+	for p.pos+trail < len(p.src) && isASCIILetter(p.src[p.pos+trail]) {
+		trail++
+	}
+	if trail > 0 {
+		if len(link.Children) == 0 {
+			link.Children = []Node{&Text{Value: link.Target}}
+		}
+		link.Children = mergeText(append(link.Children, &Text{Value: p.src[p.pos : p.pos+trail]}))
+		p.pos += trail
+	}
+	return link, true
+}
+
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+var urlSchemes = []string{"http://", "https://", "//", "ftp://", "mailto:"}
+
+// [https://example.com label]
+func (p *parser) parseExternalLink() (Node, bool) {
+	start := p.pos
+	if p.unclosed[start] {
+		return nil, false
+	}
+	rest := p.rest()[1:]
+	isURL := false
+	for _, scheme := range urlSchemes {
+		isURL = isURL || strings.HasPrefix(strings.ToLower(rest), scheme)
+	}
+	if !isURL {
+		return nil, false
+	}
+	end := strings.IndexAny(rest, " ]\n")
+	if end < 0 || rest[end] == '\n' {
+		return nil, false
+	}
+	link := &ExternalLink{URL: rest[:end]}
+	p.pos += 1 + end
+	if p.src[p.pos] == ' ' {
+		p.pos++
+		link.Children = trimNodes(p.parseInline(parseContext{stops: stopExtLinkEnd | stopNewline}))
+	}
+	if p.eof() || p.src[p.pos] != ']' {
+		p.fail(start)
+		return nil, false
+	}
+	p.pos++
+	return link, true
+}
+
+// <tag attrs>children</tag>, <tag/>, and stray </tag>
+func (p *parser) parseTag() (Node, bool) {
+	m := tagPattern.FindStringSubmatch(p.rest())
+	if m == nil {
+		return nil, false
+	}
+	name := strings.ToLower(m[2])
+	p.pos += len(m[0])
+	if m[1] == "/" || transparentTags[name] {
+		return nil, true
+	}
+
+	tag := &Tag{Name: name, Attrs: parseAttrs(m[3])}
+	if m[4] == "/" || voidTags[name] {
+		return tag, true
+	}
+
+	if rawTags[name] {
+		rest := p.rest()
+		end := indexClosingTag(rest, name)
+		if end < 0 {
+			end = len(rest)
+		}
+		tag.Children = []Node{&Text{Value: html.UnescapeString(rest[:end])}}
+		p.pos += end
+		p.pos += closingTagLength(p.rest(), name)
+		return tag, true
+	}
+
+	// Unclosed, so carry on as if the tag were absent
+	start := p.pos
+	if p.unclosed[start] {
+		return tag, true
+	}
+	children := p.parseInline(parseContext{tag: name})
+	n := closingTagLength(p.rest(), name)
+	if n == 0 {
+		p.fail(start)
+		return tag, true
+	}
+	p.pos += n
+	tag.Children = children
+	return tag, true
+}
+
+// Length of a </name> tag at the start of s, or 0
+func closingTagLength(s string, name string) int {
+	if len(s) < len(name)+3 || s[:2] != "</" || !strings.EqualFold(s[2:2+len(name)], name) {
+		return 0
+	}
+	i := 2 + len(name)
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	if i < len(s) && s[i] == '>' {
+		return i + 1
+	}
+	return 0
+}
+
+func indexClosingTag(s string, name string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '<' && closingTagLength(s[i:], name) > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// Joins adjacent text nodes
+func mergeText(nodes []Node) []Node {
+	var merged []Node
+	for _, node := range nodes {
+		if text, ok := node.(*Text); ok && len(merged) > 0 {
+			if last, ok := merged[len(merged)-1].(*Text); ok {
+				merged[len(merged)-1] = &Text{Value: last.Value + text.Value}
+				continue
+			}
+		}
+		merged = append(merged, node)
+	}
+	return merged
+}
+
+// Strips whitespace around a run of inline nodes
+func trimNodes(nodes []Node) []Node {
+	nodes = mergeText(nodes)
+	if len(nodes) > 0 {
+		if text, ok := nodes[0].(*Text); ok {
+			nodes[0] = &Text{Value: strings.TrimLeft(text.Value, " \t\n")}
+		}
+		if text, ok := nodes[len(nodes)-1].(*Text); ok {
+			nodes[len(nodes)-1] = &Text{Value: strings.TrimRight(text.Value, " \t\n")}
+		}
+	}
+	trimmed := nodes[:0]
+	for _, node := range nodes {
+		if text, ok := node.(*Text); ok && text.Value == "" {
+			continue
+		}
+		trimmed = append(trimmed, node)
+	}
+	return trimmed
+}
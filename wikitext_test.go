@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Writes a node tree in a compact form for comparisons
+func dump(nodes []Node) string {
+	var parts []string
+	for _, node := range nodes {
+		parts = append(parts, dumpNode(node))
+	}
+	return strings.Join(parts, " ")
+}
+
+func dumpAttrs(attrs map[string]string) string {
+	var keys []string
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, attrs[k]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func dumpNode(node Node) string {
+	switch n := node.(type) {
+	case *Text:
+		return fmt.Sprintf("%q", n.Value)
+	case *Bold:
+		return fmt.Sprintf("b(%s)", dump(n.Children))
+	case *Italic:
+		return fmt.Sprintf("i(%s)", dump(n.Children))
+	case *Link:
+		return fmt.Sprintf("link[%s](%s)", n.Target, dump(n.Children))
+	case *ExternalLink:
+		return fmt.Sprintf("ext[%s](%s)", n.URL, dump(n.Children))
+	case *Template:
+		var params []string
+		for _, p := range n.Params {
+			params = append(params, fmt.Sprintf("%s=%s", p.Name, dump(p.Value)))
+		}
+		return fmt.Sprintf("tpl[%s](%s)", n.Name, strings.Join(params, " | "))
+	case *Tag:
+		return fmt.Sprintf("<%s %s>(%s)", n.Name, dumpAttrs(n.Attrs), dump(n.Children))
+	case *Paragraph:
+		return fmt.Sprintf("p(%s)", dump(n.Children))
+	case *Section:
+		return fmt.Sprintf("h%d[%s](%s)", n.Level, dump(n.Title), dump(n.Children))
+	case *List:
+		var items []string
+		for _, item := range n.Items {
+			s := fmt.Sprintf("%c(%s)", item.Marker, dump(item.Children))
+			if item.Sublist != nil {
+				s += dumpNode(item.Sublist)
+			}
+			items = append(items, s)
+		}
+		return fmt.Sprintf("list{%s}", strings.Join(items, " "))
+	case *Table:
+		var rows []string
+		for _, row := range n.Rows {
+			var cells []string
+			for _, cell := range row.Cells {
+				kind := "td"
+				if cell.Header {
+					kind = "th"
+				}
+				cells = append(cells, fmt.Sprintf("%s[%s](%s)", kind, dumpAttrs(cell.Attrs), dump(cell.Children)))
+			}
+			rows = append(rows, strings.Join(cells, " "))
+		}
+		return fmt.Sprintf("table[%s](%s){%s}", dumpAttrs(n.Attrs), dump(n.Caption), strings.Join(rows, " / "))
+	case *HorizontalRule:
+		return "hr"
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func TestParseWikitext(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		result string
+	}{
+		"plain paragraph": {
+			input:  "Giraffes are tall.",
+			result: `p("Giraffes are tall.")`,
+		},
+		"paragraphs split on blank lines": {
+			input:  "one\ntwo\n\nthree",
+			result: `p("one\ntwo") p("three")`,
+		},
+		"bold and italic": {
+			input:  "'''bold''' and ''italic'' and '''''both'''''",
+			result: `p(b("bold") " and " i("italic") " and " b(i("both")))`,
+		},
+		"quotes close at the end of the line": {
+			input:  "''unclosed\nnext line",
+			result: `p(i("unclosed") "\nnext line")`,
+		},
+		"bold inside italic": {
+			input:  "''a '''b''' c''",
+			result: `p(i("a " b("b") " c"))`,
+		},
+		"apostrophes": {
+			input:  "the cat's pyjamas",
+			result: `p("the cat's pyjamas")`,
+		},
+		"links": {
+			input:  "[[Fauna of Africa|African]] [[giraffe]]s",
+			result: `p(link[Fauna of Africa]("African") " " link[giraffe]("giraffes"))`,
+		},
+		"external link": {
+			input:  "[https://example.com Example site]",
+			result: `p(ext[https://example.com]("Example site"))`,
+		},
+		"nested templates": {
+			input:  "{{lang|zh-Hant|{{linktext|維基百科}} / {{linktext|维基百科}}}}",
+			result: `p(tpl[lang](="zh-Hant" | =tpl[linktext](="維基百科") " / " tpl[linktext](="维基百科")))`,
+		},
+		"named parameters and links inside templates": {
+			input:  "{{cite web |title=A [[b|c]] d |url=https://x.org/?a=b}}",
+			result: `p(tpl[cite web](title="A " link[b]("c") " d" | url="https://x.org/?a=b"))`,
+		},
+		"pipes inside links don't split parameters": {
+			input:  "{{Infobox\n| type = [[Privately held company|Private]]\n}}",
+			result: `p(tpl[Infobox](type=link[Privately held company]("Private")))`,
+		},
+		"unclosed template is text": {
+			input:  "{{oops",
+			result: `p("{{oops")`,
+		},
+		"refs": {
+			input:  `a.<ref name="x">{{cite web|title=T}}</ref> b<ref name=x />`,
+			result: `p("a." <ref name=x>(tpl[cite web](title="T")) " b" <ref name=x>())`,
+		},
+		"comments and entities": {
+			input:  "a<!-- hidden -->&nbsp;b &quot;c&quot;",
+			result: `p("a\u00a0b \"c\"")`,
+		},
+		"nowiki": {
+			input:  "<nowiki>[[not a link]]</nowiki>",
+			result: `p(<nowiki >("[[not a link]]"))`,
+		},
+		"unclosed tag": {
+			input:  "<small>tiny",
+			result: `p(<small >() "tiny")`,
+		},
+		"sections": {
+			input:  "lead\n== History ==\nold\n=== Early ===\nolder\n== Today ==\nnew",
+			result: `p("lead") h2["History"](p("old") h3["Early"](p("older"))) h2["Today"](p("new"))`,
+		},
+		"lists": {
+			input:  "* a\n** b\n* c\n# one\n#: more",
+			result: `list{*("a")list{*("b")} *("c") #("one")list{:("more")}}`,
+		},
+		"definition list": {
+			input:  "; term : definition\n; other",
+			result: `list{;("term") :("definition") ;("other")}`,
+		},
+		"table": {
+			input: `{| class="wikitable"
+|+ Caption
+|-
+! Year !! Title
+|-
+| 1999 || ''Film''
+|-
+| colspan="2" | Multiple
+lines
+|}
+after`,
+			result: `table[class=wikitable]("Caption"){th[]("Year") th[]("Title") / td[]("1999") td[](i("Film")) / td[colspan=2](p("Multiple") p("lines"))} p("after")`,
+		},
+		"template with pipes at line starts inside a table": {
+			input:  "{|\n| {{x\n|a=b}}\n|}",
+			result: `table[](){td[](tpl[x](a="b"))}`,
+		},
+		"horizontal rule and magic words": {
+			input:  "__NOTOC__\n----\ntext",
+			result: `hr p("text")`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if got := dump(ParseWikitext(test.input).Children); got != test.result {
+				t.Fatalf("function ParseWikitext\n---INPUT\n%q\n---GOT\n%s\n---EXPECTED\n%s\n---", test.input, got, test.result)
+			}
+		})
+	}
+}
+
+// Unclosed markup used to be parsed again at every level of nesting
+func TestParseWikitextUnclosed(t *testing.T) {
+	tests := map[string]string{
+		"tags":           strings.Repeat("<span>a ", 40),
+		"templates":      strings.Repeat("{{a|", 40),
+		"links":          strings.Repeat("[[a|", 40),
+		"external links": strings.Repeat("[http://a b ", 40),
+		"mixed":          strings.Repeat("<small>{{a|[[b|<li>", 40),
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			start := time.Now()
+			ParseWikitext(input)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Fatalf("function ParseWikitext took %v on unclosed %s", elapsed, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Wiktionary entries are read the way the English Wiktionary lays them
+// out: a level 2 section per language, holding sections for the word's
+// etymology, pronunciation, each part of speech and its translations.
+// https://en.wiktionary.org/wiki/Wiktionary:Entry_layout
+
+// Dictionary is a word's Wiktionary entry
+type Dictionary struct {
+	Word      string
+	Languages []DictionaryLanguage
+	// The page itself, shown when its layout isn't understood
+	page *Document
+}
+
+// DictionaryLanguage is what a word means in one language
+type DictionaryLanguage struct {
+	// e.g. English
+	Name        string
+	Etymologies [][]Node
+	// IPA transcriptions, with the accents they're for
+	// ahead, e.g. (UK) /dʒɪˈɹɑːf/
+	Pronunciations []string
+	Senses         []PartOfSpeech
+	Translations   []Translation
+}
+
+// PartOfSpeech is a word's definitions as a noun, a verb, and so on
+type PartOfSpeech struct {
+	Name        string
+	Definitions []Definition
+}
+
+type Definition struct {
+	Text     []Node
+	Examples [][]Node
+	// Narrower senses, from ## items
+	Subsenses []Definition
+}
+
+// Translation is a word for a sense of the entry in another language
+type Translation struct {
+	// Gloss of the sense translated, e.g. tall African mammal
+	Sense string
+	Lang  string
+	Words string
+}
+
+// Section titles of parts of speech, in lower case
+var partsOfSpeech = map[string]bool{
+	"noun": true, "proper noun": true, "verb": true, "adjective": true,
+	"adverb": true, "pronoun": true, "preposition": true, "postposition": true,
+	"conjunction": true, "interjection": true, "determiner": true, "article": true,
+	"numeral": true, "number": true, "particle": true, "classifier": true,
+	"participle": true, "prefix": true, "suffix": true, "infix": true,
+	"affix": true, "phrase": true, "prepositional phrase": true, "proverb": true,
+	"idiom": true, "contraction": true, "abbreviation": true, "initialism": true,
+	"acronym": true, "symbol": true, "letter": true,
+}
+
+// Names of languages words often come from, by the codes etymology
+// templates use, beyond those of the Wikipedias in WikipediaLangs
+var etymologyLangs = map[string]string{
+	"la": "Latin", "grc": "Ancient Greek", "el": "Greek", "ang": "Old English",
+	"enm": "Middle English", "fro": "Old French", "frm": "Middle French",
+	"non": "Old Norse", "gem-pro": "Proto-Germanic", "ine-pro": "Proto-Indo-European",
+	"LL.": "Late Latin", "ML.": "Medieval Latin", "NL.": "New Latin",
+	"sa": "Sanskrit", "goh": "Old High German", "gmh": "Middle High German",
+	"dum": "Middle Dutch", "xno": "Anglo-Norman", "sga": "Old Irish",
+}
+
+func languageName(code string) string {
+	if name, ok := WikipediaLangs[code]; ok {
+		return name
+	}
+	if name, ok := etymologyLangs[code]; ok {
+		return name
+	}
+	return code
+}
+
+// Reads the entry for word from its parsed Wiktionary page
+func ParseDictionary(word string, page *Document) *Dictionary {
+	d := &Dictionary{Word: word, page: page}
+	for _, node := range page.Children {
+		s, ok := node.(*Section)
+		if !ok || s.Level != 2 {
+			continue
+		}
+		lang := DictionaryLanguage{Name: sectionTitle(s)}
+		lang.read(s.Children)
+		if len(lang.Senses) > 0 {
+			d.Languages = append(d.Languages, lang)
+		}
+	}
+	return d
+}
+
+func sectionTitle(s *Section) string {
+	return strings.TrimSpace(PlainText(s.Title))
+}
+
+// Reads the sections under a language's, which nest when a word
+// has more than one etymology, as in "Etymology 1" > "Noun"
+func (l *DictionaryLanguage) read(nodes []Node) {
+	for _, node := range nodes {
+		s, ok := node.(*Section)
+		if !ok {
+			continue
+		}
+		title := sectionTitle(s)
+		switch kind := strings.ToLower(strings.TrimRight(title, " 0123456789")); {
+		case kind == "etymology":
+			var text []Node
+			for _, block := range s.Children {
+				if p, ok := block.(*Paragraph); ok {
+					text = append(text, expandWiktionary(p.Children)...)
+				}
+			}
+			if text = trimNodes(text); len(text) > 0 {
+				l.Etymologies = append(l.Etymologies, text)
+			}
+		case kind == "pronunciation":
+			l.Pronunciations = append(l.Pronunciations, pronunciations(s.Children)...)
+		case kind == "translations":
+			l.Translations = append(l.Translations, translations(s.Children)...)
+		case partsOfSpeech[kind]:
+			pos := PartOfSpeech{Name: title}
+			for _, block := range s.Children {
+				if list, ok := block.(*List); ok {
+					pos.Definitions = append(pos.Definitions, definitions(list)...)
+				}
+			}
+			if len(pos.Definitions) > 0 {
+				l.Senses = append(l.Senses, pos)
+			}
+		}
+		l.read(s.Children)
+	}
+}
+
+// The # items of a list, with their #: examples and ## subsenses.
+// #* quotations are left out.
+func definitions(list *List) []Definition {
+	var defs []Definition
+	for _, item := range list.Items {
+		if item.Marker != '#' {
+			continue
+		}
+		def := Definition{Text: expandWiktionary(item.Children)}
+		if item.Sublist != nil {
+			for _, sub := range item.Sublist.Items {
+				if sub.Marker == ':' && len(sub.Children) > 0 {
+					def.Examples = append(def.Examples, expandWiktionary(sub.Children))
+				}
+			}
+			def.Subsenses = definitions(item.Sublist)
+		}
+		if len(def.Text) > 0 || len(def.Subsenses) > 0 {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// Reads list items like * {{a|en|UK}} {{IPA|en|/dʒɪˈɹɑːf/}}
+func pronunciations(nodes []Node) []string {
+	var found []string
+	var walk func(*List)
+	walk = func(list *List) {
+		for _, item := range list.Items {
+			var accents, ipa []string
+			for _, node := range item.Children {
+				t, ok := node.(*Template)
+				if !ok {
+					continue
+				}
+				args := templateArgs(t)
+				switch t.Key() {
+				case "a", "accent":
+					// The language code came first in older uses
+					if len(args) > 1 {
+						args = args[1:]
+					}
+					accents = append(accents, args...)
+				case "ipa":
+					if len(args) > 1 {
+						ipa = append(ipa, args[1:]...)
+					}
+				}
+			}
+			if len(ipa) > 0 {
+				p := strings.Join(ipa, ", ")
+				if len(accents) > 0 {
+					p = "(" + strings.Join(accents, ", ") + ") " + p
+				}
+				found = append(found, p)
+			}
+			if item.Sublist != nil {
+				walk(item.Sublist)
+			}
+		}
+	}
+	for _, node := range nodes {
+		if list, ok := node.(*List); ok {
+			walk(list)
+		}
+	}
+	return found
+}
+
+// Reads the tables of translations between {{trans-top|sense}}
+// and {{trans-bottom}}, one list item per language
+func translations(nodes []Node) []Translation {
+	var found []Translation
+	sense := ""
+	var walk func(*List)
+	walk = func(list *List) {
+		for _, item := range list.Items {
+			name, rest, _ := strings.Cut(PlainText(item.Children), ":")
+			var words []string
+			for _, node := range item.Children {
+				t, ok := node.(*Template)
+				if !ok {
+					continue
+				}
+				switch t.Key() {
+				case "t", "t+", "tt", "tt+", "t-simple", "t-check", "t+check":
+					if word := strings.TrimSpace(PlainText(t.Arg(2))); word != "" {
+						words = append(words, word)
+					}
+				}
+			}
+			if len(words) == 0 && strings.TrimSpace(rest) != "" {
+				words = []string{strings.TrimSpace(rest)}
+			}
+			if name = strings.TrimSpace(name); name != "" && len(words) > 0 {
+				found = append(found, Translation{Sense: sense, Lang: name, Words: strings.Join(words, ", ")})
+			}
+			if item.Sublist != nil {
+				walk(item.Sublist)
+			}
+		}
+	}
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *Paragraph:
+			for _, child := range n.Children {
+				if t, ok := child.(*Template); ok && (t.Key() == "trans-top" || t.Key() == "checktrans-top") {
+					sense = strings.TrimSpace(PlainText(t.Arg(1)))
+				}
+			}
+		case *List:
+			walk(n)
+		}
+	}
+	return found
+}
+
+// The positional parameters of a template as plain text, leaving out
+// empty ones
+func templateArgs(t *Template) []string {
+	var args []string
+	for _, value := range t.Positional() {
+		if arg := strings.TrimSpace(PlainText(value)); arg != "" {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// Replaces the templates Wiktionary writes definitions and etymologies
+// with by what they stand for. Others are left to expandTemplate.
+func expandWiktionary(nodes []Node) []Node {
+	var expanded []Node
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *Template:
+			if stand, ok := wiktionaryTemplate(n); ok {
+				expanded = append(expanded, expandWiktionary(stand)...)
+				continue
+			}
+		case *Bold:
+			node = &Bold{Children: expandWiktionary(n.Children)}
+		case *Italic:
+			node = &Italic{Children: expandWiktionary(n.Children)}
+		}
+		expanded = append(expanded, node)
+	}
+	return mergeText(expanded)
+}
+
+func wiktionaryTemplate(t *Template) ([]Node, bool) {
+	text := func(s string) []Node {
+		return []Node{&Text{Value: s}}
+	}
+	args := templateArgs(t)
+	switch t.Key() {
+	// https://en.wiktionary.org/wiki/Template:label
+	// {{lb|en|zoology|informal}} is (zoology, informal), with _
+	// and "or" joining their neighbours without a comma
+	case "lb", "lbl", "label", "tlb":
+		if len(args) < 2 {
+			return nil, true
+		}
+		labels := args[1:]
+		var b strings.Builder
+		for i, label := range labels {
+			if label == "_" {
+				continue
+			}
+			switch {
+			case i == 0:
+			case labels[i-1] == "_" || labels[i-1] == "and" || labels[i-1] == "or" || label == "and" || label == "or":
+				b.WriteString(" ")
+			default:
+				b.WriteString(", ")
+			}
+			b.WriteString(label)
+		}
+		return text("(" + strings.TrimSpace(b.String()) + ")"), true
+	// https://en.wiktionary.org/wiki/Template:link
+	// {{l|en|giraffe|alternative text|gloss}}
+	case "l", "l-self", "ll", "m", "m-self", "mention":
+		word := strings.TrimSpace(PlainText(t.Arg(2)))
+		label := t.Arg(3)
+		if strings.TrimSpace(PlainText(label)) == "" {
+			label = t.Arg(2)
+		}
+		nodes := []Node{&Link{Target: word, Children: label}}
+		if k := t.Key(); k == "m" || k == "m-self" || k == "mention" {
+			nodes = []Node{&Italic{Children: nodes}}
+		}
+		gloss := t.Arg(4)
+		if value, ok := t.Named("t"); ok {
+			gloss = value
+		}
+		if g := strings.TrimSpace(PlainText(gloss)); g != "" {
+			nodes = append(nodes, &Text{Value: " “" + g + "”"})
+		}
+		return nodes, true
+	case "gloss", "gl":
+		return append(append(text("("), t.Arg(1)...), &Text{Value: ")"}), true
+	case "q", "qual", "qualifier", "i", "qf", "sense", "s":
+		if len(args) == 0 {
+			return nil, true
+		}
+		return text("(" + strings.Join(args, ", ") + ")"), true
+	// https://en.wiktionary.org/wiki/Template:usage_example
+	// {{ux|en|The '''giraffe''' ate.|translation}}
+	case "ux", "uxi", "usex", "eg":
+		nodes := []Node{&Italic{Children: t.Arg(2)}}
+		translation := t.Arg(3)
+		if value, ok := t.Named("t"); ok {
+			translation = value
+		}
+		if len(trimNodes(translation)) > 0 {
+			nodes = append(append(nodes, &Text{Value: " ― "}), translation...)
+		}
+		return nodes, true
+	case "n-g", "ngd", "non-gloss", "non-gloss definition", "taxlink", "taxfmt":
+		return []Node{&Italic{Children: t.Arg(1)}}, true
+	case "w", "pedia", "vern":
+		if label := t.Arg(2); len(trimNodes(label)) > 0 && t.Key() == "w" {
+			return label, true
+		}
+		return t.Arg(1), true
+	// https://en.wiktionary.org/wiki/Template:derived
+	// {{der|en|ar|زرافة}} is Arabic زرافة
+	case "der", "der+", "inh", "inh+", "bor", "bor+", "lbor", "slbor", "uder",
+		"cog", "ncog", "noncog", "cal", "calque":
+		lang, word := t.Arg(2), t.Arg(3)
+		if k := t.Key(); k == "cog" || k == "ncog" || k == "noncog" {
+			lang, word = t.Arg(1), t.Arg(2)
+		}
+		nodes := text(languageName(strings.TrimSpace(PlainText(lang))))
+		if w := strings.TrimSpace(PlainText(word)); w != "" && w != "-" {
+			nodes = append(nodes, &Text{Value: " "}, &Italic{Children: word})
+		}
+		return nodes, true
+	// {{af|en|giraffe|-like}} is giraffe + -like
+	case "af", "affix", "compound", "com", "prefix", "pre", "suffix", "suf", "confix", "con":
+		if len(args) < 2 {
+			return nil, true
+		}
+		return []Node{&Italic{Children: text(strings.Join(args[1:], " + "))}}, true
+	case "ipa":
+		if len(args) < 2 {
+			return nil, true
+		}
+		return text(strings.Join(args[1:], ", ")), true
+	case "t", "t+", "tt", "tt+", "t-simple", "t-check", "t+check":
+		return t.Arg(2), true
+	// Bookkeeping that isn't part of the text
+	case "senseid", "sid", "defdate", "rfv-sense", "rfd-sense", "rfex", "rfdef",
+		"rfquote", "rfquotek", "attn", "c", "cln", "top", "topics", "catlangname":
+		return nil, true
+	}
+	return nil, false
+}
+
+func (d *Dictionary) language(name string) (DictionaryLanguage, bool) {
+	for _, lang := range d.Languages {
+		if strings.EqualFold(lang.Name, name) {
+			return lang, true
+		}
+	}
+	return DictionaryLanguage{}, false
+}
+
+// Only the entry for the language called name, e.g. English,
+// or every language's when there's none for it
+func (d *Dictionary) In(name string) *Dictionary {
+	lang, ok := d.language(name)
+	if !ok {
+		return d
+	}
+	only := *d
+	only.Languages = []DictionaryLanguage{lang}
+	return &only
+}
+
+// Lays the entry out as a compact card: the word, then per
+// language its pronunciations, numbered definitions with their
+// examples, etymologies and translations
+func (d *Dictionary) Document() *Document {
+	if len(d.Languages) == 0 {
+		return d.page
+	}
+	text := func(s string) []Node {
+		return []Node{&Text{Value: s}}
+	}
+	doc := &Document{Children: []Node{&Paragraph{Children: []Node{&Bold{Children: text(d.Word)}}}}}
+	for _, lang := range d.Languages {
+		section := &Section{Level: 2, Title: text(lang.Name)}
+		if len(lang.Pronunciations) > 0 {
+			section.Children = append(section.Children, &Paragraph{Children: text(strings.Join(lang.Pronunciations, "; "))})
+		}
+		for _, pos := range lang.Senses {
+			section.Children = append(section.Children, &Section{
+				Level:    3,
+				Title:    text(pos.Name),
+				Children: []Node{definitionList(pos.Definitions)},
+			})
+		}
+		if len(lang.Etymologies) > 0 {
+			etymology := &Section{Level: 3, Title: text("Etymology")}
+			for _, e := range lang.Etymologies {
+				etymology.Children = append(etymology.Children, &Paragraph{Children: e})
+			}
+			section.Children = append(section.Children, etymology)
+		}
+		if len(lang.Translations) > 0 {
+			list := &List{}
+			sense := ""
+			for i, tr := range lang.Translations {
+				if i == 0 || tr.Sense != sense {
+					sense = tr.Sense
+					if sense != "" {
+						list.Items = append(list.Items, &ListItem{Marker: ';', Children: text(sense)})
+					}
+				}
+				list.Items = append(list.Items, &ListItem{Marker: ':', Children: text(tr.Lang + ": " + tr.Words)})
+			}
+			section.Children = append(section.Children, &Section{Level: 3, Title: text("Translations"), Children: []Node{list}})
+		}
+		doc.Children = append(doc.Children, section)
+	}
+	return doc
+}
+
+func definitionList(defs []Definition) *List {
+	list := &List{}
+	for _, def := range defs {
+		item := &ListItem{Marker: '#', Children: def.Text}
+		if len(def.Examples) > 0 || len(def.Subsenses) > 0 {
+			item.Sublist = &List{}
+			for _, example := range def.Examples {
+				item.Sublist.Items = append(item.Sublist.Items, &ListItem{Marker: ':', Children: []Node{&Italic{Children: example}}})
+			}
+			if len(def.Subsenses) > 0 {
+				item.Sublist.Items = append(item.Sublist.Items, definitionList(def.Subsenses).Items...)
+			}
+		}
+		list.Items = append(list.Items, item)
+	}
+	return list
+}
+
+// Looks word up, in lower case too since words starting sentences
+// are capitalized, preferring entries for the language called lang
+func lookUp(ctx context.Context, src Source, word string, lang string) (*Dictionary, error) {
+	titles := []string{word}
+	if lower := strings.ToLower(word); lower != word {
+		titles = append(titles, lower)
+	}
+	var found *Dictionary
+	for _, title := range titles {
+		article, err := src.LoadArticle(ctx, Article{Title: title})
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidTitle) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		d := ParseDictionary(article.Title, article.Document)
+		if _, ok := d.language(lang); ok {
+			return d.In(lang), nil
+		}
+		if found == nil {
+			found = d
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: no entry for %q", ErrNotFound, word)
+	}
+	return found, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testEntry = `==English==
+
+===Etymology===
+From {{bor|en|it|giraffa}}, from {{der|en|ar|زرافة}}.
+
+===Pronunciation===
+* {{a|en|UK}} {{IPA|en|/dʒɪˈɹɑːf/}}
+* {{a|en|US}} {{IPA|en|/dʒəˈɹæf/}}
+
+===Noun===
+{{en-noun}}
+
+# {{lb|en|zoology}} A tall African [[mammal]] of the genus {{taxlink|Giraffa|genus}}.
+#: {{ux|en|The '''giraffe''' ate the leaves.}}
+#* {{quote-book|en|year=1900|passage=A giraffe.}}
+# {{lb|en|informal|_|or|figurative}} A tall person.
+## A very tall person.
+
+====Translations====
+{{trans-top|tall African mammal}}
+* French: {{t+|fr|girafe|f}}
+* Chinese:
+*: Mandarin: {{t+|cmn|長頸鹿}}
+{{trans-bottom}}
+
+===Anagrams===
+* {{anagrams|en|a=aeffgir}}
+
+==German==
+
+===Noun===
+# {{l|de|Giraffe}} giraffe
+`
+
+func TestParseDictionary(t *testing.T) {
+	d := ParseDictionary("giraffe", ParseWikitext(testEntry))
+	if len(d.Languages) != 2 || d.Languages[0].Name != "English" || d.Languages[1].Name != "German" {
+		t.Fatalf("ParseDictionary() languages = %+v", d.Languages)
+	}
+	english := d.Languages[0]
+	tests := map[string]struct {
+		got      any
+		expected any
+	}{
+		"pronunciations": {
+			got:      english.Pronunciations,
+			expected: []string{"(UK) /dʒɪˈɹɑːf/", "(US) /dʒəˈɹæf/"},
+		},
+		"etymology": {
+			got:      PlainText(english.Etymologies[0]),
+			expected: "From Italian giraffa, from Arabic زرافة.",
+		},
+		"parts of speech": {
+			got:      []string{english.Senses[0].Name},
+			expected: []string{"Noun"},
+		},
+		"definition": {
+			got:      PlainText(english.Senses[0].Definitions[0].Text),
+			expected: "(zoology) A tall African mammal of the genus Giraffa.",
+		},
+		"examples without quotations": {
+			got:      len(english.Senses[0].Definitions[0].Examples),
+			expected: 1,
+		},
+		"labels joined": {
+			got:      PlainText(english.Senses[0].Definitions[1].Text),
+			expected: "(informal or figurative) A tall person.",
+		},
+		"subsenses": {
+			got:      PlainText(english.Senses[0].Definitions[1].Subsenses[0].Text),
+			expected: "A very tall person.",
+		},
+		"translations": {
+			got: english.Translations,
+			expected: []Translation{
+				{Sense: "tall African mammal", Lang: "French", Words: "girafe"},
+				{Sense: "tall African mammal", Lang: "Mandarin", Words: "長頸鹿"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if !reflect.DeepEqual(test.got, test.expected) {
+				t.Fatalf("got %#v, expected %#v", test.got, test.expected)
+			}
+		})
+	}
+}
+
+func TestDictionaryDocument(t *testing.T) {
+	d := ParseDictionary("giraffe", ParseWikitext(testEntry)).In("English")
+	expected := `giraffe
+
+English
+
+(UK) /dʒɪˈɹɑːf/; (US) /dʒəˈɹæf/
+
+Noun
+
+1. (zoology) A tall African mammal of the genus Giraffa.
+   The giraffe ate the leaves.
+2. (informal or figurative) A tall person.
+   1. A very tall person.
+
+Etymology
+
+From Italian giraffa, from Arabic زرافة.
+
+Translations
+
+tall African mammal
+  French: girafe
+  Mandarin: 長頸鹿`
+	if got := Render(d.Document(), 0).String(); got != expected {
+		t.Fatalf("Document() renders as\n%s\nexpected\n%s", got, expected)
+	}
+
+	// Pages laid out some other way are shown as they are
+	page := ParseDictionary("Haus", ParseWikitext("== Haus ({{Sprache|Deutsch}}) ==\n{{Bedeutungen}}\n:[1] Gebäude"))
+	if got := Render(page.Document(), 0).String(); !strings.Contains(got, "Gebäude") {
+		t.Fatalf("Document() of an unknown layout renders as %q", got)
+	}
+}
+
+func TestLookUp(t *testing.T) {
+	src := &fakeSource{lang: "en", articles: map[string]string{
+		"giraffe": testEntry,
+		"Giraffe": "==German==\n===Noun===\n# giraffe",
+	}}
+	tests := map[string]struct {
+		word  string
+		lang  string
+		langs []string
+	}{
+		"the language asked for": {
+			word:  "giraffe",
+			lang:  "German",
+			langs: []string{"German"},
+		},
+		"lower case for the language asked for": {
+			word:  "Giraffe",
+			lang:  "English",
+			langs: []string{"English"},
+		},
+		"every language without one": {
+			word:  "giraffe",
+			lang:  "French",
+			langs: []string{"English", "German"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, err := lookUp(context.Background(), src, test.word, test.lang)
+			if err != nil {
+				t.Fatalf("lookUp() error = %v", err)
+			}
+			var langs []string
+			for _, lang := range d.Languages {
+				langs = append(langs, lang.Name)
+			}
+			if !reflect.DeepEqual(langs, test.langs) {
+				t.Fatalf("lookUp() languages = %q, expected %q", langs, test.langs)
+			}
+		})
+	}
+}
@@ -0,0 +1,716 @@
+package main
+
+// Reading Wikipedia offline from Kiwix ZIM archives,
+// see https://wiki.openzim.org/wiki/ZIM_file_format
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"golang.org/x/net/html"
+	"golang.org/x/text/language"
+)
+
+const (
+	zimMagic = 72173914
+	// Directory entries with this MIME type index redirect elsewhere
+	zimRedirect = 0xffff
+	// Most redirects followed to reach an article
+	maxRedirects = 5
+)
+
+var ErrNotZIM = errors.New("not a ZIM archive")
+
+type zimHeader struct {
+	Magic         uint32
+	Major, Minor  uint16
+	UUID          [16]byte
+	EntryCount    uint32
+	ClusterCount  uint32
+	URLPtrPos     uint64
+	TitlePtrPos   uint64
+	ClusterPtrPos uint64
+	MIMEListPos   uint64
+	MainPage      uint32
+	LayoutPage    uint32
+	ChecksumPos   uint64
+}
+
+// zimEntry is a directory entry: an article, a piece of
+// metadata or an image, or a redirect to another entry
+type zimEntry struct {
+	mimeType  uint16
+	namespace byte
+	// Where the content is, for entries that aren't redirects
+	cluster, blob uint32
+	// Index of the entry redirected to
+	redirect uint32
+	url      string
+	title    string
+}
+
+func (e zimEntry) isRedirect() bool {
+	return e.mimeType == zimRedirect
+}
+
+// Entries without a title of their own go by their URL
+func (e zimEntry) displayTitle() string {
+	if e.title != "" {
+		return e.title
+	}
+	return e.url
+}
+
+// zimArchive reads searches and articles from a ZIM file,
+// the same way Client does from a Wikipedia's API
+type zimArchive struct {
+	file      io.ReaderAt
+	closer    io.Closer
+	header    zimHeader
+	mimeTypes []string
+	// Namespace articles are in, C since version 6.1 and A before
+	namespace byte
+	// From the archive's metadata
+	title    string
+	language string
+	// Words of the articles, when wki index has indexed them
+	words *wordTable
+
+	// The last cluster read, as articles next to each other
+	// in the index tend to be stored together
+	mu          sync.Mutex
+	lastCluster uint32
+	lastData    []byte
+	lastWide    bool
+}
+
+// Opens the ZIM archive at path, along with the
+// full-text index wki index made of it, if there is one
+func openZIM(path string) (*zimArchive, error) {
+	z, err := openZIMArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	if z.words, err = openZIMIndex(z, defaultIndexDir(path)); err != nil {
+		z.Close()
+		return nil, err
+	}
+	return z, nil
+}
+
+func openZIMArchive(path string) (*zimArchive, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	z, err := newZIMArchive(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	z.closer = file
+	if z.title == "" {
+		z.title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return z, nil
+}
+
+func newZIMArchive(file io.ReaderAt) (*zimArchive, error) {
+	z := &zimArchive{file: file, lastCluster: ^uint32(0)}
+	err := binary.Read(io.NewSectionReader(file, 0, 80), binary.LittleEndian, &z.header)
+	if err != nil || z.header.Magic != zimMagic {
+		return nil, ErrNotZIM
+	}
+	z.namespace = 'A'
+	if z.header.Major > 6 || z.header.Major == 6 && z.header.Minor >= 1 {
+		z.namespace = 'C'
+	}
+
+	// The MIME types are strings ending in an empty one
+	pos := int64(z.header.MIMEListPos)
+	for {
+		mimeType, err := z.readString(pos)
+		if err != nil {
+			return nil, err
+		}
+		if mimeType == "" {
+			break
+		}
+		z.mimeTypes = append(z.mimeTypes, mimeType)
+		pos += int64(len(mimeType)) + 1
+	}
+
+	z.title = z.metadata("Title")
+	z.language = zimLanguage(z.metadata("Language"))
+	return z, nil
+}
+
+// Wikipedias whose codes aren't the ISO 639-1 code of their language
+var zimWikipediaCodes = map[string]string{
+	"nb":  "no",
+	"yue": "zh-yue",
+}
+
+// Archives give their languages as ISO 639-3 codes, e.g. eng or
+// eng,fra, where Wikipedias go by the shorter ISO 639-1 codes
+func zimLanguage(metadata string) string {
+	code, _, _ := strings.Cut(metadata, ",")
+	code = strings.TrimSpace(code)
+	base, err := language.ParseBase(code)
+	if err != nil {
+		return code
+	}
+	if wiki, ok := zimWikipediaCodes[base.String()]; ok {
+		return wiki
+	}
+	return base.String()
+}
+
+func (z *zimArchive) Close() error {
+	if z.words != nil {
+		z.words.Close()
+	}
+	if z.closer == nil {
+		return nil
+	}
+	return z.closer.Close()
+}
+
+// A metadata value such as Title or Language, empty if there's none
+func (z *zimArchive) metadata(name string) string {
+	i, ok := z.findURL('M', name)
+	if !ok {
+		return ""
+	}
+	entry, err := z.entry(i)
+	if err != nil {
+		return ""
+	}
+	data, err := z.content(entry)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Reads a NUL terminated string at pos
+func (z *zimArchive) readString(pos int64) (string, error) {
+	var s []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := z.file.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], 0); i >= 0 {
+			return string(append(s, buf[:i]...)), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: unterminated string at %d", ErrNotZIM, pos)
+		}
+		s = append(s, buf[:n]...)
+		pos += int64(n)
+	}
+}
+
+func (z *zimArchive) readUint(pos int64, size int) (uint64, error) {
+	buf := make([]byte, 8)
+	if _, err := z.file.ReadAt(buf[:size], pos); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrNotZIM, err)
+	}
+	return binary.LittleEndian.Uint64(buf), nil
+}
+
+// The directory entry at index i of the URL pointer list
+func (z *zimArchive) entry(i uint32) (zimEntry, error) {
+	if i >= z.header.EntryCount {
+		return zimEntry{}, fmt.Errorf("%w: no entry %d", ErrNotZIM, i)
+	}
+	pos, err := z.readUint(int64(z.header.URLPtrPos)+8*int64(i), 8)
+	if err != nil {
+		return zimEntry{}, err
+	}
+	fixed := make([]byte, 16)
+	if _, err := z.file.ReadAt(fixed, int64(pos)); err != nil {
+		return zimEntry{}, fmt.Errorf("%w: %v", ErrNotZIM, err)
+	}
+	entry := zimEntry{
+		mimeType:  binary.LittleEndian.Uint16(fixed),
+		namespace: fixed[3],
+	}
+	// Redirects have an index where others have a cluster and blob
+	names := int64(pos) + 16
+	if entry.isRedirect() {
+		entry.redirect = binary.LittleEndian.Uint32(fixed[8:])
+		names = int64(pos) + 12
+	} else {
+		entry.cluster = binary.LittleEndian.Uint32(fixed[8:])
+		entry.blob = binary.LittleEndian.Uint32(fixed[12:])
+	}
+	if entry.url, err = z.readString(names); err != nil {
+		return entry, err
+	}
+	entry.title, err = z.readString(names + int64(len(entry.url)) + 1)
+	return entry, err
+}
+
+// The entry at index i of the title pointer list
+func (z *zimArchive) entryByTitle(i uint32) (zimEntry, error) {
+	index, err := z.readUint(int64(z.header.TitlePtrPos)+4*int64(i), 4)
+	if err != nil {
+		return zimEntry{}, err
+	}
+	return z.entry(uint32(index))
+}
+
+// Finds the index of the entry with url in namespace,
+// which are sorted by namespace and then URL
+func (z *zimArchive) findURL(namespace byte, url string) (uint32, bool) {
+	key := string(namespace) + url
+	var failed bool
+	i := sort.Search(int(z.header.EntryCount), func(i int) bool {
+		entry, err := z.entry(uint32(i))
+		failed = failed || err != nil
+		return string(entry.namespace)+entry.url >= key
+	})
+	if failed || i == int(z.header.EntryCount) {
+		return 0, false
+	}
+	entry, err := z.entry(uint32(i))
+	return uint32(i), err == nil && entry.namespace == namespace && entry.url == url
+}
+
+// Index in the title pointer list of the first article
+// whose title sorts at or after title
+func (z *zimArchive) searchTitle(title string) uint32 {
+	key := string(z.namespace) + title
+	return uint32(sort.Search(int(z.header.EntryCount), func(i int) bool {
+		entry, err := z.entryByTitle(uint32(i))
+		return err != nil || string(entry.namespace)+entry.displayTitle() >= key
+	}))
+}
+
+// The content of an entry, following redirects
+func (z *zimArchive) content(entry zimEntry) ([]byte, error) {
+	entry, err := z.resolve(entry)
+	if err != nil {
+		return nil, err
+	}
+	return z.blob(entry.cluster, entry.blob)
+}
+
+// The entry a redirect leads to, or entry if it isn't one
+func (z *zimArchive) resolve(entry zimEntry) (zimEntry, error) {
+	for range maxRedirects {
+		if !entry.isRedirect() {
+			return entry, nil
+		}
+		var err error
+		if entry, err = z.entry(entry.redirect); err != nil {
+			return entry, err
+		}
+	}
+	return entry, fmt.Errorf("%w: too many redirects to %q", ErrNotZIM, entry.url)
+}
+
+// Reads blob n of a cluster. Clusters start with a byte giving
+// their compression, then a list of offsets to their blobs.
+func (z *zimArchive) blob(cluster uint32, n uint32) ([]byte, error) {
+	data, wide, err := z.cluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+	size := 4
+	if wide {
+		size = 8
+	}
+	offset := func(i uint32) uint64 {
+		at := int(i) * size
+		if at+size > len(data) {
+			return 0
+		}
+		if wide {
+			return binary.LittleEndian.Uint64(data[at:])
+		}
+		return uint64(binary.LittleEndian.Uint32(data[at:]))
+	}
+	blobs := offset(0) / uint64(size)
+	start, end := offset(n), offset(n+1)
+	if uint64(n)+1 >= blobs || start > end || end > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: no blob %d in cluster %d", ErrNotZIM, n, cluster)
+	}
+	return data[start:end], nil
+}
+
+// The uncompressed data of a cluster, and whether its offsets are 64-bit
+func (z *zimArchive) cluster(n uint32) ([]byte, bool, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if n == z.lastCluster {
+		return z.lastData, z.lastWide, nil
+	}
+	if n >= z.header.ClusterCount {
+		return nil, false, fmt.Errorf("%w: no cluster %d", ErrNotZIM, n)
+	}
+	start, err := z.readUint(int64(z.header.ClusterPtrPos)+8*int64(n), 8)
+	if err != nil {
+		return nil, false, err
+	}
+	end := z.header.ChecksumPos
+	if n+1 < z.header.ClusterCount {
+		if end, err = z.readUint(int64(z.header.ClusterPtrPos)+8*int64(n+1), 8); err != nil {
+			return nil, false, err
+		}
+	}
+	if end <= start {
+		return nil, false, fmt.Errorf("%w: bad cluster %d", ErrNotZIM, n)
+	}
+	raw := make([]byte, end-start)
+	if _, err := z.file.ReadAt(raw, int64(start)); err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrNotZIM, err)
+	}
+
+	info := raw[0]
+	wide := info&0x10 != 0
+	var data []byte
+	switch info & 0x0f {
+	case 0, 1:
+		data = raw[1:]
+	case 4:
+		r, err := xz.NewReader(bytes.NewReader(raw[1:]))
+		if err == nil {
+			data, err = io.ReadAll(r)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: cluster %d: %v", ErrNotZIM, n, err)
+		}
+	case 5:
+		r, err := zstd.NewReader(bytes.NewReader(raw[1:]))
+		if err == nil {
+			data, err = io.ReadAll(r)
+			r.Close()
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: cluster %d: %v", ErrNotZIM, n, err)
+		}
+	default:
+		return nil, false, fmt.Errorf("%w: cluster %d has unknown compression %d", ErrNotZIM, n, info&0x0f)
+	}
+	z.lastCluster, z.lastData, z.lastWide = n, data, wide
+	return data, wide, nil
+}
+
+func (z *zimArchive) isArticle(entry zimEntry) bool {
+	if entry.namespace != z.namespace {
+		return false
+	}
+	if entry.isRedirect() {
+		return true
+	}
+	return int(entry.mimeType) < len(z.mimeTypes) && strings.HasPrefix(z.mimeTypes[entry.mimeType], "text/html")
+}
+
+// Ways a query may be capitalized in titles: as typed,
+// then starting with a capital, then with every word capitalized
+func titleVariants(query string) []string {
+	variants := []string{query}
+	add := func(s string) {
+		for _, v := range variants {
+			if v == s {
+				return
+			}
+		}
+		variants = append(variants, s)
+	}
+	r, size := utf8.DecodeRuneInString(query)
+	add(string(unicode.ToUpper(r)) + query[size:])
+	words := strings.Fields(query)
+	for i, word := range words {
+		r, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToUpper(r)) + word[size:]
+	}
+	add(strings.Join(words, " "))
+	return variants
+}
+
+// Searches article titles starting with query, using the archive's
+// title index, then, once wki index has indexed the archive, the
+// articles having every word of the query. The archive's own
+// full-text index is a Xapian database, which would need Xapian.
+func (z *zimArchive) Search(ctx context.Context, query string, limit int, offset int) ([]SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+	var results []SearchResult
+	seen := map[string]bool{}
+	skipped := 0
+	add := func(entry zimEntry) {
+		title := entry.displayTitle()
+		if seen[title] || len(results) >= limit {
+			return
+		}
+		seen[title] = true
+		if skipped < offset {
+			skipped++
+			return
+		}
+		results = append(results, SearchResult{Title: title, Url: z.articleUrl(entry)})
+	}
+
+	for _, prefix := range titleVariants(query) {
+		for i := z.searchTitle(prefix); i < z.header.EntryCount && len(results) < limit; i++ {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			entry, err := z.entryByTitle(i)
+			if err != nil {
+				return nil, err
+			}
+			if entry.namespace != z.namespace || !strings.HasPrefix(entry.displayTitle(), prefix) {
+				break
+			}
+			if z.isArticle(entry) {
+				add(entry)
+			}
+		}
+	}
+
+	if z.words == nil || len(results) >= limit {
+		return results, nil
+	}
+	matches, err := z.words.search(query)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range matches {
+		if len(results) >= limit {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		entry, err := z.entry(i)
+		if err != nil {
+			return nil, err
+		}
+		add(entry)
+	}
+	return results, nil
+}
+
+// What a full-text index of an archive is of
+type zimIndexInfo struct {
+	// The archive's UUID in hex, to tell it from others of the same name
+	UUID     string `json:"uuid"`
+	Articles int    `json:"articles"`
+}
+
+func (z *zimArchive) uuid() string {
+	return hex.EncodeToString(z.header.UUID[:])
+}
+
+// Builds a full-text index in dir of the words of an archive's
+// articles, which Search looks through after titles. Articles are
+// read a cluster at a time, as decompressing them is the slow part.
+// Reports how many articles were indexed.
+func buildZIMIndex(ctx context.Context, z *zimArchive, dir string) (int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	// The index isn't one until it's finished
+	if err := os.Remove(filepath.Join(dir, dumpInfoFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	type place struct {
+		cluster, blob uint32
+		entry         uint32
+	}
+	var places []place
+	for i := range z.header.EntryCount {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		entry, err := z.entry(i)
+		if err != nil {
+			return 0, err
+		}
+		if z.isArticle(entry) && !entry.isRedirect() {
+			places = append(places, place{cluster: entry.cluster, blob: entry.blob, entry: i})
+		}
+	}
+	slices.SortFunc(places, func(a, b place) int {
+		return cmp.Or(cmp.Compare(a.cluster, b.cluster), cmp.Compare(a.blob, b.blob))
+	})
+
+	words := newWordIndex(dir)
+	defer words.runs.remove()
+	for _, p := range places {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		data, err := z.blob(p.cluster, p.blob)
+		if err != nil {
+			return 0, err
+		}
+		if err := words.add(p.entry, dumpWords(htmlText(data))); err != nil {
+			return 0, err
+		}
+	}
+	if err := words.write(dir); err != nil {
+		return 0, err
+	}
+
+	data, err := json.MarshalIndent(zimIndexInfo{UUID: z.uuid(), Articles: len(places)}, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	return len(places), os.WriteFile(filepath.Join(dir, dumpInfoFile), data, 0o644)
+}
+
+// Opens the full-text index of an archive in dir, if it has one
+func openZIMIndex(z *zimArchive, dir string) (*wordTable, error) {
+	data, err := os.ReadFile(filepath.Join(dir, dumpInfoFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var info zimIndexInfo
+	if err := json.Unmarshal(data, &info); err != nil || info.UUID != z.uuid() {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNotDumpIndex)
+	}
+	return openWordTable(dir)
+}
+
+// The text of an HTML page, without its scripts and styles
+func htmlText(data []byte) string {
+	var b strings.Builder
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	skip := false
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			skip = string(name) == "script" || string(name) == "style"
+		case html.EndTagToken:
+			skip = false
+			b.WriteByte(' ')
+		case html.TextToken:
+			if !skip {
+				b.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// Where an article is in the archive, e.g. zim:wikipedia_en/Giraffe
+func (z *zimArchive) articleUrl(entry zimEntry) string {
+	return "zim:" + z.title + "/" + entry.url
+}
+
+// Loads an article by its URL in the archive, e.g. Giraffe_family,
+// or failing that by its title
+func (z *zimArchive) LoadArticle(ctx context.Context, article Article) (Article, error) {
+	title, _, _ := strings.Cut(article.Title, "#")
+	title = strings.TrimSpace(title)
+	i, ok := z.findURL(z.namespace, strings.ReplaceAll(title, " ", "_"))
+	if !ok {
+		i, ok = z.findURL(z.namespace, title)
+	}
+	var entry zimEntry
+	var err error
+	if ok {
+		entry, err = z.entry(i)
+	} else {
+		index := z.searchTitle(title)
+		if index >= z.header.EntryCount {
+			return article, fmt.Errorf("%w: %q", ErrNotFound, article.Title)
+		}
+		entry, err = z.entryByTitle(index)
+		if err == nil && (entry.namespace != z.namespace || entry.displayTitle() != title) {
+			return article, fmt.Errorf("%w: %q", ErrNotFound, article.Title)
+		}
+	}
+	if err != nil {
+		return article, err
+	}
+	if entry, err = z.resolve(entry); err != nil {
+		return article, err
+	}
+	if !z.isArticle(entry) {
+		return article, fmt.Errorf("%w: %q", ErrNotFound, article.Title)
+	}
+	if err := ctx.Err(); err != nil {
+		return article, err
+	}
+	data, err := z.blob(entry.cluster, entry.blob)
+	if err != nil {
+		return article, err
+	}
+
+	article.Title = entry.displayTitle()
+	article.Url = z.articleUrl(entry)
+	article.Lang = z.Language()
+	article.LangLinks = nil
+	article.Document = ParseHTML(string(data), "")
+	return article, nil
+}
+
+// Picks up to n articles at random, trying entries
+// at random until enough of them are articles
+func (z *zimArchive) Random(ctx context.Context, n int) ([]string, error) {
+	var titles []string
+	for tries := 0; len(titles) < n && tries < 100*n && z.header.EntryCount > 0; tries++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		entry, err := z.entry(rand.Uint32N(z.header.EntryCount))
+		if err != nil {
+			return nil, err
+		}
+		if z.isArticle(entry) && !entry.isRedirect() {
+			titles = append(titles, entry.displayTitle())
+		}
+	}
+	return titles, nil
+}
+
+// Archives don't link to other languages
+func (z *zimArchive) LangLinks(ctx context.Context, title string) ([]LangLink, error) {
+	return nil, nil
+}
+
+func (z *zimArchive) Facts(ctx context.Context, title string) ([]Fact, error) {
+	return nil, nil
+}
+
+func (z *zimArchive) Language() string {
+	return z.language
+}
+
+func (z *zimArchive) SiteName() string {
+	return z.title
+}
+
+func (z *zimArchive) InLang(lang string) (Source, error) {
+	return nil, fmt.Errorf("%w: %s", ErrOneLanguage, z.language)
+}
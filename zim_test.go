@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type zimFixtureEntry struct {
+	namespace byte
+	url       string
+	title     string
+	mimeType  string
+	content   string
+	// URL of the entry redirected to, in the same namespace
+	redirect string
+}
+
+// Writes a ZIM archive of entries, keeping metadata in an
+// uncompressed cluster and everything else in a zstd one
+func writeZIM(t *testing.T, entries []zimFixtureEntry) []byte {
+	t.Helper()
+	sort.Slice(entries, func(i, j int) bool {
+		return string(entries[i].namespace)+entries[i].url < string(entries[j].namespace)+entries[j].url
+	})
+	index := map[string]uint32{}
+	for i, e := range entries {
+		index[string(e.namespace)+e.url] = uint32(i)
+	}
+	titles := make([]uint32, len(entries))
+	for i := range titles {
+		titles[i] = uint32(i)
+	}
+	title := func(e zimFixtureEntry) string {
+		if e.title != "" {
+			return e.title
+		}
+		return e.url
+	}
+	sort.SliceStable(titles, func(i, j int) bool {
+		a, b := entries[titles[i]], entries[titles[j]]
+		return string(a.namespace)+title(a) < string(b.namespace)+title(b)
+	})
+
+	var mimeTypes []string
+	mimeIndex := map[string]uint16{}
+	var blobs [2][]string
+	var dirents [][]byte
+	for _, e := range entries {
+		var d bytes.Buffer
+		if e.redirect != "" {
+			binary.Write(&d, binary.LittleEndian, uint16(zimRedirect))
+			d.Write([]byte{0, e.namespace, 0, 0, 0, 0})
+			binary.Write(&d, binary.LittleEndian, index[string(e.namespace)+e.redirect])
+		} else {
+			if _, ok := mimeIndex[e.mimeType]; !ok {
+				mimeIndex[e.mimeType] = uint16(len(mimeTypes))
+				mimeTypes = append(mimeTypes, e.mimeType)
+			}
+			cluster := 1
+			if e.namespace == 'M' {
+				cluster = 0
+			}
+			binary.Write(&d, binary.LittleEndian, mimeIndex[e.mimeType])
+			d.Write([]byte{0, e.namespace, 0, 0, 0, 0})
+			binary.Write(&d, binary.LittleEndian, uint32(cluster))
+			binary.Write(&d, binary.LittleEndian, uint32(len(blobs[cluster])))
+			blobs[cluster] = append(blobs[cluster], e.content)
+		}
+		d.WriteString(e.url + "\x00" + e.title + "\x00")
+		dirents = append(dirents, d.Bytes())
+	}
+
+	var clusters [][]byte
+	for i, contents := range blobs {
+		var data bytes.Buffer
+		offset := 4 * (len(contents) + 1)
+		for _, content := range contents {
+			binary.Write(&data, binary.LittleEndian, uint32(offset))
+			offset += len(content)
+		}
+		binary.Write(&data, binary.LittleEndian, uint32(offset))
+		for _, content := range contents {
+			data.WriteString(content)
+		}
+		if i == 0 {
+			clusters = append(clusters, append([]byte{1}, data.Bytes()...))
+			continue
+		}
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clusters = append(clusters, encoder.EncodeAll(data.Bytes(), []byte{5}))
+		encoder.Close()
+	}
+
+	mimeList := strings.Join(mimeTypes, "\x00") + "\x00\x00"
+	header := zimHeader{
+		Magic:        zimMagic,
+		Major:        6,
+		Minor:        1,
+		EntryCount:   uint32(len(entries)),
+		ClusterCount: uint32(len(clusters)),
+		MIMEListPos:  80,
+	}
+	header.URLPtrPos = header.MIMEListPos + uint64(len(mimeList))
+	header.TitlePtrPos = header.URLPtrPos + 8*uint64(len(entries))
+	direntPos := header.TitlePtrPos + 4*uint64(len(entries))
+	header.ClusterPtrPos = direntPos
+	for _, d := range dirents {
+		header.ClusterPtrPos += uint64(len(d))
+	}
+	clusterPos := header.ClusterPtrPos + 8*uint64(len(clusters))
+	header.ChecksumPos = clusterPos
+	for _, c := range clusters {
+		header.ChecksumPos += uint64(len(c))
+	}
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, header)
+	out.WriteString(mimeList)
+	pos := direntPos
+	for _, d := range dirents {
+		binary.Write(&out, binary.LittleEndian, pos)
+		pos += uint64(len(d))
+	}
+	binary.Write(&out, binary.LittleEndian, titles)
+	for _, d := range dirents {
+		out.Write(d)
+	}
+	pos = clusterPos
+	for _, c := range clusters {
+		binary.Write(&out, binary.LittleEndian, pos)
+		pos += uint64(len(c))
+	}
+	for _, c := range clusters {
+		out.Write(c)
+	}
+	// The MD5 checksum, which isn't checked
+	out.Write(make([]byte, 16))
+	return out.Bytes()
+}
+
+func testZIM(t *testing.T) *zimArchive {
+	t.Helper()
+	z, err := newZIMArchive(bytes.NewReader(testZIMData(t)))
+	if err != nil {
+		t.Fatalf("newZIMArchive() error = %v", err)
+	}
+	return z
+}
+
+func testZIMData(t *testing.T) []byte {
+	t.Helper()
+	return writeZIM(t, []zimFixtureEntry{
+		{namespace: 'M', url: "Title", mimeType: "text/plain", content: "Wikipedia (test)"},
+		{namespace: 'M', url: "Language", mimeType: "text/plain", content: "eng"},
+		{namespace: 'C', url: "Giraffe", title: "Giraffe", mimeType: "text/html", content: `<html><body>
+			<h1>Giraffe</h1>
+			<p>The <b>giraffe</b> is in the <a href="./Giraffe_family">giraffe family</a>.</p>
+			<h2>Habitat</h2><p>Savannas.</p></body></html>`},
+		{namespace: 'C', url: "Giraffe_family", title: "Giraffe family", mimeType: "text/html", content: `<p>See <a href="./Giraffidae">Giraffidae</a>.</p>`},
+		{namespace: 'C', url: "Giraffidae", mimeType: "text/html", content: `<p>A family.</p>`},
+		{namespace: 'C', url: "Camelopard", title: "Camelopard", redirect: "Giraffe"},
+		{namespace: 'C', url: "Giraffe.css", mimeType: "text/css", content: `p {}`},
+	})
+}
+
+func TestZIMSearch(t *testing.T) {
+	z := testZIM(t)
+	tests := map[string]struct {
+		query  string
+		limit  int
+		offset int
+		titles []string
+	}{
+		"title prefix": {
+			query:  "Giraf",
+			limit:  10,
+			titles: []string{"Giraffe", "Giraffe family", "Giraffidae"},
+		},
+		"lowercase": {
+			query:  "giraffe f",
+			limit:  10,
+			titles: []string{"Giraffe family"},
+		},
+		"paged": {
+			query:  "gir",
+			limit:  1,
+			offset: 1,
+			titles: []string{"Giraffe family"},
+		},
+		"redirect": {
+			query:  "camel",
+			limit:  10,
+			titles: []string{"Camelopard"},
+		},
+		"no match": {
+			query: "okapi",
+			limit: 10,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			results, err := z.Search(context.Background(), test.query, test.limit, test.offset)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			var titles []string
+			for _, result := range results {
+				titles = append(titles, result.Title)
+			}
+			if !reflect.DeepEqual(titles, test.titles) {
+				t.Fatalf("Search() titles = %q, expected %q", titles, test.titles)
+			}
+		})
+	}
+}
+
+func TestZIMLoadArticle(t *testing.T) {
+	z := testZIM(t)
+	tests := map[string]struct {
+		title    string
+		expected string
+		content  string
+		err      error
+	}{
+		"by title": {
+			title:    "Giraffe family",
+			expected: "Giraffe family",
+			content:  "See Giraffidae.",
+		},
+		"untitled": {
+			title:    "Giraffidae",
+			expected: "Giraffidae",
+			content:  "A family.",
+		},
+		"redirect": {
+			title:    "Camelopard",
+			expected: "Giraffe",
+			content:  "The giraffe is in the giraffe family.",
+		},
+		"not an article": {
+			title: "Giraffe.css",
+			err:   ErrNotFound,
+		},
+		"missing": {
+			title: "Okapi",
+			err:   ErrNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			article, err := z.LoadArticle(context.Background(), Article{Title: test.title})
+			if !errors.Is(err, test.err) {
+				t.Fatalf("LoadArticle() error = %v, expected %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			text := Render(article.Document, 0).String()
+			if article.Title != test.expected || !strings.Contains(text, test.content) {
+				t.Fatalf("LoadArticle() = %q with %q, expected %q with %q", article.Title, text, test.expected, test.content)
+			}
+		})
+	}
+
+	article, _ := z.LoadArticle(context.Background(), Article{Title: "Giraffe"})
+	rendering := Render(article.Document, 0)
+	if len(rendering.Links) != 1 || rendering.Links[0].Target != "Giraffe family" || len(rendering.Sections) != 1 {
+		t.Fatalf("Render() links %+v, sections %+v", rendering.Links, rendering.Sections)
+	}
+}
+
+func TestZIMMetadata(t *testing.T) {
+	z := testZIM(t)
+	if z.SiteName() != "Wikipedia (test)" || z.Language() != "en" {
+		t.Fatalf("SiteName() = %q, Language() = %q", z.SiteName(), z.Language())
+	}
+	if _, err := z.InLang("de"); !errors.Is(err, ErrOneLanguage) {
+		t.Fatalf("InLang() error = %v, expected ErrOneLanguage", err)
+	}
+	if _, err := newZIMArchive(strings.NewReader("not a zim")); !errors.Is(err, ErrNotZIM) {
+		t.Fatalf("newZIMArchive() error = %v, expected ErrNotZIM", err)
+	}
+}
+
+func TestZIMLanguage(t *testing.T) {
+	tests := map[string]string{
+		"eng":       "en",
+		"fra,eng":   "fr",
+		"nob":       "no",
+		"yue":       "zh-yue",
+		"en":        "en",
+		"":          "",
+		"not valid": "not valid",
+	}
+	for metadata, expected := range tests {
+		if got := zimLanguage(metadata); got != expected {
+			t.Fatalf("zimLanguage(%q) = %q, expected %q", metadata, got, expected)
+		}
+	}
+}
+
+func TestZIMFullText(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.zim")
+	if err := os.WriteFile(path, testZIMData(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	search := func(query string) []string {
+		t.Helper()
+		z, err := openZIM(path)
+		if err != nil {
+			t.Fatalf("openZIM() error = %v", err)
+		}
+		defer z.Close()
+		results, err := z.Search(context.Background(), query, 10, 0)
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		var titles []string
+		for _, result := range results {
+			titles = append(titles, result.Title)
+		}
+		return titles
+	}
+	if titles := search("savannas"); titles != nil {
+		t.Fatalf("Search() before indexing = %q, expected titles only", titles)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runIndex(context.Background(), nil, []string{path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("runIndex() = %d, stderr %q", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Indexed the words of 3 articles") {
+		t.Fatalf("runIndex() stdout = %q", stdout.String())
+	}
+	tests := map[string][]string{
+		"savannas":       {"Giraffe"},
+		"family":         {"Giraffe", "Giraffidae"},
+		"giraffe family": {"Giraffe family", "Giraffe"},
+		"okapi":          nil,
+	}
+	for query, expected := range tests {
+		if titles := search(query); !reflect.DeepEqual(titles, expected) {
+			t.Fatalf("Search(%q) = %q, expected %q", query, titles, expected)
+		}
+	}
+
+	// An index of another archive isn't used
+	info := filepath.Join(defaultIndexDir(path), dumpInfoFile)
+	if err := os.WriteFile(info, []byte(`{"uuid": "another"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openZIM(path); !errors.Is(err, ErrNotDumpIndex) {
+		t.Fatalf("openZIM() error = %v, expected ErrNotDumpIndex", err)
+	}
+}